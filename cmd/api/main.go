@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
 	"github.com/fekuna/go-rest-clean-architecture/config"
 	"github.com/fekuna/go-rest-clean-architecture/internal/server"
-	"github.com/fekuna/go-rest-clean-architecture/pkg/db/aws"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/db/redis"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 )
 
@@ -33,25 +34,42 @@ func main() {
 	appLogger.InitLogger()
 	appLogger.Infof("AppVersion: %s, LogLevel: %s, Mode: %s, SSL: %v", cfg.Server.AppVersion, cfg.Logger.Level, cfg.Server.Mode, cfg.Server.SSL)
 
-	psqlDB, err := postgres.NewPsqlDB(cfg)
+	redisClient := redis.NewRedisClient(cfg)
+	defer redisClient.Close()
+	appLogger.Info("Redis Connected")
+
+	psqlDB, err := postgres.NewPsqlRouter(cfg, redis.NewWriteTracker(redisClient))
 	if err != nil {
 		appLogger.Fatalf("Postgresql init: %s", err)
 	} else {
-		appLogger.Infof("Postgres connected, Status: %#v", psqlDB.Stats())
+		appLogger.Infof("Postgres connected, Status: %#v", psqlDB.Primary().Stats())
 	}
 	defer psqlDB.Close()
+	if err = psqlDB.CheckReplicas(context.Background()); err != nil {
+		appLogger.Warnf("Postgres replicas unhealthy, reading from primary: %s", err)
+	}
 
-	redisClient := redis.NewRedisClient(cfg)
-	defer redisClient.Close()
-	appLogger.Info("Redis Connected")
+	if cfg.Store.Driver == storage.DriverFS && cfg.Store.SigningKey == "" {
+		// Upload urls signed with a generated key don't survive restarts or work across instances
+		if cfg.Server.Mode != "Development" {
+			appLogger.Fatal("Object store signing key is required by the fs driver, set STORE_SIGNINGKEY")
+		}
+		if cfg.Store.SigningKey, err = storage.NewSigningKey(); err != nil {
+			appLogger.Fatalf("Object store signing key: %s", err)
+		}
+		appLogger.Warn("STORE_SIGNINGKEY is not set, signing upload urls with a generated key")
+	}
 
-	awsClient, err := aws.NewAWSClient(cfg.AWS.Endpoint, cfg.AWS.MinioAccessKey, cfg.AWS.MinioSecretkey, cfg.AWS.UseSSL)
+	store, err := storage.NewObjectStore(cfg)
 	if err != nil {
-		appLogger.Error("AWS Client init: %s", err)
+		appLogger.Fatalf("Object store init: %s", err)
+	}
+	if err = storage.EnsureBuckets(context.Background(), store, cfg); err != nil {
+		appLogger.Fatalf("Object store buckets: %s", err)
 	}
-	appLogger.Info("AWS Client S3 connected")
+	appLogger.Infof("Object store initialized, Driver: %s", cfg.Store.Driver)
 
-	s := server.NewServer(cfg, psqlDB, redisClient, awsClient, appLogger)
+	s := server.NewServer(cfg, psqlDB, redisClient, store, appLogger)
 	if err = s.Run(); err != nil {
 		log.Fatal(err)
 	}
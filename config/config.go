@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -21,24 +22,45 @@ type Config struct {
 	Logger   Logger
 	AWS      AWS
 	Jaeger   Jaeger
+	Jobs     Jobs
+	Cache    Cache
+	CSRF     CSRF
+	CORS     CORS
+	Admin    Admin
+
+	SecurityHeaders SecurityHeaders
 }
 
 // Server config struct
 type ServerConfig struct {
 	AppVersion        string
 	Port              string
-	PprofPort         string
 	Mode              string
 	JwtSecretKey      string
 	CookieName        string
 	ReadTimeout       time.Duration
 	WriteTimeout      time.Duration
 	SSL               bool
+	TLS               TLS
 	CtxDefaultTimeout time.Duration
 	CSRF              bool
 	Debug             bool
 }
 
+// Server TLS config, used when SSL is set
+type TLS struct {
+	CertFile string
+	KeyFile  string
+	// Min protocol version, 1.2 or 1.3
+	MinVersion string
+	// Cipher suite names for TLS 1.2, the Go defaults when empty
+	CipherSuites []string
+	// CA bundle client certificates are verified against
+	ClientCAFile string
+	// Client certificate policy, none, request, require, verify-if-given or require-and-verify
+	ClientAuth string
+}
+
 // Logger config
 type Logger struct {
 	Development       bool
@@ -57,6 +79,28 @@ type PostgresConfig struct {
 	PostgresqlDbName   string
 	PostgresqlSSLMode  bool
 	PgDriver           string
+	Pool               PostgresPool
+	Replicas           []PostgresReplica
+	// Seconds reads of a session stay on the primary after it writes
+	ReadYourWritesWindow int
+	// Seconds between replica health checks
+	ReplicaCheckInterval int
+}
+
+// Postgresql connection pool, durations in seconds
+type PostgresPool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime int
+	ConnMaxIdleTime int
+}
+
+// Postgresql read replica, it shares the primary's credentials and database.
+// A zero pool uses the primary's pool settings.
+type PostgresReplica struct {
+	Host string
+	Port string
+	Pool PostgresPool
 }
 
 // Redis config
@@ -83,13 +127,31 @@ type Cookie struct {
 	MaxAge   int
 	Secure   bool
 	HTTPOnly bool
+	// SameSite attribute of the session cookie, lax, strict or none, browser default when empty
+	SameSite string
 }
 
 // Session config
 type Session struct {
 	Prefix string
 	Name   string
+	// Idle timeout in seconds, activity slides the expiry up to MaxLifetime
 	Expire int
+	// Absolute session lifetime in seconds, zero keeps sessions from sliding
+	MaxLifetime int
+	// Idle timeout and absolute lifetime of remember me sessions, zero uses the one above each on its own
+	RememberMeExpire      int
+	RememberMeMaxLifetime int
+	// Min seconds between expiry refreshes of a session
+	RefreshInterval int
+	// Session store, redis, postgres or memory
+	Store string
+	// Move sessions of earlier versions out of redis into the store
+	MigrateLegacy bool
+	// Max concurrent sessions of a user, zero for no limit
+	MaxPerUser int
+	// What a login past MaxPerUser does, evict-oldest ends the oldest sessions, deny refuses the login
+	LimitPolicy string
 }
 
 // Metrics config
@@ -100,7 +162,27 @@ type Metrics struct {
 
 // Store config
 type Store struct {
+	Driver      string
 	ImageFolder string
+	PublicURL   string
+	// HMAC key of the upload urls of the fs driver. Secret, set it from the STORE_SIGNINGKEY environment variable,
+	// required outside development where a missing one is generated per process
+	SigningKey    string
+	CreateBuckets bool
+	Buckets       Buckets
+}
+
+// Upload buckets, one per upload purpose
+type Buckets struct {
+	Avatars     Bucket
+	News        Bucket
+	Attachments Bucket
+}
+
+// Object storage bucket, public buckets allow anonymous reads
+type Bucket struct {
+	Name   string
+	Public bool
 }
 
 // AWS S3
@@ -119,12 +201,80 @@ type Jaeger struct {
 	LogSpan     bool
 }
 
+// User cache config, TTLs in seconds, a zero LocalSize disables the in-process tier
+type Cache struct {
+	LocalSize   int
+	LocalTTL    int
+	RemoteTTL   int
+	NegativeTTL int
+	Jitter      float64
+	// Redis pub/sub channel local tier invalidations are published on
+	InvalidationChannel string
+}
+
+// CSRF tokens config, checked on unsafe methods when Server.CSRF is set
+type CSRF struct {
+	// HMAC key of the tokens, shared by every instance. Secret, set it from the CSRF_SECRET environment variable,
+	// required outside development where a missing one is generated per process
+	Secret string
+	// Token lifetime in seconds
+	TTL int
+	// Also set the token in a cookie the header must match
+	DoubleSubmit bool
+	CookieName   string
+}
+
+// CORS config, empty lists take the middleware defaults
+type CORS struct {
+	// Allowed origins, * allows any and https://*.example.com the subdomains of example.com
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	// Preflight cache in seconds
+	MaxAge int
+}
+
+// Security response headers config, empty values leave a header out
+type SecurityHeaders struct {
+	// HSTS max-age in seconds, sent on https requests only
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	ContentSecurityPolicy string
+	ContentTypeNosniff    bool
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+}
+
+// Admin listener config, serves pprof, expvar, build info, the config and the log level
+type Admin struct {
+	Enabled bool
+	Addr    string
+	// Bearer token of the admin requests, required unless Addr binds a loopback address only.
+	// Secret, set it from the ADMIN_TOKEN environment variable rather than the config file.
+	Token string
+}
+
+// Background jobs config, intervals in seconds, zero disables a job
+type Jobs struct {
+	AvatarReconcileInterval int
+	AvatarReconcileDelete   bool
+	AvatarOrphanMinAge      int
+	AccountPurgeInterval    int
+	AccountRestoreWindow    int
+	SessionCleanupInterval  int
+}
+
 // Load config file from given path
 func LoadConfig(filename string) (*viper.Viper, error) {
 	v := viper.New()
 
 	v.SetConfigName(filename)
 	v.AddConfigPath(".")
+	// Keys of the file are overridden by their environment variables, like ADMIN_TOKEN for admin.Token
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
@@ -3,14 +3,18 @@ package auth
 
 import (
 	"context"
+	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/internal/models"
-	"github.com/minio/minio-go/v7"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 )
 
-// Minio AWS S3 interface
+// Object storage interface
 type AWSRepository interface {
-	PutObject(ctx context.Context, input models.UploadInput) (*minio.UploadInfo, error)
-	GetObject(ctx context.Context, bucket string, fileName string) (*minio.Object, error)
+	PutObject(ctx context.Context, input models.UploadInput) (*storage.ObjectInfo, error)
+	GetObject(ctx context.Context, bucket string, fileName string) (*storage.Object, error)
 	RemoveObject(ctx context.Context, bucket string, fileName string) error
+	ListObjects(ctx context.Context, bucket string, prefix string) ([]storage.ObjectInfo, error)
+	GetObjectURL(bucket string, fileName string) string
+	PresignPutObject(ctx context.Context, input models.UploadInput, expires time.Duration) (*storage.PresignedRequest, error)
 }
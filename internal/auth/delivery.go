@@ -10,6 +10,14 @@ type Handlers interface {
 	FindByName() echo.HandlerFunc
 	GetUsers() echo.HandlerFunc
 	GetUserByID() echo.HandlerFunc
+	PatchUser() echo.HandlerFunc
 	GetCSRFToken() echo.HandlerFunc
 	UploadAvatar() echo.HandlerFunc
+	PresignAvatar() echo.HandlerFunc
+	ConfirmAvatar() echo.HandlerFunc
+	GetAvatar() echo.HandlerFunc
+	DeleteAvatar() echo.HandlerFunc
+	DeactivateUser() echo.HandlerFunc
+	DeleteUser() echo.HandlerFunc
+	RestoreUser() echo.HandlerFunc
 }
@@ -2,10 +2,13 @@ package http
 
 import (
 	"bytes"
-	"context"
+	"encoding/json"
 	"errors"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/config"
 	"github.com/fekuna/go-rest-clean-architecture/internal/auth"
@@ -14,22 +17,40 @@ import (
 	"github.com/fekuna/go-rest-clean-architecture/pkg/csrf"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	maxAvatarSize = 10 << 20 // 10MB
+
+	// Versioned avatar urls change on every upload, unversioned ones must revalidate
+	avatarVersionedCacheControl = "public, max-age=31536000, immutable"
+	avatarCacheControl          = "public, no-cache"
+
+	mergePatchMIME = "application/merge-patch+json"
+)
+
 // Auth handlers
 type authHandlers struct {
 	cfg    *config.Config
 	authUC auth.UseCase
 	sessUC session.UCSession
+	csrf   *csrf.Signer
 	logger logger.Logger
 }
 
 // NewAuthHandlers Auth handlers constructor
 func NewAuthHandlers(cfg *config.Config, authUC auth.UseCase, sessUC session.UCSession, log logger.Logger) auth.Handlers {
-	return &authHandlers{cfg: cfg, authUC: authUC, sessUC: sessUC, logger: log}
+	return &authHandlers{
+		cfg:    cfg,
+		authUC: authUC,
+		sessUC: sessUC,
+		csrf:   csrf.NewSigner([]byte(cfg.CSRF.Secret), time.Duration(cfg.CSRF.TTL)*time.Second),
+		logger: log,
+	}
 }
 
 // Register godoc
@@ -50,22 +71,21 @@ func (h *authHandlers) Register() echo.HandlerFunc {
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		ctx := context.Background()
+		ctx := c.Request().Context()
 		createdUser, err := h.authUC.Register(ctx, user)
 		if err != nil {
 			utils.LogResponseError(c, h.logger, err)
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		sess, err := h.sessUC.CreateSession(ctx, &models.Session{
-			UserID: createdUser.User.UserID,
-		}, h.cfg.Session.Expire)
+		sess := &models.Session{UserID: createdUser.User.UserID}
+		token, err := h.sessUC.CreateSession(ctx, sess)
 		if err != nil {
 			utils.LogResponseError(c, h.logger, err)
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		c.SetCookie(utils.CreateSessionCookie(h.cfg, sess))
+		c.SetCookie(utils.CreateSessionCookie(h.cfg, token, sess.ExpiresAt))
 
 		return c.JSON(http.StatusCreated, createdUser)
 	}
@@ -73,7 +93,8 @@ func (h *authHandlers) Register() echo.HandlerFunc {
 
 // GetCSRFToken godoc
 // @Summary Get CSRF token
-// @Description Get CSRF token, required auth session cookie
+// @Description Get CSRF token of the session in the X-CSRF-TOKEN header, required auth session cookie.
+// @Description The token expires and changes with the session, it is also set as a cookie on double submit.
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -89,9 +110,23 @@ func (h *authHandlers) GetCSRFToken() echo.HandlerFunc {
 			utils.LogResponseError(c, h.logger, httpErrors.NewUnauthorizedError(httpErrors.Unauthorized))
 			return utils.ErrResponseWithLog(c, h.logger, httpErrors.NewUnauthorizedError(httpErrors.Unauthorized))
 		}
-		token := csrf.MakeToken(sid, h.logger)
+		token, err := h.csrf.MakeToken(sid)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(http.StatusInternalServerError, httpErrors.NewInternalServerError(err))
+		}
+
+		if h.cfg.CSRF.DoubleSubmit {
+			c.SetCookie(&http.Cookie{
+				Name:     h.cfg.CSRF.CookieName,
+				Value:    token,
+				Path:     "/",
+				MaxAge:   int(h.csrf.TTL().Seconds()),
+				Secure:   h.cfg.Cookie.Secure,
+				SameSite: utils.SameSite(h.cfg.Cookie.SameSite),
+			})
+		}
 		c.Response().Header().Set(csrf.CSRFHeader, token)
-		c.Response().Header().Set("Access-Control-Expose-Headers", csrf.CSRFHeader)
 
 		return c.NoContent(http.StatusOK)
 	}
@@ -99,7 +134,7 @@ func (h *authHandlers) GetCSRFToken() echo.HandlerFunc {
 
 // Login godoc
 // @Summary Login new user
-// @Description login user, returns user and set session
+// @Description login user, returns user and set session, remember_me keeps the session longer
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -109,6 +144,8 @@ func (h *authHandlers) Login() echo.HandlerFunc {
 	type Login struct {
 		Email    string `json:"email" db:"email" validate:"omitempty,lte=60,email"`
 		Password string `json:"password,omitempty" db:"password" validate:"required,gte=6"`
+		// Longer lived session
+		RememberMe bool `json:"remember_me"`
 	}
 	return func(c echo.Context) error {
 		// TODO: Open tracing
@@ -119,7 +156,7 @@ func (h *authHandlers) Login() echo.HandlerFunc {
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		ctx := context.Background()
+		ctx := c.Request().Context()
 
 		userWithToken, err := h.authUC.Login(ctx, &models.User{
 			Email:    login.Email,
@@ -130,15 +167,21 @@ func (h *authHandlers) Login() echo.HandlerFunc {
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		sess, err := h.sessUC.CreateSession(ctx, &models.Session{
-			UserID: userWithToken.User.UserID,
-		}, h.cfg.Session.Expire)
+		// A session from before the login is never carried over, so a planted cookie can't be fixed on the user
+		if cookie, err := c.Cookie(h.cfg.Session.Name); err == nil && cookie.Value != "" {
+			if err = h.sessUC.DeleteByID(ctx, cookie.Value); err != nil {
+				utils.LogResponseError(c, h.logger, err)
+			}
+		}
+
+		sess := &models.Session{UserID: userWithToken.User.UserID, RememberMe: login.RememberMe}
+		token, err := h.sessUC.CreateSession(ctx, sess)
 		if err != nil {
 			utils.LogResponseError(c, h.logger, err)
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		c.SetCookie(utils.CreateSessionCookie(h.cfg, sess))
+		c.SetCookie(utils.CreateSessionCookie(h.cfg, token, sess.ExpiresAt))
 
 		return c.JSON(http.StatusOK, userWithToken)
 	}
@@ -166,7 +209,7 @@ func (h *authHandlers) Logout() echo.HandlerFunc {
 			return c.JSON(http.StatusInternalServerError, httpErrors.NewInternalServerError(err))
 		}
 
-		ctx := context.Background()
+		ctx := c.Request().Context()
 
 		if err := h.sessUC.DeleteByID(ctx, cookie.Value); err != nil {
 			utils.LogResponseError(c, h.logger, err)
@@ -184,9 +227,15 @@ func (h *authHandlers) Logout() echo.HandlerFunc {
 // @Description Find user by name
 // @Tags Auth
 // @Accept json
-// @Param name query string false "username" Format(username)
+// @Param name query string false "name, email or city to search for" Format(username)
+// @Param mode query string false "match mode: fuzzy (default), prefix or exact"
+// @Param after query string false "cursor of the page after, from next_cursor"
+// @Param before query string false "cursor of the page before, from prev_cursor"
+// @Param limit query int false "cursor page size"
+// @Param include_total query bool false "count total with cursor pagination"
+// @Param fields query string false "comma separated fields to return, like first_name,last_name,avatar"
 // @Produce json
-// @Success 200 {object} models.UsersList
+// @Success 200 {object} models.UserSearchProjectionList
 // @Failure 500 {object} httpErrors.RestError
 // @Router /auth/find [get]
 func (h *authHandlers) FindByName() echo.HandlerFunc {
@@ -203,14 +252,30 @@ func (h *authHandlers) FindByName() echo.HandlerFunc {
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		ctx := context.Background()
-		response, err := h.authUC.FindByName(ctx, c.QueryParam("name"), paginationQuery)
+		fields, err := utils.GetUserFieldsFromCtx(c)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		ctx := c.Request().Context()
+		response, err := h.authUC.FindByName(ctx, &models.UserSearch{Query: c.QueryParam("name"), Mode: c.QueryParam("mode")}, paginationQuery)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		utils.SetPaginationLinks(c, paginationQuery, &response.Pagination)
+
+		viewer := h.viewer(c)
+		projected, err := response.Project(viewer, fields)
 		if err != nil {
 			utils.LogResponseError(c, h.logger, err)
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		return c.JSON(http.StatusOK, response)
+		setProjectionHeaders(c, viewer)
+		return c.JSON(http.StatusOK, projected)
 	}
 }
 
@@ -221,9 +286,21 @@ func (h *authHandlers) FindByName() echo.HandlerFunc {
 // @Accept json
 // @Param page query int false "page number" Format(page)
 // @Param size query int false "number of elements per page" Format(size)
-// @Param orderBy query int false "filter name" Format(orderBy)
+// @Param orderBy query string false "sort fields, like created_at:desc,last_name:asc" Format(orderBy)
+// @Param role query string false "role"
+// @Param city query string false "city"
+// @Param country query string false "country"
+// @Param gender query string false "gender"
+// @Param created_after query string false "RFC 3339 timestamp or date"
+// @Param created_before query string false "RFC 3339 timestamp or date"
+// @Param has_avatar query bool false "has avatar"
+// @Param after query string false "cursor of the page after, from next_cursor"
+// @Param before query string false "cursor of the page before, from prev_cursor"
+// @Param limit query int false "cursor page size"
+// @Param include_total query bool false "count total with cursor pagination"
+// @Param fields query string false "comma separated fields to return, like first_name,last_name,avatar"
 // @Produce json
-// @Success 200 {object} models.UsersList
+// @Success 200 {object} models.UsersProjectionList
 // @Failure 500 {object} httpErrors.RestError
 // @Router /auth/all [get]
 func (h *authHandlers) GetUsers() echo.HandlerFunc {
@@ -236,14 +313,36 @@ func (h *authHandlers) GetUsers() echo.HandlerFunc {
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		ctx := context.Background()
-		usersList, err := h.authUC.GetUsers(ctx, paginationQuery)
+		filter, err := utils.GetUserFilterFromCtx(c)
 		if err != nil {
 			utils.LogResponseError(c, h.logger, err)
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		return c.JSON(http.StatusOK, usersList)
+		fields, err := utils.GetUserFieldsFromCtx(c)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		ctx := c.Request().Context()
+		usersList, err := h.authUC.GetUsers(ctx, filter, paginationQuery)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		utils.SetPaginationLinks(c, paginationQuery, &usersList.Pagination)
+
+		viewer := h.viewer(c)
+		projected, err := usersList.Project(viewer, fields)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		setProjectionHeaders(c, viewer)
+		return c.JSON(http.StatusOK, projected)
 	}
 }
 
@@ -254,7 +353,10 @@ func (h *authHandlers) GetUsers() echo.HandlerFunc {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "user_id"
-// @Success 200 {object} models.User
+// @Param fields query string false "comma separated fields to return, like first_name,last_name,avatar"
+// @Param If-None-Match header string false "entity tag of a cached copy"
+// @Success 200 {object} models.UserProjection
+// @Success 304 {string} string "not modified"
 // @Failure 500 {object} httpErrors.RestError
 // @Router /auth/{id} [get]
 func (h *authHandlers) GetUserByID() echo.HandlerFunc {
@@ -267,25 +369,117 @@ func (h *authHandlers) GetUserByID() echo.HandlerFunc {
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		ctx := context.Background()
+		fields, err := utils.GetUserFieldsFromCtx(c)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		ctx := c.Request().Context()
 		user, err := h.authUC.GetByID(ctx, uID)
 		if err != nil {
 			utils.LogResponseError(c, h.logger, err)
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		return c.JSON(http.StatusOK, user)
+		viewer := h.viewer(c)
+		setProjectionHeaders(c, viewer)
+		c.Response().Header().Set("ETag", user.ETag())
+		if utils.MatchWeakETag(c.Request().Header.Get("If-None-Match"), user.ETag()) {
+			return c.NoContent(http.StatusNotModified)
+		}
+
+		projection, err := user.Project(models.UserVisibility(viewer, user.UserID), fields)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, projection)
+	}
+}
+
+// PatchUser godoc
+// @Summary Patch user
+// @Description Update user fields with a JSON merge patch (RFC 7396), null clears a field
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user_id"
+// @Param If-Match header string true "entity tag from GET /auth/{id}"
+// @Success 200 {object} models.User
+// @Failure 400 {object} httpErrors.RestError
+// @Failure 403 {object} httpErrors.RestError
+// @Failure 412 {object} httpErrors.RestError
+// @Failure 428 {object} httpErrors.RestError
+// @Router /auth/{id} [patch]
+func (h *authHandlers) PatchUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// TODO: Open Tracing
+
+		uID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		if !h.canModifyUser(c, uID) {
+			utils.LogResponseError(c, h.logger, httpErrors.PermissionDenied)
+			return c.JSON(http.StatusForbidden, httpErrors.NewForbiddenError(httpErrors.PermissionDenied))
+		}
+
+		ifMatch := c.Request().Header.Get("If-Match")
+		if ifMatch == "" {
+			err := httpErrors.NewRestError(http.StatusPreconditionRequired, httpErrors.PreconditionRequired.Error(), nil)
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		contentType := c.Request().Header.Get(echo.HeaderContentType)
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != mergePatchMIME && mediaType != echo.MIMEApplicationJSON {
+			err := httpErrors.NewRestError(http.StatusUnsupportedMediaType, "content type must be "+mergePatchMIME, contentType)
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		patch, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		// Users may edit their profile but only admins change roles
+		var members map[string]json.RawMessage
+		if json.Unmarshal(patch, &members) == nil {
+			if _, ok := members["role"]; ok && !h.viewer(c).IsAdmin() {
+				utils.LogResponseError(c, h.logger, httpErrors.PermissionDenied)
+				return c.JSON(http.StatusForbidden, httpErrors.NewForbiddenError(httpErrors.PermissionDenied))
+			}
+		}
+
+		ctx := c.Request().Context()
+		updatedUser, err := h.authUC.Patch(ctx, uID, ifMatch, patch)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		if _, ok := members["role"]; ok {
+			h.rotateSessions(c, uID)
+		}
+
+		c.Response().Header().Set("ETag", updatedUser.ETag())
+		return c.JSON(http.StatusOK, updatedUser)
 	}
 }
 
 // UploadAvatar godoc
 // @Summary Post avatar
-// @Description Post user avatar image
+// @Description Post user avatar image, stored as 64, 256 and 512 pixel squares
 // @Tags Auth
 // @Accept  json
 // @Produce  json
 // @Param file formData file true "Body with image file"
-// @Param bucket query string true "aws s3 bucket" Format(bucket)
 // @Param id path int true "user_id"
 // @Success 200 {string} string	"ok"
 // @Failure 500 {object} httpErrors.RestError
@@ -294,17 +488,27 @@ func (h *authHandlers) UploadAvatar() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		// TODO: Open Tracing
 
-		bucket := c.QueryParam("bucket")
+		if c.QueryParam("bucket") != "" {
+			utils.LogResponseError(c, h.logger, httpErrors.BucketNotAllowed)
+			return c.JSON(http.StatusBadRequest, httpErrors.NewBadRequestError(httpErrors.BucketNotAllowed.Error()))
+		}
+
 		uID, err := uuid.Parse(c.Param("user_id"))
 		if err != nil {
 			utils.LogResponseError(c, h.logger, err)
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
+		if !h.canModifyUser(c, uID) {
+			utils.LogResponseError(c, h.logger, httpErrors.PermissionDenied)
+			return c.JSON(http.StatusForbidden, httpErrors.NewForbiddenError(httpErrors.PermissionDenied))
+		}
+
+		c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxAvatarSize)
 		image, err := utils.ReadImage(c, "file")
 		if err != nil {
 			utils.LogResponseError(c, h.logger, err)
-			return c.JSON(httpErrors.ErrorResponse(err))
+			return c.JSON(http.StatusBadRequest, httpErrors.NewBadRequestError(err.Error()))
 		}
 
 		file, err := image.Open()
@@ -328,13 +532,12 @@ func (h *authHandlers) UploadAvatar() echo.HandlerFunc {
 
 		reader := bytes.NewReader(binaryImage.Bytes())
 
-		ctx := context.Background()
+		ctx := c.Request().Context()
 		updatedUser, err := h.authUC.UploadAvatar(ctx, uID, models.UploadInput{
 			File:        reader,
 			Name:        image.Filename,
 			Size:        image.Size,
 			ContentType: contentType,
-			BucketName:  bucket,
 		})
 		if err != nil {
 			utils.LogResponseError(c, h.logger, err)
@@ -344,3 +547,356 @@ func (h *authHandlers) UploadAvatar() echo.HandlerFunc {
 		return c.JSON(http.StatusOK, updatedUser)
 	}
 }
+
+// PresignAvatar godoc
+// @Summary Presign avatar upload
+// @Description Get a presigned url to upload the avatar image directly to storage, the upload must be confirmed
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user_id"
+// @Success 200 {object} models.AvatarUpload
+// @Failure 400 {object} httpErrors.RestError
+// @Router /auth/{id}/avatar/presign [post]
+func (h *authHandlers) PresignAvatar() echo.HandlerFunc {
+	type PresignRequest struct {
+		ContentType string `json:"content_type" validate:"required"`
+		Size        int64  `json:"size" validate:"required,gt=0"`
+	}
+	return func(c echo.Context) error {
+		// TODO: Open Tracing
+
+		uID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		if !h.canModifyUser(c, uID) {
+			utils.LogResponseError(c, h.logger, httpErrors.PermissionDenied)
+			return c.JSON(http.StatusForbidden, httpErrors.NewForbiddenError(httpErrors.PermissionDenied))
+		}
+
+		presignRequest := &PresignRequest{}
+		if err = utils.ReadRequest(c, presignRequest); err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		ctx := c.Request().Context()
+		upload, err := h.authUC.PresignAvatar(ctx, uID, presignRequest.ContentType, presignRequest.Size)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, upload)
+	}
+}
+
+// ConfirmAvatar godoc
+// @Summary Confirm avatar upload
+// @Description Validate and process a presigned avatar upload, replaces the current avatar
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user_id"
+// @Success 200 {object} models.User
+// @Failure 404 {object} httpErrors.RestError
+// @Router /auth/{id}/avatar/confirm [post]
+func (h *authHandlers) ConfirmAvatar() echo.HandlerFunc {
+	type ConfirmRequest struct {
+		UploadID uuid.UUID `json:"upload_id" validate:"required"`
+	}
+	return func(c echo.Context) error {
+		// TODO: Open Tracing
+
+		uID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		if !h.canModifyUser(c, uID) {
+			utils.LogResponseError(c, h.logger, httpErrors.PermissionDenied)
+			return c.JSON(http.StatusForbidden, httpErrors.NewForbiddenError(httpErrors.PermissionDenied))
+		}
+
+		confirmRequest := &ConfirmRequest{}
+		if err = utils.ReadRequest(c, confirmRequest); err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		ctx := c.Request().Context()
+		updatedUser, err := h.authUC.ConfirmAvatar(ctx, uID, confirmRequest.UploadID)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, updatedUser)
+	}
+}
+
+// GetAvatar godoc
+// @Summary Get avatar
+// @Description Get user avatar image, supports ETag and Range requests
+// @Tags Auth
+// @Produce image/jpeg,image/png
+// @Param id path int true "user_id"
+// @Param size query int false "minimal image size in pixels" Format(size)
+// @Success 200 {file} file
+// @Failure 404 {object} httpErrors.RestError
+// @Router /auth/{id}/avatar [get]
+func (h *authHandlers) GetAvatar() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// TODO: Open Tracing
+
+		uID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		size := 0
+		if sizeQuery := c.QueryParam("size"); sizeQuery != "" {
+			if size, err = strconv.Atoi(sizeQuery); err != nil {
+				utils.LogResponseError(c, h.logger, err)
+				return c.JSON(http.StatusBadRequest, httpErrors.NewBadRequestError(httpErrors.BadQueryParams))
+			}
+		}
+
+		ctx := c.Request().Context()
+		object, err := h.authUC.GetAvatar(ctx, uID, size)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(storage.ErrorResponse(err))
+		}
+
+		cacheControl := avatarCacheControl
+		if c.QueryParam("v") != "" {
+			cacheControl = avatarVersionedCacheControl
+		}
+
+		return storage.ServeObject(c, object, cacheControl)
+	}
+}
+
+// DeleteAvatar godoc
+// @Summary Delete avatar
+// @Description Delete user avatar with all stored sizes
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user_id"
+// @Success 200 {object} models.User
+// @Failure 403 {object} httpErrors.RestError
+// @Router /auth/{id}/avatar [delete]
+func (h *authHandlers) DeleteAvatar() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// TODO: Open Tracing
+
+		uID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		if !h.canModifyUser(c, uID) {
+			utils.LogResponseError(c, h.logger, httpErrors.PermissionDenied)
+			return c.JSON(http.StatusForbidden, httpErrors.NewForbiddenError(httpErrors.PermissionDenied))
+		}
+
+		ctx := c.Request().Context()
+		updatedUser, err := h.authUC.DeleteAvatar(ctx, uID)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, updatedUser)
+	}
+}
+
+// DeactivateUser godoc
+// @Summary Deactivate user
+// @Description Deactivate user account, it can't sign in until restored
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user_id"
+// @Success 204 {string} string "no content"
+// @Failure 403 {object} httpErrors.RestError
+// @Router /auth/{id}/deactivate [post]
+func (h *authHandlers) DeactivateUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// TODO: Open Tracing
+
+		uID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		if !h.canModifyUser(c, uID) {
+			utils.LogResponseError(c, h.logger, httpErrors.PermissionDenied)
+			return c.JSON(http.StatusForbidden, httpErrors.NewForbiddenError(httpErrors.PermissionDenied))
+		}
+
+		ctx := c.Request().Context()
+		if err := h.authUC.Deactivate(ctx, uID); err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		h.endSessions(c, uID)
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// DeleteUser godoc
+// @Summary Delete user
+// @Description Soft delete user, it can be restored within the restore window and is anonymised after it
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user_id"
+// @Success 204 {string} string "no content"
+// @Failure 403 {object} httpErrors.RestError
+// @Router /auth/{id} [delete]
+func (h *authHandlers) DeleteUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// TODO: Open Tracing
+
+		uID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		if !h.canModifyUser(c, uID) {
+			utils.LogResponseError(c, h.logger, httpErrors.PermissionDenied)
+			return c.JSON(http.StatusForbidden, httpErrors.NewForbiddenError(httpErrors.PermissionDenied))
+		}
+
+		ctx := c.Request().Context()
+		if err := h.authUC.Delete(ctx, uID); err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		h.endSessions(c, uID)
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// RestoreUser godoc
+// @Summary Restore user
+// @Description Restore deactivated user, or deleted one within the restore window, with its credentials
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} models.User
+// @Failure 401 {object} httpErrors.RestError
+// @Failure 410 {object} httpErrors.RestError
+// @Router /auth/restore [post]
+func (h *authHandlers) RestoreUser() echo.HandlerFunc {
+	type Restore struct {
+		Email    string `json:"email" validate:"required,lte=60,email"`
+		Password string `json:"password" validate:"required,gte=6"`
+	}
+	return func(c echo.Context) error {
+		// TODO: Open Tracing
+
+		restore := &Restore{}
+		if err := utils.ReadRequest(c, restore); err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		ctx := c.Request().Context()
+		restoredUser, err := h.authUC.Restore(ctx, &models.User{
+			Email:    restore.Email,
+			Password: restore.Password,
+		})
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		return c.JSON(http.StatusOK, restoredUser)
+	}
+}
+
+// End every session of a deactivated or deleted user so restoring the account doesn't bring them back,
+// users ending their own account are signed out
+func (h *authHandlers) endSessions(c echo.Context, userID uuid.UUID) {
+	ctx := c.Request().Context()
+	if err := h.sessUC.DeleteUserSessions(ctx, userID, ""); err != nil {
+		utils.LogResponseError(c, h.logger, err)
+	}
+
+	viewer := h.viewer(c)
+	sid, ok := c.Get("sid").(string)
+	if viewer == nil || viewer.UserID != userID || !ok {
+		return
+	}
+
+	// Sessions of earlier versions are not listed by user
+	if err := h.sessUC.DeleteByID(ctx, sid); err != nil {
+		utils.LogResponseError(c, h.logger, err)
+	}
+	utils.DeleteSessionCookie(c, h.cfg.Session.Name)
+}
+
+// Sessions of a user whose privileges changed are not reused, the own session of the request moves to a new token
+// and the other sessions of the user are ended.
+// Login and role changes are the only privilege changes there are. Passwords can't change after register, they are
+// no patch field and updateUserQuery doesn't write them, and there is no second factor to complete. A password
+// change or 2FA step added later has to rotate here as well.
+func (h *authHandlers) rotateSessions(c echo.Context, userID uuid.UUID) {
+	ctx := c.Request().Context()
+
+	var keep string
+	viewer := h.viewer(c)
+	if sid, ok := c.Get("sid").(string); ok && viewer != nil && viewer.UserID == userID {
+		token, sess, err := h.sessUC.RotateSession(ctx, sid)
+		if err != nil {
+			utils.LogResponseError(c, h.logger, err)
+		} else {
+			keep = token
+			c.Set("sid", token)
+			c.SetCookie(utils.CreateSessionCookie(h.cfg, token, sess.ExpiresAt))
+		}
+	}
+
+	if err := h.sessUC.DeleteUserSessions(ctx, userID, keep); err != nil {
+		utils.LogResponseError(c, h.logger, err)
+	}
+}
+
+// Only the user itself or an admin may modify a user
+func (h *authHandlers) canModifyUser(c echo.Context, userID uuid.UUID) bool {
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return false
+	}
+	return user.UserID == userID || user.IsAdmin()
+}
+
+// Signed in user of the request, nil for anonymous requests
+func (h *authHandlers) viewer(c echo.Context) *models.User {
+	user, _ := c.Get("user").(*models.User)
+	return user
+}
+
+// Projected responses depend on the session, shared caches must not store the private ones
+func setProjectionHeaders(c echo.Context, viewer *models.User) {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderCookie)
+	if viewer != nil {
+		c.Response().Header().Set("Cache-Control", "private")
+	}
+}
@@ -2,10 +2,12 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/config"
 	"github.com/fekuna/go-rest-clean-architecture/internal/auth/mock"
@@ -13,6 +15,8 @@ import (
 	mockSess "github.com/fekuna/go-rest-clean-architecture/internal/session/mock"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/converter"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/query"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -78,7 +82,7 @@ func TestAuthHandlers_Register(t *testing.T) {
 	session := "session"
 
 	mockAuthUC.EXPECT().Register(context.Background(), gomock.Eq(user)).Return(userWithToken, nil)
-	mockSessUC.EXPECT().CreateSession(context.Background(), gomock.Eq(sess), 10).Return(session, nil)
+	mockSessUC.EXPECT().CreateSession(context.Background(), gomock.Eq(sess)).Return(session, nil)
 
 	err = handlerFunc(c)
 	require.NoError(t, err)
@@ -148,11 +152,28 @@ func TestAuthHandlers_Login(t *testing.T) {
 	session := "session"
 
 	mockAuthUC.EXPECT().Login(context.Background(), gomock.Eq(user)).Return(userWithToken, nil)
-	mockSessUC.EXPECT().CreateSession(context.Background(), gomock.Eq(sess), 10).Return(session, nil)
+	mockSessUC.EXPECT().CreateSession(context.Background(), gomock.Eq(sess)).Return(session, nil)
 
 	err = handlerFunc(c)
 	require.NoError(t, err)
 	require.Nil(t, err)
+
+	t.Run("Session from before login", func(t *testing.T) {
+		cfg.Session.Name = "session-id"
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(buf.String()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.AddCookie(&http.Cookie{Name: cfg.Session.Name, Value: "planted"})
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockAuthUC.EXPECT().Login(context.Background(), gomock.Eq(user)).Return(userWithToken, nil)
+		mockSessUC.EXPECT().DeleteByID(context.Background(), "planted").Return(nil)
+		mockSessUC.EXPECT().CreateSession(context.Background(), gomock.Eq(sess)).Return(session, nil)
+
+		require.NoError(t, handlerFunc(c))
+		require.Contains(t, rec.Header().Get(echo.HeaderSetCookie), "session-id="+session)
+	})
 }
 
 func TestAuthHandlers_Logout(t *testing.T) {
@@ -202,3 +223,269 @@ func TestAuthHandlers_Logout(t *testing.T) {
 	require.NoError(t, err)
 	require.Nil(t, err)
 }
+
+func TestAuthHandlers_DeactivateUser(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAuthUC := mock.NewMockUseCase(ctrl)
+	mockSessUC := mockSess.NewMockUCSession(ctrl)
+
+	cfg := &config.Config{
+		Session: config.Session{
+			Name: "session-id",
+		},
+		Logger: config.Logger{
+			Development: true,
+		},
+	}
+
+	apiLogger := logger.NewApiLogger(cfg)
+	authHandlers := NewAuthHandlers(cfg, mockAuthUC, mockSessUC, apiLogger)
+
+	deactivate := func(userID uuid.UUID, viewer *models.User) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/"+userID.String()+"/deactivate", nil)
+		rec := httptest.NewRecorder()
+
+		c := e.NewContext(req, rec)
+		c.SetParamNames("user_id")
+		c.SetParamValues(userID.String())
+		c.Set("user", viewer)
+		c.Set("sid", "token")
+
+		require.NoError(t, authHandlers.DeactivateUser()(c))
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		return rec
+	}
+
+	t.Run("By admin", func(t *testing.T) {
+		role := "admin"
+		admin := &models.User{UserID: uuid.New(), Role: &role}
+		userID := uuid.New()
+
+		mockAuthUC.EXPECT().Deactivate(context.Background(), userID).Return(nil)
+		// Sessions of the user end too, not only the one of the request
+		mockSessUC.EXPECT().DeleteUserSessions(context.Background(), userID, "").Return(nil)
+
+		rec := deactivate(userID, admin)
+		require.Empty(t, rec.Header().Get(echo.HeaderSetCookie))
+	})
+
+	t.Run("Own account", func(t *testing.T) {
+		user := &models.User{UserID: uuid.New()}
+
+		mockAuthUC.EXPECT().Deactivate(context.Background(), user.UserID).Return(nil)
+		mockSessUC.EXPECT().DeleteUserSessions(context.Background(), user.UserID, "").Return(nil)
+		mockSessUC.EXPECT().DeleteByID(context.Background(), "token").Return(nil)
+
+		rec := deactivate(user.UserID, user)
+		require.Contains(t, rec.Header().Get(echo.HeaderSetCookie), "session-id=;")
+	})
+}
+
+func TestAuthHandlers_FindByName(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAuthUC := mock.NewMockUseCase(ctrl)
+	mockSessUC := mockSess.NewMockUCSession(ctrl)
+
+	cfg := &config.Config{
+		Logger: config.Logger{
+			Development: true,
+		},
+	}
+
+	apiLogger := logger.NewApiLogger(cfg)
+	authHandlers := NewAuthHandlers(cfg, mockAuthUC, mockSessUC, apiLogger)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/find?name=email", nil)
+	rec := httptest.NewRecorder()
+
+	c := e.NewContext(req, rec)
+
+	role := "admin"
+	phone := "+62811"
+	address := "Street 1"
+	pq := &utils.PaginationQuery{Size: 10, Page: 1}
+	searchList := &models.UserSearchList{
+		Pagination: utils.GetPagination(1, pq),
+		Users: []*models.UserSearchHit{{
+			User: models.User{
+				UserID:      uuid.New(),
+				FirstName:   "FirstName",
+				LastName:    "LastName",
+				Email:       "email@gmail.com",
+				Role:        &role,
+				PhoneNumber: &phone,
+				Address:     &address,
+				LoginDate:   time.Now(),
+			},
+			Rank:      0.5,
+			Highlight: "<mark>FirstName</mark> LastName",
+		}},
+	}
+
+	mockAuthUC.EXPECT().FindByName(context.Background(), gomock.Eq(&models.UserSearch{Query: "email"}), gomock.Any()).Return(searchList, nil)
+
+	err := authHandlers.FindByName()(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, echo.HeaderCookie, rec.Header().Get(echo.HeaderVary))
+	require.Empty(t, rec.Header().Get("Cache-Control"))
+
+	var body struct {
+		Users []map[string]interface{} `json:"users"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Users, 1)
+
+	hit := body.Users[0]
+	require.Equal(t, "FirstName", hit["first_name"])
+	require.Equal(t, "<mark>FirstName</mark> LastName", hit["highlight"])
+	require.Contains(t, hit, "rank")
+	for _, field := range []string{"email", "phone_number", "address", "role", "login_date", "password"} {
+		require.NotContains(t, hit, field)
+	}
+}
+
+func TestAuthHandlers_GetUsers(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAuthUC := mock.NewMockUseCase(ctrl)
+	mockSessUC := mockSess.NewMockUCSession(ctrl)
+
+	cfg := &config.Config{
+		Logger: config.Logger{
+			Development: true,
+		},
+	}
+
+	apiLogger := logger.NewApiLogger(cfg)
+	authHandlers := NewAuthHandlers(cfg, mockAuthUC, mockSessUC, apiLogger)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/all?page=2&size=10&role=admin", nil)
+	rec := httptest.NewRecorder()
+
+	c := e.NewContext(req, rec)
+	// TODO: Open Tracing
+
+	role := "admin"
+	pq := &utils.PaginationQuery{Size: 10, Page: 2}
+	filter := &models.UserFilter{Role: &role, Sort: query.Sort{}}
+	usersList := &models.UsersList{
+		Pagination: utils.GetPagination(35, pq),
+		Users:      make([]*models.User, 0),
+	}
+
+	mockAuthUC.EXPECT().GetUsers(context.Background(), gomock.Eq(filter), gomock.Eq(pq)).Return(usersList, nil)
+
+	err := authHandlers.GetUsers()(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	base := "http://example.com/api/v1/auth/all?"
+	require.Equal(t, base+"page=3&role=admin&size=10", usersList.Links.Next)
+	require.Equal(t, base+"page=1&role=admin&size=10", usersList.Links.Prev)
+	require.Equal(t, base+"page=4&role=admin&size=10", usersList.Links.Last)
+	require.Contains(t, rec.Header().Get("Link"), `<`+base+`page=3&role=admin&size=10>; rel="next"`)
+}
+
+func TestAuthHandlers_GetUserByID(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAuthUC := mock.NewMockUseCase(ctrl)
+	mockSessUC := mockSess.NewMockUCSession(ctrl)
+
+	cfg := &config.Config{
+		Logger: config.Logger{
+			Development: true,
+		},
+	}
+
+	apiLogger := logger.NewApiLogger(cfg)
+	authHandlers := NewAuthHandlers(cfg, mockAuthUC, mockSessUC, apiLogger)
+
+	phone := "+62811"
+	user := &models.User{
+		UserID:      uuid.New(),
+		FirstName:   "FirstName",
+		LastName:    "LastName",
+		Email:       "email@gmail.com",
+		PhoneNumber: &phone,
+		Version:     3,
+	}
+
+	get := func(t *testing.T, target string, viewer *models.User) map[string]interface{} {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+
+		c := e.NewContext(req, rec)
+		c.SetParamNames("user_id")
+		c.SetParamValues(user.UserID.String())
+		if viewer != nil {
+			c.Set("user", viewer)
+		}
+
+		mockAuthUC.EXPECT().GetByID(context.Background(), user.UserID).Return(user, nil)
+
+		err := authHandlers.GetUserByID()(c)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, echo.HeaderCookie, rec.Header().Get(echo.HeaderVary))
+
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body
+	}
+
+	t.Run("Public", func(t *testing.T) {
+		body := get(t, "/api/v1/auth/"+user.UserID.String(), nil)
+		require.Equal(t, "FirstName", body["first_name"])
+		require.NotContains(t, body, "email")
+		require.NotContains(t, body, "phone_number")
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		body := get(t, "/api/v1/auth/"+user.UserID.String(), user)
+		require.Equal(t, "email@gmail.com", body["email"])
+		require.NotContains(t, body, "login_date")
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		body := get(t, "/api/v1/auth/"+user.UserID.String()+"?fields=first_name,email", nil)
+		require.Equal(t, map[string]interface{}{"first_name": "FirstName"}, body)
+	})
+
+	t.Run("Not modified", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/"+user.UserID.String(), nil)
+		req.Header.Set("If-None-Match", `W/"3"`)
+		rec := httptest.NewRecorder()
+
+		c := e.NewContext(req, rec)
+		c.SetParamNames("user_id")
+		c.SetParamValues(user.UserID.String())
+
+		mockAuthUC.EXPECT().GetByID(context.Background(), user.UserID).Return(user, nil)
+
+		err := authHandlers.GetUserByID()(c)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotModified, rec.Code)
+		require.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	})
+}
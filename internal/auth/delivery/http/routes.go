@@ -9,12 +9,21 @@ import (
 func MapAuthRoutes(authGroup *echo.Group, h auth.Handlers, mw *middleware.MiddlewareManager) {
 	authGroup.POST("/register", h.Register())
 	authGroup.POST("/login", h.Login())
-	authGroup.POST("/logout", h.Logout())
-	authGroup.GET("/find", h.FindByName())
-	authGroup.GET("/all", h.GetUsers())
-	authGroup.GET("/:user_id", h.GetUserByID())
+	authGroup.POST("/restore", h.RestoreUser())
+	authGroup.GET("/find", h.FindByName(), mw.OptionalAuthSessionMiddleware)
+	authGroup.GET("/all", h.GetUsers(), mw.OptionalAuthSessionMiddleware)
+	authGroup.GET("/:user_id", h.GetUserByID(), mw.OptionalAuthSessionMiddleware)
+	authGroup.GET("/:user_id/avatar", h.GetAvatar())
 	// authGroup.Use(middleware.AuthJWTMiddleware(authUC, cfg))
 	authGroup.Use(mw.AuthSessionMiddleware)
+	authGroup.Use(mw.CSRF)
+	authGroup.POST("/logout", h.Logout())
 	authGroup.GET("/token", h.GetCSRFToken())
+	authGroup.PATCH("/:user_id", h.PatchUser())
+	authGroup.DELETE("/:user_id", h.DeleteUser())
+	authGroup.POST("/:user_id/deactivate", h.DeactivateUser())
 	authGroup.POST("/:user_id/avatar", h.UploadAvatar())
+	authGroup.DELETE("/:user_id/avatar", h.DeleteAvatar())
+	authGroup.POST("/:user_id/avatar/presign", h.PresignAvatar())
+	authGroup.POST("/:user_id/avatar/confirm", h.ConfirmAvatar())
 }
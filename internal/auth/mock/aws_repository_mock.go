@@ -7,10 +7,11 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/fekuna/go-rest-clean-architecture/internal/models"
+	storage "github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 	gomock "github.com/golang/mock/gomock"
-	minio "github.com/minio/minio-go/v7"
 )
 
 // MockAWSRepository is a mock of AWSRepository interface.
@@ -37,10 +38,10 @@ func (m *MockAWSRepository) EXPECT() *MockAWSRepositoryMockRecorder {
 }
 
 // GetObject mocks base method.
-func (m *MockAWSRepository) GetObject(ctx context.Context, bucket, fileName string) (*minio.Object, error) {
+func (m *MockAWSRepository) GetObject(ctx context.Context, bucket, fileName string) (*storage.Object, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetObject", ctx, bucket, fileName)
-	ret0, _ := ret[0].(*minio.Object)
+	ret0, _ := ret[0].(*storage.Object)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -51,11 +52,55 @@ func (mr *MockAWSRepositoryMockRecorder) GetObject(ctx, bucket, fileName interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetObject", reflect.TypeOf((*MockAWSRepository)(nil).GetObject), ctx, bucket, fileName)
 }
 
+// GetObjectURL mocks base method.
+func (m *MockAWSRepository) GetObjectURL(bucket, fileName string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetObjectURL", bucket, fileName)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetObjectURL indicates an expected call of GetObjectURL.
+func (mr *MockAWSRepositoryMockRecorder) GetObjectURL(bucket, fileName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetObjectURL", reflect.TypeOf((*MockAWSRepository)(nil).GetObjectURL), bucket, fileName)
+}
+
+// ListObjects mocks base method.
+func (m *MockAWSRepository) ListObjects(ctx context.Context, bucket, prefix string) ([]storage.ObjectInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListObjects", ctx, bucket, prefix)
+	ret0, _ := ret[0].([]storage.ObjectInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListObjects indicates an expected call of ListObjects.
+func (mr *MockAWSRepositoryMockRecorder) ListObjects(ctx, bucket, prefix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListObjects", reflect.TypeOf((*MockAWSRepository)(nil).ListObjects), ctx, bucket, prefix)
+}
+
+// PresignPutObject mocks base method.
+func (m *MockAWSRepository) PresignPutObject(ctx context.Context, input models.UploadInput, expires time.Duration) (*storage.PresignedRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PresignPutObject", ctx, input, expires)
+	ret0, _ := ret[0].(*storage.PresignedRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PresignPutObject indicates an expected call of PresignPutObject.
+func (mr *MockAWSRepositoryMockRecorder) PresignPutObject(ctx, input, expires interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PresignPutObject", reflect.TypeOf((*MockAWSRepository)(nil).PresignPutObject), ctx, input, expires)
+}
+
 // PutObject mocks base method.
-func (m *MockAWSRepository) PutObject(ctx context.Context, input models.UploadInput) (*minio.UploadInfo, error) {
+func (m *MockAWSRepository) PutObject(ctx context.Context, input models.UploadInput) (*storage.ObjectInfo, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "PutObject", ctx, input)
-	ret0, _ := ret[0].(*minio.UploadInfo)
+	ret0, _ := ret[0].(*storage.ObjectInfo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -7,6 +7,7 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/fekuna/go-rest-clean-architecture/internal/models"
 	utils "github.com/fekuna/go-rest-clean-architecture/pkg/utils"
@@ -37,6 +38,34 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// Deactivate mocks base method.
+func (m *MockRepository) Deactivate(ctx context.Context, userID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deactivate", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Deactivate indicates an expected call of Deactivate.
+func (mr *MockRepositoryMockRecorder) Deactivate(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deactivate", reflect.TypeOf((*MockRepository)(nil).Deactivate), ctx, userID)
+}
+
+// Delete mocks base method.
+func (m *MockRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockRepositoryMockRecorder) Delete(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepository)(nil).Delete), ctx, userID)
+}
+
 // FindByEmail mocks base method.
 func (m *MockRepository) FindByEmail(ctx context.Context, user *models.User) (*models.User, error) {
 	m.ctrl.T.Helper()
@@ -53,18 +82,48 @@ func (mr *MockRepositoryMockRecorder) FindByEmail(ctx, user interface{}) *gomock
 }
 
 // FindByName mocks base method.
-func (m *MockRepository) FindByName(ctx context.Context, name string, query *utils.PaginationQuery) (*models.UsersList, error) {
+func (m *MockRepository) FindByName(ctx context.Context, search *models.UserSearch, query *utils.PaginationQuery) (*models.UserSearchList, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByName", ctx, name, query)
-	ret0, _ := ret[0].(*models.UsersList)
+	ret := m.ctrl.Call(m, "FindByName", ctx, search, query)
+	ret0, _ := ret[0].(*models.UserSearchList)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindByName indicates an expected call of FindByName.
-func (mr *MockRepositoryMockRecorder) FindByName(ctx, name, query interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) FindByName(ctx, search, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByName", reflect.TypeOf((*MockRepository)(nil).FindByName), ctx, search, query)
+}
+
+// FindInactiveByEmail mocks base method.
+func (m *MockRepository) FindInactiveByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindInactiveByEmail", ctx, email)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindInactiveByEmail indicates an expected call of FindInactiveByEmail.
+func (mr *MockRepositoryMockRecorder) FindInactiveByEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindInactiveByEmail", reflect.TypeOf((*MockRepository)(nil).FindInactiveByEmail), ctx, email)
+}
+
+// GetAvatars mocks base method.
+func (m *MockRepository) GetAvatars(ctx context.Context) ([]models.AvatarVariants, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAvatars", ctx)
+	ret0, _ := ret[0].([]models.AvatarVariants)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAvatars indicates an expected call of GetAvatars.
+func (mr *MockRepositoryMockRecorder) GetAvatars(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByName", reflect.TypeOf((*MockRepository)(nil).FindByName), ctx, name, query)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAvatars", reflect.TypeOf((*MockRepository)(nil).GetAvatars), ctx)
 }
 
 // GetByID mocks base method.
@@ -83,18 +142,48 @@ func (mr *MockRepositoryMockRecorder) GetByID(ctx, userID interface{}) *gomock.C
 }
 
 // GetUsers mocks base method.
-func (m *MockRepository) GetUsers(ctx context.Context, pq *utils.PaginationQuery) (*models.UsersList, error) {
+func (m *MockRepository) GetUsers(ctx context.Context, filter *models.UserFilter, pq *utils.PaginationQuery) (*models.UsersList, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsers", ctx, pq)
+	ret := m.ctrl.Call(m, "GetUsers", ctx, filter, pq)
 	ret0, _ := ret[0].(*models.UsersList)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUsers indicates an expected call of GetUsers.
-func (mr *MockRepositoryMockRecorder) GetUsers(ctx, pq interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetUsers(ctx, filter, pq interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockRepository)(nil).GetUsers), ctx, pq)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockRepository)(nil).GetUsers), ctx, filter, pq)
+}
+
+// Patch mocks base method.
+func (m *MockRepository) Patch(ctx context.Context, userID uuid.UUID, version int, fields map[string]interface{}) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Patch", ctx, userID, version, fields)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Patch indicates an expected call of Patch.
+func (mr *MockRepositoryMockRecorder) Patch(ctx, userID, version, fields interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockRepository)(nil).Patch), ctx, userID, version, fields)
+}
+
+// PurgeDeleted mocks base method.
+func (m *MockRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", ctx, deletedBefore)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockRepositoryMockRecorder) PurgeDeleted(ctx, deletedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockRepository)(nil).PurgeDeleted), ctx, deletedBefore)
 }
 
 // Register mocks base method.
@@ -112,6 +201,21 @@ func (mr *MockRepositoryMockRecorder) Register(ctx, user interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockRepository)(nil).Register), ctx, user)
 }
 
+// Restore mocks base method.
+func (m *MockRepository) Restore(ctx context.Context, userID uuid.UUID, deletedAfter time.Time) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, userID, deletedAfter)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockRepositoryMockRecorder) Restore(ctx, userID, deletedAfter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockRepository)(nil).Restore), ctx, userID, deletedAfter)
+}
+
 // Update mocks base method.
 func (m *MockRepository) Update(ctx context.Context, user *models.User) (*models.User, error) {
 	m.ctrl.T.Helper()
@@ -126,3 +230,19 @@ func (mr *MockRepositoryMockRecorder) Update(ctx, user interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepository)(nil).Update), ctx, user)
 }
+
+// UpdateAvatar mocks base method.
+func (m *MockRepository) UpdateAvatar(ctx context.Context, userID uuid.UUID, avatar *string, avatars models.AvatarVariants) (*models.User, models.AvatarVariants, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAvatar", ctx, userID, avatar, avatars)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(models.AvatarVariants)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UpdateAvatar indicates an expected call of UpdateAvatar.
+func (mr *MockRepositoryMockRecorder) UpdateAvatar(ctx, userID, avatar, avatars interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAvatar", reflect.TypeOf((*MockRepository)(nil).UpdateAvatar), ctx, userID, avatar, avatars)
+}
@@ -64,6 +64,21 @@ func (mr *MockRedisRepositoryMockRecorder) GetByIDCtx(ctx, key interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIDCtx", reflect.TypeOf((*MockRedisRepository)(nil).GetByIDCtx), ctx, key)
 }
 
+// MigrateUsersCtx mocks base method.
+func (m *MockRedisRepository) MigrateUsersCtx(ctx context.Context, match string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateUsersCtx", ctx, match)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MigrateUsersCtx indicates an expected call of MigrateUsersCtx.
+func (mr *MockRedisRepositoryMockRecorder) MigrateUsersCtx(ctx, match interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateUsersCtx", reflect.TypeOf((*MockRedisRepository)(nil).MigrateUsersCtx), ctx, match)
+}
+
 // SetUserCtx mocks base method.
 func (m *MockRedisRepository) SetUserCtx(ctx context.Context, key string, seconds int, user *models.User) error {
 	m.ctrl.T.Helper()
@@ -9,6 +9,7 @@ import (
 	reflect "reflect"
 
 	models "github.com/fekuna/go-rest-clean-architecture/internal/models"
+	storage "github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 	utils "github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 	gomock "github.com/golang/mock/gomock"
 	uuid "github.com/google/uuid"
@@ -37,19 +38,92 @@ func (m *MockUseCase) EXPECT() *MockUseCaseMockRecorder {
 	return m.recorder
 }
 
+// ConfirmAvatar mocks base method.
+func (m *MockUseCase) ConfirmAvatar(ctx context.Context, userID, uploadID uuid.UUID) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmAvatar", ctx, userID, uploadID)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmAvatar indicates an expected call of ConfirmAvatar.
+func (mr *MockUseCaseMockRecorder) ConfirmAvatar(ctx, userID, uploadID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmAvatar", reflect.TypeOf((*MockUseCase)(nil).ConfirmAvatar), ctx, userID, uploadID)
+}
+
+// Deactivate mocks base method.
+func (m *MockUseCase) Deactivate(ctx context.Context, userID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deactivate", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Deactivate indicates an expected call of Deactivate.
+func (mr *MockUseCaseMockRecorder) Deactivate(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deactivate", reflect.TypeOf((*MockUseCase)(nil).Deactivate), ctx, userID)
+}
+
+// Delete mocks base method.
+func (m *MockUseCase) Delete(ctx context.Context, userID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockUseCaseMockRecorder) Delete(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUseCase)(nil).Delete), ctx, userID)
+}
+
+// DeleteAvatar mocks base method.
+func (m *MockUseCase) DeleteAvatar(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAvatar", ctx, userID)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteAvatar indicates an expected call of DeleteAvatar.
+func (mr *MockUseCaseMockRecorder) DeleteAvatar(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAvatar", reflect.TypeOf((*MockUseCase)(nil).DeleteAvatar), ctx, userID)
+}
+
 // FindByName mocks base method.
-func (m *MockUseCase) FindByName(ctx context.Context, name string, query *utils.PaginationQuery) (*models.UsersList, error) {
+func (m *MockUseCase) FindByName(ctx context.Context, search *models.UserSearch, query *utils.PaginationQuery) (*models.UserSearchList, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByName", ctx, name, query)
-	ret0, _ := ret[0].(*models.UsersList)
+	ret := m.ctrl.Call(m, "FindByName", ctx, search, query)
+	ret0, _ := ret[0].(*models.UserSearchList)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindByName indicates an expected call of FindByName.
-func (mr *MockUseCaseMockRecorder) FindByName(ctx, name, query interface{}) *gomock.Call {
+func (mr *MockUseCaseMockRecorder) FindByName(ctx, search, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByName", reflect.TypeOf((*MockUseCase)(nil).FindByName), ctx, search, query)
+}
+
+// GetAvatar mocks base method.
+func (m *MockUseCase) GetAvatar(ctx context.Context, userID uuid.UUID, size int) (*storage.Object, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAvatar", ctx, userID, size)
+	ret0, _ := ret[0].(*storage.Object)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAvatar indicates an expected call of GetAvatar.
+func (mr *MockUseCaseMockRecorder) GetAvatar(ctx, userID, size interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByName", reflect.TypeOf((*MockUseCase)(nil).FindByName), ctx, name, query)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAvatar", reflect.TypeOf((*MockUseCase)(nil).GetAvatar), ctx, userID, size)
 }
 
 // GetByID mocks base method.
@@ -68,18 +142,18 @@ func (mr *MockUseCaseMockRecorder) GetByID(ctx, userID interface{}) *gomock.Call
 }
 
 // GetUsers mocks base method.
-func (m *MockUseCase) GetUsers(ctx context.Context, pq *utils.PaginationQuery) (*models.UsersList, error) {
+func (m *MockUseCase) GetUsers(ctx context.Context, filter *models.UserFilter, pq *utils.PaginationQuery) (*models.UsersList, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsers", ctx, pq)
+	ret := m.ctrl.Call(m, "GetUsers", ctx, filter, pq)
 	ret0, _ := ret[0].(*models.UsersList)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUsers indicates an expected call of GetUsers.
-func (mr *MockUseCaseMockRecorder) GetUsers(ctx, pq interface{}) *gomock.Call {
+func (mr *MockUseCaseMockRecorder) GetUsers(ctx, filter, pq interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockUseCase)(nil).GetUsers), ctx, pq)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockUseCase)(nil).GetUsers), ctx, filter, pq)
 }
 
 // Login mocks base method.
@@ -97,6 +171,81 @@ func (mr *MockUseCaseMockRecorder) Login(ctx, user interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUseCase)(nil).Login), ctx, user)
 }
 
+// MigrateUserCache mocks base method.
+func (m *MockUseCase) MigrateUserCache(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateUserCache", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MigrateUserCache indicates an expected call of MigrateUserCache.
+func (mr *MockUseCaseMockRecorder) MigrateUserCache(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateUserCache", reflect.TypeOf((*MockUseCase)(nil).MigrateUserCache), ctx)
+}
+
+// Patch mocks base method.
+func (m *MockUseCase) Patch(ctx context.Context, userID uuid.UUID, ifMatch string, patch []byte) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Patch", ctx, userID, ifMatch, patch)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Patch indicates an expected call of Patch.
+func (mr *MockUseCaseMockRecorder) Patch(ctx, userID, ifMatch, patch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockUseCase)(nil).Patch), ctx, userID, ifMatch, patch)
+}
+
+// PresignAvatar mocks base method.
+func (m *MockUseCase) PresignAvatar(ctx context.Context, userID uuid.UUID, contentType string, size int64) (*models.AvatarUpload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PresignAvatar", ctx, userID, contentType, size)
+	ret0, _ := ret[0].(*models.AvatarUpload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PresignAvatar indicates an expected call of PresignAvatar.
+func (mr *MockUseCaseMockRecorder) PresignAvatar(ctx, userID, contentType, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PresignAvatar", reflect.TypeOf((*MockUseCase)(nil).PresignAvatar), ctx, userID, contentType, size)
+}
+
+// PurgeDeleted mocks base method.
+func (m *MockUseCase) PurgeDeleted(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockUseCaseMockRecorder) PurgeDeleted(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockUseCase)(nil).PurgeDeleted), ctx)
+}
+
+// ReconcileAvatars mocks base method.
+func (m *MockUseCase) ReconcileAvatars(ctx context.Context, remove bool) ([]storage.ObjectInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileAvatars", ctx, remove)
+	ret0, _ := ret[0].([]storage.ObjectInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReconcileAvatars indicates an expected call of ReconcileAvatars.
+func (mr *MockUseCaseMockRecorder) ReconcileAvatars(ctx, remove interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileAvatars", reflect.TypeOf((*MockUseCase)(nil).ReconcileAvatars), ctx, remove)
+}
+
 // Register mocks base method.
 func (m *MockUseCase) Register(ctx context.Context, user *models.User) (*models.UserWithToken, error) {
 	m.ctrl.T.Helper()
@@ -112,6 +261,21 @@ func (mr *MockUseCaseMockRecorder) Register(ctx, user interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockUseCase)(nil).Register), ctx, user)
 }
 
+// Restore mocks base method.
+func (m *MockUseCase) Restore(ctx context.Context, user *models.User) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, user)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockUseCaseMockRecorder) Restore(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockUseCase)(nil).Restore), ctx, user)
+}
+
 // UploadAvatar mocks base method.
 func (m *MockUseCase) UploadAvatar(ctx context.Context, userID uuid.UUID, file models.UploadInput) (*models.User, error) {
 	m.ctrl.T.Helper()
@@ -126,3 +290,17 @@ func (mr *MockUseCaseMockRecorder) UploadAvatar(ctx, userID, file interface{}) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadAvatar", reflect.TypeOf((*MockUseCase)(nil).UploadAvatar), ctx, userID, file)
 }
+
+// WithinTx mocks base method.
+func (m *MockUseCase) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithinTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithinTx indicates an expected call of WithinTx.
+func (mr *MockUseCaseMockRecorder) WithinTx(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithinTx", reflect.TypeOf((*MockUseCase)(nil).WithinTx), ctx, fn)
+}
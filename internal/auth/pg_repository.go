@@ -3,6 +3,7 @@ package auth
 
 import (
 	"context"
+	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/internal/models"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
@@ -13,8 +14,16 @@ import (
 type Repository interface {
 	Register(ctx context.Context, user *models.User) (*models.User, error)
 	Update(ctx context.Context, user *models.User) (*models.User, error)
+	Patch(ctx context.Context, userID uuid.UUID, version int, fields map[string]interface{}) (*models.User, error)
 	FindByEmail(ctx context.Context, user *models.User) (*models.User, error)
-	FindByName(ctx context.Context, name string, query *utils.PaginationQuery) (*models.UsersList, error)
-	GetUsers(ctx context.Context, pq *utils.PaginationQuery) (*models.UsersList, error)
+	FindByName(ctx context.Context, search *models.UserSearch, query *utils.PaginationQuery) (*models.UserSearchList, error)
+	GetUsers(ctx context.Context, filter *models.UserFilter, pq *utils.PaginationQuery) (*models.UsersList, error)
 	GetByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+	UpdateAvatar(ctx context.Context, userID uuid.UUID, avatar *string, avatars models.AvatarVariants) (*models.User, models.AvatarVariants, error)
+	GetAvatars(ctx context.Context) ([]models.AvatarVariants, error)
+	Deactivate(ctx context.Context, userID uuid.UUID) error
+	Delete(ctx context.Context, userID uuid.UUID) error
+	FindInactiveByEmail(ctx context.Context, email string) (*models.User, error)
+	Restore(ctx context.Context, userID uuid.UUID, deletedAfter time.Time) (*models.User, error)
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error)
 }
@@ -12,4 +12,5 @@ type RedisRepository interface {
 	GetByIDCtx(ctx context.Context, key string) (*models.User, error)
 	SetUserCtx(ctx context.Context, key string, seconds int, user *models.User) error
 	DeleteUserCtx(ctx context.Context, key string) error
+	MigrateUsersCtx(ctx context.Context, match string) (int, error)
 }
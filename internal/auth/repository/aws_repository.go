@@ -2,64 +2,80 @@ package repository
 
 import (
 	"context"
-	"fmt"
+	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/internal/auth"
 	"github.com/fekuna/go-rest-clean-architecture/internal/models"
-	"github.com/google/uuid"
-	"github.com/minio/minio-go/v7"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 	"github.com/pkg/errors"
 )
 
-// Auth AWS S3 repository
+// Auth object storage repository
 type authAWSRepository struct {
-	client *minio.Client
+	store storage.ObjectStore
 }
 
-// Auth AWS S3 repository constructor
-func NewAuthAWSRepository(awsClient *minio.Client) auth.AWSRepository {
-	return &authAWSRepository{client: awsClient}
+// Auth object storage repository constructor
+func NewAuthAWSRepository(store storage.ObjectStore) auth.AWSRepository {
+	return &authAWSRepository{store: store}
 }
 
-// Upload file to AWS
-func (aws *authAWSRepository) PutObject(ctx context.Context, input models.UploadInput) (*minio.UploadInfo, error) {
+// Upload file to object storage
+func (aws *authAWSRepository) PutObject(ctx context.Context, input models.UploadInput) (*storage.ObjectInfo, error) {
 	// TODO: Open Tracing
 
-	options := minio.PutObjectOptions{
-		ContentType:  input.ContentType,
-		UserMetadata: map[string]string{"x-amz-acl": "public-read"},
-	}
-
-	uploadInfo, err := aws.client.PutObject(ctx, input.BucketName, aws.generateFileName(input.Name), input.File, input.Size, options)
+	uploadInfo, err := aws.store.PutObject(ctx, input.BucketName, input.Name, input.File, input.Size, input.ContentType)
 	if err != nil {
 		return nil, errors.Wrap(err, "authAWSRepository.FileUpload.PutObject")
 	}
 
-	return &uploadInfo, err
+	return uploadInfo, err
 }
 
-// Download file from AWS
-func (aws *authAWSRepository) GetObject(ctx context.Context, bucket string, fileName string) (*minio.Object, error) {
+// Download file from object storage
+func (aws *authAWSRepository) GetObject(ctx context.Context, bucket string, fileName string) (*storage.Object, error) {
 	// TODO: Open Tracing
 
-	object, err := aws.client.GetObject(ctx, bucket, fileName, minio.GetObjectOptions{})
+	object, err := aws.store.GetObject(ctx, bucket, fileName)
 	if err != nil {
 		return nil, errors.Wrap(err, "authAWSRepository.FileDownload.GetObject")
 	}
 	return object, nil
 }
 
-// Delete file from AWS
+// Delete file from object storage
 func (aws *authAWSRepository) RemoveObject(ctx context.Context, bucket string, fileName string) error {
 	// TODO: Open Tracing
 
-	if err := aws.client.RemoveObject(ctx, bucket, fileName, minio.RemoveObjectOptions{}); err != nil {
+	if err := aws.store.RemoveObject(ctx, bucket, fileName); err != nil {
 		return errors.Wrap(err, "authAWSRepository.RemoveObject")
 	}
 	return nil
 }
 
-func (aws *authAWSRepository) generateFileName(fileName string) string {
-	uid := uuid.New().String()
-	return fmt.Sprintf("%s-%s", uid, fileName)
+// List files with the given name prefix
+func (aws *authAWSRepository) ListObjects(ctx context.Context, bucket string, prefix string) ([]storage.ObjectInfo, error) {
+	// TODO: Open Tracing
+
+	objects, err := aws.store.ListObjects(ctx, bucket, prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "authAWSRepository.ListObjects")
+	}
+	return objects, nil
+}
+
+// Presign a direct upload of a file with the given content type and size
+func (aws *authAWSRepository) PresignPutObject(ctx context.Context, input models.UploadInput, expires time.Duration) (*storage.PresignedRequest, error) {
+	// TODO: Open Tracing
+
+	presigned, err := aws.store.PresignPut(ctx, input.BucketName, input.Name, input.ContentType, input.Size, expires)
+	if err != nil {
+		return nil, errors.Wrap(err, "authAWSRepository.PresignPutObject")
+	}
+	return presigned, nil
+}
+
+// Get public file url
+func (aws *authAWSRepository) GetObjectURL(bucket string, fileName string) string {
+	return aws.store.ObjectURL(bucket, fileName)
 }
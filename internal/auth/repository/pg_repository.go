@@ -2,23 +2,38 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/fekuna/go-rest-clean-architecture/internal/auth"
 	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/query"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
+var (
+	defaultUserSort = query.Sort{{Field: "first_name", Column: "first_name"}, {Field: "last_name", Column: "last_name"}}
+	// user_id is unique, it keeps the order stable between equal values
+	userIDSort = query.SortField{Field: "user_id", Column: "user_id"}
+	searchSort = query.Sort{{Field: "rank", Column: "rank", Desc: true}, userIDSort}
+)
+
 // Auth Repository
 type authRepo struct {
-	db *sqlx.DB
+	db *postgres.Router
 }
 
 // Auth Repository constructor
-func NewAuthRepository(db *sqlx.DB) auth.Repository {
+func NewAuthRepository(db *postgres.Router) auth.Repository {
 	return &authRepo{db: db}
 }
 
@@ -27,7 +42,7 @@ func (r *authRepo) Register(ctx context.Context, user *models.User) (*models.Use
 	fmt.Printf("\n %v", &user)
 	// TODO: OPEN TRACING
 	u := &models.User{}
-	if err := r.db.QueryRowxContext(ctx, createUserQuery, &user.FirstName, &user.LastName, &user.Email,
+	if err := r.db.Writer(ctx).QueryRowxContext(ctx, createUserQuery, &user.FirstName, &user.LastName, &user.Email,
 		&user.Password, &user.Role, &user.About, &user.Avatar, &user.PhoneNumber, &user.Address, &user.City,
 		&user.Gender, &user.Postcode, &user.Birthday,
 	).StructScan(u); err != nil {
@@ -41,125 +56,400 @@ func (r *authRepo) Register(ctx context.Context, user *models.User) (*models.Use
 func (r *authRepo) FindByEmail(ctx context.Context, user *models.User) (*models.User, error) {
 	// TODO: OPEN TRACING
 	foundUser := &models.User{}
-	if err := r.db.QueryRowxContext(ctx, findUserByEmail, user.Email).StructScan(foundUser); err != nil {
+	if err := r.db.Reader(ctx).QueryRowxContext(ctx, findUserByEmail, user.Email).StructScan(foundUser); err != nil {
 		return nil, errors.Wrap(err, "authRepo.FindByEmail.QueryRowxContext")
 	}
 	return foundUser, nil
 }
 
-// Find users by name
-func (r *authRepo) FindByName(ctx context.Context, name string, query *utils.PaginationQuery) (*models.UsersList, error) {
+// Search users by name, email and city ordered by relevance
+func (r *authRepo) FindByName(ctx context.Context, search *models.UserSearch, query *utils.PaginationQuery) (*models.UserSearchList, error) {
 	// TODO: Open Tracing
+	selectQuery, countQuery, b := userSearchQuery(search)
+
+	if query.IsCursor() {
+		hits, pagination, err := selectPage(ctx, r.db.Reader(ctx), selectQuery, countQuery, b, searchSort, query, (*models.UserSearchHit).SortValue)
+		if err != nil {
+			return nil, errors.Wrap(err, "authRepo.FindByName.selectPage")
+		}
+		return &models.UserSearchList{Pagination: pagination, Users: hits}, nil
+	}
+
 	var totalCount int
-	if err := r.db.GetContext(ctx, &totalCount, getTotalCount, name); err != nil {
+	if err := r.db.Reader(ctx).GetContext(ctx, &totalCount, countQuery, b.Args()...); err != nil {
 		return nil, errors.Wrap(err, "authRepo.FindByName.GetContext.totalCount")
 	}
 
 	if totalCount == 0 {
-		return &models.UsersList{
-			TotalCount: totalCount,
-			TotalPages: utils.GetTotalPages(totalCount, query.GetSize()),
-			Page:       query.GetPage(),
-			Size:       query.GetSize(),
-			HasMore:    utils.GetHasMore(query.GetPage(), totalCount, query.GetSize()),
-			Users:      make([]*models.User, 0),
+		return &models.UserSearchList{
+			Pagination: utils.GetPagination(totalCount, query),
+			Users:      make([]*models.UserSearchHit, 0),
 		}, nil
 	}
 
-	rows, err := r.db.QueryxContext(ctx, findUsers, name, query.GetOffset(), query.GetLimit())
-	if err != nil {
-		return nil, errors.Wrap(err, "authRepo.FindByName.QueryxContext")
-	}
-	defer rows.Close()
-
-	var users = make([]*models.User, 0, query.GetSize())
-	for rows.Next() {
-		var user models.User
-		if err = rows.StructScan(&user); err != nil {
-			return nil, errors.Wrap(err, "authRepo.FindByName.StructScan")
-		}
-		users = append(users, &user)
-	}
+	hitsQuery := fmt.Sprintf("%s ORDER BY %s OFFSET %s LIMIT %s", selectQuery, searchSort.SQL(), b.Arg(query.GetOffset()), b.Arg(query.GetLimit()))
 
-	if err = rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "authRepo.FindByName.rows.Err")
+	var hits = make([]*models.UserSearchHit, 0, query.GetSize())
+	if err := r.db.Reader(ctx).SelectContext(ctx, &hits, hitsQuery, b.Args()...); err != nil {
+		return nil, errors.Wrap(err, "authRepo.FindByName.SelectContext")
 	}
 
-	return &models.UsersList{
-		TotalCount: totalCount,
-		TotalPages: utils.GetTotalPages(totalCount, query.GetSize()),
-		Page:       query.GetPage(),
-		Size:       query.GetSize(),
-		HasMore:    utils.GetHasMore(query.GetPage(), totalCount, query.GetSize()),
-		Users:      users,
+	return &models.UserSearchList{
+		Pagination: utils.GetPagination(totalCount, query),
+		Users:      hits,
 	}, nil
-
 }
 
-// Get users with pagination
-func (r *authRepo) GetUsers(ctx context.Context, pq *utils.PaginationQuery) (*models.UsersList, error) {
+// Get filtered and sorted users with pagination
+func (r *authRepo) GetUsers(ctx context.Context, filter *models.UserFilter, pq *utils.PaginationQuery) (*models.UsersList, error) {
 	// TODO: Open Tracing
 
+	if filter == nil {
+		filter = &models.UserFilter{}
+	}
+	b := userFilterQuery(filter)
+
+	sort := filter.Sort
+	if len(sort) == 0 {
+		sort = defaultUserSort
+	}
+	if pq.IsCursor() {
+		users, pagination, err := selectPage(ctx, r.db.Reader(ctx), getUsers, getTotal, b, sort.With(userIDSort), pq, (*models.User).SortValue)
+		if err != nil {
+			return nil, errors.Wrap(err, "authRepo.GetUsers.selectPage")
+		}
+		return &models.UsersList{Pagination: pagination, Users: users}, nil
+	}
+
 	var totalCount int
-	if err := r.db.GetContext(ctx, &totalCount, getTotal); err != nil {
+	if err := r.db.Reader(ctx).GetContext(ctx, &totalCount, getTotal+b.WhereSQL(), b.Args()...); err != nil {
 		return nil, errors.Wrap(err, "authRepo.GetUsers.GetContext.totalCount")
 	}
 
 	if totalCount == 0 {
 		return &models.UsersList{
-			TotalCount: totalCount,
-			TotalPages: utils.GetTotalPages(totalCount, pq.GetSize()),
-			Page:       pq.GetPage(),
-			Size:       pq.GetSize(),
-			HasMore:    utils.GetHasMore(pq.GetPage(), totalCount, pq.GetSize()),
+			Pagination: utils.GetPagination(totalCount, pq),
 			Users:      make([]*models.User, 0),
 		}, nil
 	}
 
+	usersQuery := fmt.Sprintf("%s%s ORDER BY %s OFFSET %s LIMIT %s", getUsers, b.WhereSQL(),
+		sort.With(userIDSort).SQL(), b.Arg(pq.GetOffset()), b.Arg(pq.GetLimit()))
+
 	var users = make([]*models.User, 0, pq.GetSize())
-	if err := r.db.SelectContext(
-		ctx,
-		&users,
-		getUsers,
-		pq.GetOrderBy(),
-		pq.GetOffset(),
-		pq.GetLimit(),
-	); err != nil {
+	if err := r.db.Reader(ctx).SelectContext(ctx, &users, usersQuery, b.Args()...); err != nil {
 		return nil, errors.Wrap(err, "authRepo.GetUsers.SelectContext")
 	}
 
 	return &models.UsersList{
-		TotalCount: totalCount,
-		TotalPages: utils.GetTotalPages(totalCount, pq.GetSize()),
-		Page:       pq.GetPage(),
-		Size:       pq.GetSize(),
-		HasMore:    utils.GetHasMore(pq.GetPage(), totalCount, pq.GetSize()),
+		Pagination: utils.GetPagination(totalCount, pq),
 		Users:      users,
 	}, nil
 }
 
+// Get a page of rows after or before the cursor, selectQuery and countQuery get the conditions of b appended.
+// One extra row is read to know if there is a next page, the total is only counted when asked for.
+func selectPage[T any](ctx context.Context, db postgres.Querier, selectQuery string, countQuery string, b *query.Builder, sort query.Sort, pq *utils.PaginationQuery, sortValue func(T, string) string) ([]T, models.Pagination, error) {
+	pagination := models.Pagination{Size: pq.GetSize()}
+	if pq.IncludeTotal {
+		if err := db.GetContext(ctx, &pagination.TotalCount, countQuery+b.WhereSQL(), b.Args()...); err != nil {
+			return nil, pagination, errors.Wrap(err, "selectPage.GetContext.totalCount")
+		}
+		pagination.TotalPages = utils.GetTotalPages(pagination.TotalCount, pq.GetSize())
+	}
+
+	cursor, reverse := pq.After, false
+	if pq.Before != "" {
+		cursor, reverse = pq.Before, true
+	}
+	if cursor != "" {
+		values, err := query.DecodeCursor(cursor, sort)
+		if err != nil {
+			return nil, pagination, httpErrors.NewRestError(http.StatusBadRequest, err.Error(), err)
+		}
+		b.Keyset(sort, values, reverse)
+	}
+
+	order := sort
+	if reverse {
+		order = sort.Reverse()
+	}
+	pageQuery := fmt.Sprintf("%s%s ORDER BY %s LIMIT %s", selectQuery, b.WhereSQL(), order.SQL(), b.Arg(pq.GetLimit()+1))
+
+	rows := make([]T, 0, pq.GetLimit()+1)
+	if err := db.SelectContext(ctx, &rows, pageQuery, b.Args()...); err != nil {
+		return nil, pagination, errors.Wrap(err, "selectPage.SelectContext")
+	}
+
+	more := len(rows) > pq.GetLimit()
+	if more {
+		rows = rows[:pq.GetLimit()]
+	}
+	if reverse {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	if len(rows) > 0 {
+		first, last := rowCursor(sort, rows[0], sortValue), rowCursor(sort, rows[len(rows)-1], sortValue)
+		// Paging back always has rows after it, paging forward has rows before it once past the first page
+		if reverse {
+			pagination.NextCursor = last
+			if more {
+				pagination.PrevCursor = first
+			}
+		} else {
+			if more {
+				pagination.NextCursor = last
+			}
+			if cursor != "" {
+				pagination.PrevCursor = first
+			}
+		}
+	}
+	pagination.HasMore = pagination.NextCursor != ""
+
+	return rows, pagination, nil
+}
+
+// Cursor pointing at the row in sort order
+func rowCursor[T any](sort query.Sort, row T, sortValue func(T, string) string) string {
+	values := make([]string, 0, len(sort))
+	for _, field := range sort {
+		values = append(values, sortValue(row, field.Field))
+	}
+	return query.EncodeCursor(sort, values)
+}
+
+// Build search query for the mode, the first argument is the search text
+func userSearchQuery(search *models.UserSearch) (string, string, *query.Builder) {
+	b := query.NewBuilder()
+	text := b.Arg(search.Query)
+
+	var match, rank, tsQuery string
+	switch search.Mode {
+	case models.SearchModeExact:
+		tsQuery = "plainto_tsquery('simple', " + text + ")"
+		match = "search_vector @@ " + tsQuery
+		rank = "ts_rank(search_vector, " + tsQuery + ")"
+	case models.SearchModePrefix:
+		tsQuery = "to_tsquery('simple', " + b.Arg(prefixTSQuery(search.Query)) + ")"
+		match = "search_vector @@ " + tsQuery
+		rank = "ts_rank(search_vector, " + tsQuery + ")"
+	default:
+		// Trigram similarity tolerates typos, prefix matches keep partial words ranked
+		tsQuery = "to_tsquery('simple', " + b.Arg(prefixTSQuery(search.Query)) + ")"
+		match = "(search_vector @@ " + tsQuery +
+			" OR (first_name || ' ' || last_name) % " + text +
+			" OR split_part(email, '@', 1) % " + text +
+			" OR city % " + text + ")"
+		rank = "GREATEST(ts_rank(search_vector, " + tsQuery + ")" +
+			", similarity(first_name || ' ' || last_name, " + text + ")" +
+			", similarity(split_part(email, '@', 1), " + text + ")" +
+			", similarity(COALESCE(city, ''), " + text + "))"
+	}
+
+	return fmt.Sprintf(searchUsers, match, rank, tsQuery), fmt.Sprintf(getSearchTotal, match), b
+}
+
+// Prefix tsquery of the words of text, like "jo:* & smi:*"
+func prefixTSQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// Build users filter conditions, values are bound as arguments
+func userFilterQuery(filter *models.UserFilter) *query.Builder {
+	b := query.NewBuilder().Where("deleted_at IS NULL")
+	if filter.Role != nil {
+		b.Where("role = ?", *filter.Role)
+	}
+	if filter.City != nil {
+		b.Where("lower(city) = lower(?)", *filter.City)
+	}
+	if filter.Country != nil {
+		b.Where("lower(country) = lower(?)", *filter.Country)
+	}
+	if filter.Gender != nil {
+		b.Where("lower(gender) = lower(?)", *filter.Gender)
+	}
+	if filter.CreatedAfter != nil {
+		b.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		b.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.HasAvatar != nil {
+		if *filter.HasAvatar {
+			b.Where("avatars IS NOT NULL")
+		} else {
+			b.Where("avatars IS NULL")
+		}
+	}
+	return b
+}
+
 // Get User By Id
 func (r *authRepo) GetByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
 	// TODO: Open Tracing
 
 	user := &models.User{}
-	if err := r.db.QueryRowxContext(ctx, getUserQuery, userID).StructScan(user); err != nil {
+	if err := r.db.Reader(ctx).QueryRowxContext(ctx, getUserQuery, userID).StructScan(user); err != nil {
 		return nil, errors.Wrap(err, "authRepo.GetByID.QueryRowxContext")
 	}
 	return user, nil
 }
 
-// Update existing user
+// Update existing user if it is still at its version, a user changed since returns sql.ErrNoRows
 func (r *authRepo) Update(ctx context.Context, user *models.User) (*models.User, error) {
 	// TODO: Open Tracing
 
 	u := &models.User{}
-	if err := r.db.GetContext(ctx, u, updateUserQuery, &user.FirstName, &user.LastName, &user.Email,
+	if err := r.db.Writer(ctx).GetContext(ctx, u, updateUserQuery, &user.FirstName, &user.LastName, &user.Email,
 		&user.Role, &user.About, &user.Avatar, &user.PhoneNumber, &user.Address, &user.City, &user.Gender,
-		&user.Postcode, &user.Birthday, &user.UserID,
+		&user.Postcode, &user.Birthday, user.Avatars, &user.UserID, user.Version,
 	); err != nil {
 		return nil, errors.Wrap(err, "authRepo.Update.GetContext")
 	}
 
 	return u, nil
 }
+
+// Update only the given user columns if the user is still at version, nil values set them to NULL.
+// A user changed since returns sql.ErrNoRows.
+func (r *authRepo) Patch(ctx context.Context, userID uuid.UUID, version int, fields map[string]interface{}) (*models.User, error) {
+	// TODO: Open Tracing
+
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if _, ok := models.UserPatchFields[column]; !ok {
+			return nil, errors.Errorf("authRepo.Patch: column %q can't be patched", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	b := query.NewBuilder()
+	sets := make([]string, 0, len(columns))
+	for _, column := range columns {
+		sets = append(sets, column+" = "+b.Arg(fields[column]))
+	}
+	patchQuery := fmt.Sprintf(patchUserQuery, strings.Join(sets, ", "), b.Arg(userID), b.Arg(version))
+
+	u := &models.User{}
+	if err := r.db.Writer(ctx).GetContext(ctx, u, patchQuery, b.Args()...); err != nil {
+		return nil, errors.Wrap(err, "authRepo.Patch.GetContext")
+	}
+
+	return u, nil
+}
+
+// Replace or clear user avatar, returns the avatars it replaced
+func (r *authRepo) UpdateAvatar(ctx context.Context, userID uuid.UUID, avatar *string, avatars models.AvatarVariants) (*models.User, models.AvatarVariants, error) {
+	// TODO: Open Tracing
+
+	u := &struct {
+		models.User
+		PreviousAvatars models.AvatarVariants `db:"previous_avatars"`
+	}{}
+	if err := r.db.Writer(ctx).GetContext(ctx, u, updateAvatarQuery, avatar, avatars, userID); err != nil {
+		return nil, nil, errors.Wrap(err, "authRepo.UpdateAvatar.GetContext")
+	}
+
+	return &u.User, u.PreviousAvatars, nil
+}
+
+// Deactivate user, a deactivated user can't sign in until restored
+func (r *authRepo) Deactivate(ctx context.Context, userID uuid.UUID) error {
+	// TODO: Open Tracing
+
+	result, err := r.db.Writer(ctx).ExecContext(ctx, deactivateUserQuery, userID)
+	if err != nil {
+		return errors.Wrap(err, "authRepo.Deactivate.ExecContext")
+	}
+
+	return checkAffected(result, "authRepo.Deactivate")
+}
+
+// Soft delete user, the row is kept and can be restored until it is purged
+func (r *authRepo) Delete(ctx context.Context, userID uuid.UUID) error {
+	// TODO: Open Tracing
+
+	result, err := r.db.Writer(ctx).ExecContext(ctx, deleteUserQuery, userID)
+	if err != nil {
+		return errors.Wrap(err, "authRepo.Delete.ExecContext")
+	}
+
+	return checkAffected(result, "authRepo.Delete")
+}
+
+// Find deactivated or soft deleted user by email
+func (r *authRepo) FindInactiveByEmail(ctx context.Context, email string) (*models.User, error) {
+	// TODO: Open Tracing
+
+	foundUser := &models.User{}
+	if err := r.db.Reader(ctx).QueryRowxContext(ctx, findInactiveUserByEmail, email).StructScan(foundUser); err != nil {
+		return nil, errors.Wrap(err, "authRepo.FindInactiveByEmail.QueryRowxContext")
+	}
+
+	return foundUser, nil
+}
+
+// Restore deactivated user, or soft deleted one deleted after deletedAfter
+func (r *authRepo) Restore(ctx context.Context, userID uuid.UUID, deletedAfter time.Time) (*models.User, error) {
+	// TODO: Open Tracing
+
+	u := &models.User{}
+	if err := r.db.Writer(ctx).GetContext(ctx, u, restoreUserQuery, userID, deletedAfter); err != nil {
+		return nil, errors.Wrap(err, "authRepo.Restore.GetContext")
+	}
+
+	return u, nil
+}
+
+// Anonymise users soft deleted before deletedBefore, returns how many were purged
+func (r *authRepo) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	// TODO: Open Tracing
+
+	result, err := r.db.Writer(ctx).ExecContext(ctx, purgeDeletedUsersQuery, deletedBefore)
+	if err != nil {
+		return 0, errors.Wrap(err, "authRepo.PurgeDeleted.ExecContext")
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "authRepo.PurgeDeleted.RowsAffected")
+	}
+
+	return purged, nil
+}
+
+// Updates of a missing user return sql.ErrNoRows
+func checkAffected(result sql.Result, op string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, op+".RowsAffected")
+	}
+	if affected == 0 {
+		return errors.Wrap(sql.ErrNoRows, op+".RowsAffected")
+	}
+	return nil
+}
+
+// Get avatars of every user that has one
+func (r *authRepo) GetAvatars(ctx context.Context) ([]models.AvatarVariants, error) {
+	// TODO: Open Tracing
+
+	avatars := make([]models.AvatarVariants, 0)
+	if err := r.db.Reader(ctx).SelectContext(ctx, &avatars, getAvatarsQuery); err != nil {
+		return nil, errors.Wrap(err, "authRepo.GetAvatars.SelectContext")
+	}
+
+	return avatars, nil
+}
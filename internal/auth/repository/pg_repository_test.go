@@ -2,11 +2,17 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/query"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -23,7 +29,7 @@ func TestAuthRepo_Register(t *testing.T) {
 	sqlxDB := sqlx.NewDb(db, "sqlmock")
 	defer sqlxDB.Close()
 
-	authRepo := NewAuthRepository(sqlxDB)
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
 
 	t.Run("Register", func(t *testing.T) {
 		gender := "male"
@@ -63,7 +69,7 @@ func TestAuthRepo_GetByID(t *testing.T) {
 	sqlxDB := sqlx.NewDb(db, "sqlmock")
 	defer sqlxDB.Close()
 
-	authRepo := NewAuthRepository(sqlxDB)
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
 
 	t.Run("GetByID", func(t *testing.T) {
 		uid := uuid.New()
@@ -90,6 +96,136 @@ func TestAuthRepo_GetByID(t *testing.T) {
 	})
 }
 
+func TestAuthRepo_Patch(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	defer sqlxDB.Close()
+
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
+
+	uid := uuid.New()
+	rows := sqlmock.NewRows([]string{"user_id", "first_name", "last_name", "email", "about"}).AddRow(
+		uid, "Alfan", "Almunawar", "alfan@gmail.com", nil)
+
+	mock.ExpectQuery(fmt.Sprintf(patchUserQuery, "about = $1, first_name = $2", "$3", "$4")).
+		WithArgs(nil, "Alfan", uid, 2).
+		WillReturnRows(rows)
+
+	user, err := authRepo.Patch(context.Background(), uid, 2, map[string]interface{}{
+		"first_name": "Alfan",
+		"about":      nil,
+	})
+	require.NoError(t, err)
+	require.Nil(t, user.About)
+
+	_, err = authRepo.Patch(context.Background(), uid, 2, map[string]interface{}{"password": "secret"})
+	require.Error(t, err)
+}
+
+func TestAuthRepo_Update(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	defer sqlxDB.Close()
+
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
+
+	user := &models.User{
+		UserID:    uuid.New(),
+		FirstName: "Alfan",
+		LastName:  "Almunawar",
+		Email:     "alfan@gmail.com",
+		Version:   2,
+	}
+	args := func() []driver.Value {
+		return []driver.Value{&user.FirstName, &user.LastName, &user.Email, &user.Role, &user.About, &user.Avatar,
+			&user.PhoneNumber, &user.Address, &user.City, &user.Gender, &user.Postcode, &user.Birthday, user.Avatars,
+			&user.UserID, user.Version}
+	}
+
+	t.Run("Update", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"user_id", "first_name", "version"}).AddRow(user.UserID, "Alfan", 3)
+
+		mock.ExpectQuery(updateUserQuery).WithArgs(args()...).WillReturnRows(rows)
+
+		updatedUser, err := authRepo.Update(context.Background(), user)
+		require.NoError(t, err)
+		require.Equal(t, 3, updatedUser.Version)
+	})
+
+	t.Run("Changed since read", func(t *testing.T) {
+		mock.ExpectQuery(updateUserQuery).WithArgs(args()...).WillReturnError(sql.ErrNoRows)
+
+		_, err := authRepo.Update(context.Background(), user)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthRepo_UpdateAvatar(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	defer sqlxDB.Close()
+
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
+
+	uid := uuid.New()
+	avatar := "http://localhost/avatar_512.png"
+	avatars := models.AvatarVariants{{Size: 512, Bucket: "avatars", Key: "new_512.png"}}
+	rows := sqlmock.NewRows([]string{"user_id", "avatar", "avatars", "previous_avatars"}).
+		AddRow(uid, avatar, `[{"size":512,"bucket":"avatars","key":"new_512.png"}]`, `[{"size":512,"bucket":"avatars","key":"old_512.png"}]`)
+
+	mock.ExpectQuery(updateAvatarQuery).WithArgs(&avatar, avatars, uid).WillReturnRows(rows)
+
+	user, previous, err := authRepo.UpdateAvatar(context.Background(), uid, &avatar, avatars)
+	require.NoError(t, err)
+	require.Equal(t, "new_512.png", user.Avatars[0].Key)
+	require.Equal(t, "old_512.png", previous[0].Key)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthRepo_Delete(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	defer sqlxDB.Close()
+
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
+
+	uid := uuid.New()
+
+	mock.ExpectExec(deleteUserQuery).WithArgs(uid).WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, authRepo.Delete(context.Background(), uid))
+
+	mock.ExpectExec(deleteUserQuery).WithArgs(uid).WillReturnResult(sqlmock.NewResult(0, 0))
+	require.ErrorIs(t, authRepo.Delete(context.Background(), uid), sql.ErrNoRows)
+
+	deletedBefore := time.Now().Add(-time.Hour)
+	mock.ExpectExec(purgeDeletedUsersQuery).WithArgs(deletedBefore).WillReturnResult(sqlmock.NewResult(0, 2))
+	purged, err := authRepo.PurgeDeleted(context.Background(), deletedBefore)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), purged)
+}
+
 func TestAuthRepo_FindByEmail(t *testing.T) {
 	t.Parallel()
 
@@ -100,7 +236,7 @@ func TestAuthRepo_FindByEmail(t *testing.T) {
 	sqlxDB := sqlx.NewDb(db, "sqlmock")
 	defer sqlxDB.Close()
 
-	authRepo := NewAuthRepository(sqlxDB)
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
 
 	t.Run("FindByEmail", func(t *testing.T) {
 		uid := uuid.New()
@@ -135,7 +271,7 @@ func TestAuthRepo_GetUsers(t *testing.T) {
 	sqlxDB := sqlx.NewDb(db, "sqlmock")
 	defer sqlxDB.Close()
 
-	authRepo := NewAuthRepository(sqlxDB)
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
 
 	t.Run("FindByEmail", func(t *testing.T) {
 		uid := uuid.New()
@@ -145,10 +281,10 @@ func TestAuthRepo_GetUsers(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"user_id", "first_name", "last_name", "email"}).AddRow(
 			uid, "Alfan", "Almunawar", "alfan@gmai.com")
 
-		mock.ExpectQuery(getTotal).WillReturnRows(totalCountRows)
+		mock.ExpectQuery(getTotal + " WHERE deleted_at IS NULL").WillReturnRows(totalCountRows)
 		mock.ExpectQuery(getUsers).WithArgs("", 0, 10).WillReturnRows(rows)
 
-		users, err := authRepo.GetUsers(context.Background(), &utils.PaginationQuery{
+		users, err := authRepo.GetUsers(context.Background(), nil, &utils.PaginationQuery{
 			Size:    10,
 			Page:    1,
 			OrderBy: "",
@@ -159,7 +295,7 @@ func TestAuthRepo_GetUsers(t *testing.T) {
 
 }
 
-func TestAuthRepo_FindByName(t *testing.T) {
+func TestAuthRepo_GetUsersFilter(t *testing.T) {
 	t.Parallel()
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -169,21 +305,62 @@ func TestAuthRepo_FindByName(t *testing.T) {
 	sqlxDB := sqlx.NewDb(db, "sqlmock")
 	defer sqlxDB.Close()
 
-	authRepo := NewAuthRepository(sqlxDB)
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
 
-	t.Run("FindByName", func(t *testing.T) {
+	t.Run("Filter and sort", func(t *testing.T) {
 		uid := uuid.New()
-		userName := "Alfan"
+		role := "admin"
+		hasAvatar := true
 
-		totalCountRows := sqlmock.NewRows([]string{"count"}).AddRow(0)
+		sort, err := query.ParseSort("created_at:desc,last_name", models.UserSortFields)
+		require.NoError(t, err)
 
+		totalCountRows := sqlmock.NewRows([]string{"count"}).AddRow(1)
 		rows := sqlmock.NewRows([]string{"user_id", "first_name", "last_name", "email"}).AddRow(
 			uid, "Alfan", "Almunawar", "alfan@gmail.com")
 
-		mock.ExpectQuery(getTotalCount).WillReturnRows(totalCountRows)
-		mock.ExpectQuery(findUsers).WithArgs("", 0, 10).WillReturnRows(rows)
+		mock.ExpectQuery(getTotal + " WHERE deleted_at IS NULL AND role = $1 AND avatars IS NOT NULL").WithArgs(role).WillReturnRows(totalCountRows)
+		mock.ExpectQuery(getUsers+" WHERE deleted_at IS NULL AND role = $1 AND avatars IS NOT NULL ORDER BY created_at DESC, last_name ASC, user_id ASC OFFSET $2 LIMIT $3").
+			WithArgs(role, 0, 10).WillReturnRows(rows)
+
+		users, err := authRepo.GetUsers(context.Background(), &models.UserFilter{
+			Role:      &role,
+			HasAvatar: &hasAvatar,
+			Sort:      sort,
+		}, &utils.PaginationQuery{Size: 10, Page: 1})
+		require.NoError(t, err)
+		require.Len(t, users.Users, 1)
+	})
+
+}
+
+func TestAuthRepo_FindByName(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	defer sqlxDB.Close()
+
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
+
+	t.Run("FindByName", func(t *testing.T) {
+		uid := uuid.New()
+		search := &models.UserSearch{Query: "Alfan Almu", Mode: models.SearchModeFuzzy}
+		selectQuery, countQuery, _ := userSearchQuery(search)
 
-		usersList, err := authRepo.FindByName(context.Background(), userName, &utils.PaginationQuery{
+		totalCountRows := sqlmock.NewRows([]string{"count"}).AddRow(1)
+
+		rows := sqlmock.NewRows([]string{"user_id", "first_name", "last_name", "email", "rank", "highlight"}).AddRow(
+			uid, "Alfan", "Almunawar", "alfan@gmail.com", 0.6, "<mark>Alfan</mark> Almunawar")
+
+		mock.ExpectQuery(countQuery).WithArgs("Alfan Almu", "alfan:* & almu:*").WillReturnRows(totalCountRows)
+		mock.ExpectQuery(selectQuery+" ORDER BY rank DESC, user_id ASC OFFSET $3 LIMIT $4").
+			WithArgs("Alfan Almu", "alfan:* & almu:*", 0, 10).WillReturnRows(rows)
+
+		usersList, err := authRepo.FindByName(context.Background(), search, &utils.PaginationQuery{
 			Size:    10,
 			Page:    1,
 			OrderBy: "",
@@ -191,5 +368,125 @@ func TestAuthRepo_FindByName(t *testing.T) {
 
 		require.NoError(t, err)
 		require.NotNil(t, usersList)
+		require.Len(t, usersList.Users, 1)
+		require.Equal(t, float32(0.6), usersList.Users[0].Rank)
+		require.Equal(t, "<mark>Alfan</mark> Almunawar", usersList.Users[0].Highlight)
+	})
+
+	t.Run("Exact", func(t *testing.T) {
+		selectQuery, countQuery, b := userSearchQuery(&models.UserSearch{Query: "alfan", Mode: models.SearchModeExact})
+		require.Contains(t, selectQuery, "plainto_tsquery('simple', $1)")
+		require.Contains(t, selectQuery, "'<', '&lt;'")
+		require.NotContains(t, countQuery, "similarity")
+		require.Len(t, b.Args(), 1)
 	})
 }
+
+func TestAuthRepo_SelectedColumns(t *testing.T) {
+	t.Parallel()
+
+	columns := strings.Split(strings.TrimPrefix(returningUser, " RETURNING "), ",")
+	queries := map[string]string{
+		"findUserByEmail":         findUserByEmail,
+		"searchUsers":             searchUsers,
+		"getUsers":                getUsers,
+		"getUserQuery":            getUserQuery,
+		"findInactiveUserByEmail": findInactiveUserByEmail,
+	}
+	for name, selectQuery := range queries {
+		for _, column := range columns {
+			column = strings.TrimSpace(column)
+			if column == "password" {
+				continue
+			}
+			require.Regexp(t, `\b`+column+`\b`, selectQuery, "%s doesn't select %s", name, column)
+		}
+	}
+}
+
+func TestAuthRepo_GetUsersCursor(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	defer sqlxDB.Close()
+
+	authRepo := NewAuthRepository(postgres.NewRouter(sqlxDB, nil, 0, nil))
+
+	sort, err := query.ParseSort("created_at:desc", models.UserSortFields)
+	require.NoError(t, err)
+	filter := &models.UserFilter{Sort: sort}
+
+	createdAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	uids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	var nextCursor string
+
+	t.Run("First page", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"user_id", "first_name", "last_name", "created_at"})
+		for i, uid := range uids {
+			rows.AddRow(uid, "Alfan", "Almunawar", createdAt.Add(-time.Duration(i)*time.Hour))
+		}
+
+		mock.ExpectQuery(getUsers + " WHERE deleted_at IS NULL ORDER BY created_at DESC, user_id ASC LIMIT $1").WithArgs(3).WillReturnRows(rows)
+
+		usersList, err := authRepo.GetUsers(context.Background(), filter, &utils.PaginationQuery{Size: 2, Cursor: true})
+		require.NoError(t, err)
+		require.Len(t, usersList.Users, 2)
+		require.True(t, usersList.HasMore)
+		require.Empty(t, usersList.PrevCursor)
+		require.NotEmpty(t, usersList.NextCursor)
+		nextCursor = usersList.NextCursor
+	})
+
+	t.Run("Next page", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"user_id", "first_name", "last_name", "created_at"}).
+			AddRow(uids[2], "Alfan", "Almunawar", createdAt.Add(-2*time.Hour))
+
+		last := createdAt.Add(-time.Hour).Format(time.RFC3339Nano)
+		mock.ExpectQuery(getUsers+" WHERE deleted_at IS NULL AND ((created_at < $1) OR (created_at = $2 AND user_id > $3)) ORDER BY created_at DESC, user_id ASC LIMIT $4").
+			WithArgs(last, last, uids[1].String(), 3).WillReturnRows(rows)
+
+		usersList, err := authRepo.GetUsers(context.Background(), filter, &utils.PaginationQuery{Size: 2, Cursor: true, After: nextCursor})
+		require.NoError(t, err)
+		require.Len(t, usersList.Users, 1)
+		require.False(t, usersList.HasMore)
+		require.Empty(t, usersList.NextCursor)
+		require.NotEmpty(t, usersList.PrevCursor)
+	})
+
+	t.Run("Nullable sort column", func(t *testing.T) {
+		sort, err := query.ParseSort("country", models.UserSortFields)
+		require.NoError(t, err)
+		filter := &models.UserFilter{Sort: sort}
+
+		rows := sqlmock.NewRows([]string{"user_id", "first_name", "last_name", "country"}).
+			AddRow(uids[0], "Alfan", "Almunawar", nil).
+			AddRow(uids[1], "Alfan", "Almunawar", "ID").
+			AddRow(uids[2], "Alfan", "Almunawar", "SG")
+
+		mock.ExpectQuery(getUsers + " WHERE deleted_at IS NULL ORDER BY COALESCE(country, '') ASC, user_id ASC LIMIT $1").WithArgs(3).WillReturnRows(rows)
+
+		usersList, err := authRepo.GetUsers(context.Background(), filter, &utils.PaginationQuery{Size: 2, Cursor: true})
+		require.NoError(t, err)
+		require.Len(t, usersList.Users, 2)
+
+		// The cursor carries the country of the last row, not the empty value of an unselected column
+		mock.ExpectQuery(getUsers+" WHERE deleted_at IS NULL AND ((COALESCE(country, '') > $1) OR (COALESCE(country, '') = $2 AND user_id > $3)) ORDER BY COALESCE(country, '') ASC, user_id ASC LIMIT $4").
+			WithArgs("ID", "ID", uids[1].String(), 3).WillReturnRows(sqlmock.NewRows([]string{"user_id", "country"}).AddRow(uids[2], "SG"))
+
+		usersList, err = authRepo.GetUsers(context.Background(), filter, &utils.PaginationQuery{Size: 2, Cursor: true, After: usersList.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, usersList.Users, 1)
+		require.Equal(t, "SG", *usersList.Users[0].Country)
+	})
+
+	t.Run("Cursor of another sort", func(t *testing.T) {
+		_, err := authRepo.GetUsers(context.Background(), &models.UserFilter{}, &utils.PaginationQuery{Size: 2, Cursor: true, After: nextCursor})
+		require.Error(t, err)
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,8 +2,6 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/internal/auth"
@@ -12,6 +10,8 @@ import (
 	"github.com/pkg/errors"
 )
 
+const migrateScanCount = 100
+
 // Auth redis repository
 type authRedisRepo struct {
 	redisClient *redis.Client
@@ -22,29 +22,41 @@ func NewAuthRedisRepo(redisClient *redis.Client) auth.RedisRepository {
 	return &authRedisRepo{redisClient: redisClient}
 }
 
-// Get user by id
+// Get user by id, nil when the key is missing or cached by a newer version.
+// Users cached by an older version are cached again in the current one.
 func (a *authRedisRepo) GetByIDCtx(ctx context.Context, key string) (*models.User, error) {
 	// TODO: Open Tracing
 
 	userBytes, err := a.redisClient.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "authRedisRepo.GetByIDCtx.redisClient.Get")
 	}
-	user := &models.User{}
-	if err = json.Unmarshal(userBytes, user); err != nil {
-		return nil, errors.Wrap(err, "authRedisRepo.GetByIDCtx.json.Unmarshal")
+
+	user, outdated, err := decodeCachedUser(userBytes)
+	if errors.Is(err, errUserCacheVersion) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "authRedisRepo.GetByIDCtx.decodeCachedUser")
+	}
+	if outdated {
+		if _, err = a.migrateUser(ctx, key); err != nil {
+			return nil, err
+		}
 	}
+
 	return user, nil
 }
 
-// Cache user with duration in seconds
+// Cache user with duration in seconds, credentials are never cached
 func (a *authRedisRepo) SetUserCtx(ctx context.Context, key string, seconds int, user *models.User) error {
-	fmt.Printf("SET USER CONTEXT")
-
 	// TODO: Open Tracing
-	userBytes, err := json.Marshal(user)
+	userBytes, err := encodeCachedUser(user)
 	if err != nil {
-		return errors.Wrap(err, "authRedisRepo.SetUserCtx.json.Unmarshal")
+		return errors.Wrap(err, "authRedisRepo.SetUserCtx.encodeCachedUser")
 	}
 	if err = a.redisClient.Set(ctx, key, userBytes, time.Second*time.Duration(seconds)).Err(); err != nil {
 		return errors.Wrap(err, "authRedisRepo.SetUserCtx.redisClient.Set")
@@ -53,6 +65,80 @@ func (a *authRedisRepo) SetUserCtx(ctx context.Context, key string, seconds int,
 	return nil
 }
 
+// Cache users matching the key pattern again in the current version, returns how many were outdated
+func (a *authRedisRepo) MigrateUsersCtx(ctx context.Context, match string) (int, error) {
+	// TODO: Open Tracing
+	migrated := 0
+	iter := a.redisClient.Scan(ctx, 0, match, migrateScanCount).Iterator()
+	for iter.Next(ctx) {
+		ok, err := a.migrateUser(ctx, iter.Val())
+		if err != nil {
+			return migrated, err
+		}
+		if ok {
+			migrated++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return migrated, errors.Wrap(err, "authRedisRepo.MigrateUsersCtx.Scan")
+	}
+
+	return migrated, nil
+}
+
+// Encode the user of key again if it is outdated, keeping its expiry.
+// The key is watched so a user deleted or cached meanwhile is not overwritten.
+func (a *authRedisRepo) migrateUser(ctx context.Context, key string) (bool, error) {
+	migrated := false
+	err := a.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+		userBytes, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		user, outdated, err := decodeCachedUser(userBytes)
+		if errors.Is(err, errUserCacheVersion) || (err == nil && !outdated) {
+			return nil
+		}
+		if err != nil {
+			// Undecodable entries are dropped, the user is loaded again on the next read
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				return pipe.Del(ctx, key).Err()
+			})
+			return err
+		}
+
+		ttl, err := tx.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if ttl < 0 {
+			ttl = 0
+		}
+
+		userBytes, err = encodeCachedUser(user)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return pipe.Set(ctx, key, userBytes, ttl).Err()
+		})
+		migrated = err == nil
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "authRedisRepo.migrateUser.Watch")
+	}
+
+	return migrated, nil
+}
+
 // Delete user by key
 func (a *authRedisRepo) DeleteUserCtx(ctx context.Context, key string) error {
 	// TODO: Open Tracing
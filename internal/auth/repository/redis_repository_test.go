@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"log"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis"
 	"github.com/fekuna/go-rest-clean-architecture/internal/auth"
@@ -11,6 +13,7 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func SetupRedis() auth.RedisRepository {
@@ -49,6 +52,12 @@ func TestAuthRedisRepo_GetByIDCtx(t *testing.T) {
 		require.NoError(t, err)
 		require.NotNil(t, user)
 	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		user, err := authRedisRepo.GetByIDCtx(context.Background(), uuid.New().String())
+		require.NoError(t, err)
+		require.Nil(t, user)
+	})
 }
 
 func TestAuthRedisRepo_SetUserCtx(t *testing.T) {
@@ -84,3 +93,116 @@ func TestAuthRedisRepo_DeleteUserCtx(t *testing.T) {
 		require.Nil(t, err)
 	})
 }
+
+func setupRedisServer(t *testing.T) (*miniredis.Miniredis, auth.RedisRepository) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+
+	client := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	t.Cleanup(func() { client.Close() })
+
+	return mr, NewAuthRedisRepo(client)
+}
+
+func TestAuthRedisRepo_NoCredentials(t *testing.T) {
+	t.Parallel()
+
+	mr, authRedisRepo := setupRedisServer(t)
+
+	hashPassword, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	avatar := "https://avatars/user.png"
+	u := &models.User{
+		UserID:    uuid.New(),
+		FirstName: "Alfan",
+		LastName:  "Almunawar",
+		Email:     "email@gmail.com",
+		Password:  string(hashPassword),
+		Avatar:    &avatar,
+		Version:   3,
+	}
+	key := uuid.New().String()
+
+	require.NoError(t, authRedisRepo.SetUserCtx(context.Background(), key, 10, u))
+
+	raw, err := mr.Get(key)
+	require.NoError(t, err)
+	require.NotContains(t, raw, string(hashPassword))
+	require.NotContains(t, raw, `"password"`)
+
+	cached, err := authRedisRepo.GetByIDCtx(context.Background(), key)
+	require.NoError(t, err)
+	require.Empty(t, cached.Password)
+	require.Equal(t, u.Email, cached.Email)
+	require.Equal(t, avatar, *cached.Avatar)
+	require.Equal(t, u.Version, cached.Version)
+}
+
+// A models.User field must either be cached or excluded, so new secrets can't slip into redis
+func TestCachedUser_Fields(t *testing.T) {
+	t.Parallel()
+
+	cachedFields := make(map[string]bool)
+	cachedType := reflect.TypeOf(cachedUser{})
+	for i := 0; i < cachedType.NumField(); i++ {
+		cachedFields[cachedType.Field(i).Name] = true
+	}
+
+	userType := reflect.TypeOf(models.User{})
+	for i := 0; i < userType.NumField(); i++ {
+		name := userType.Field(i).Name
+		if userCacheExcluded[name] {
+			require.False(t, cachedFields[name], "excluded field %s is cached", name)
+			continue
+		}
+		require.True(t, cachedFields[name], "field %s is neither cached nor excluded", name)
+	}
+}
+
+func TestAuthRedisRepo_MigrateUsersCtx(t *testing.T) {
+	t.Parallel()
+
+	mr, authRedisRepo := setupRedisServer(t)
+	ctx := context.Background()
+
+	legacy := `{"user_id":"` + uuid.New().String() + `","first_name":"Alfan","last_name":"Almunawar","password":"$2a$10$hash","version":2}`
+	require.NoError(t, mr.Set("api-auth:: legacy-1", legacy))
+	mr.SetTTL("api-auth:: legacy-1", time.Hour)
+	require.NoError(t, mr.Set("api-auth:: legacy-2", legacy))
+	require.NoError(t, authRedisRepo.SetUserCtx(ctx, "api-auth:: current", 60, &models.User{UserID: uuid.New()}))
+	require.NoError(t, mr.Set("api-auth:: newer", `{"v":99,"user":{}}`))
+
+	t.Run("Read", func(t *testing.T) {
+		user, err := authRedisRepo.GetByIDCtx(ctx, "api-auth:: legacy-1")
+		require.NoError(t, err)
+		require.Empty(t, user.Password)
+		require.Equal(t, 2, user.Version)
+
+		raw, err := mr.Get("api-auth:: legacy-1")
+		require.NoError(t, err)
+		require.NotContains(t, raw, "$2a$10$hash")
+		require.Equal(t, time.Hour, mr.TTL("api-auth:: legacy-1"))
+
+		user, err = authRedisRepo.GetByIDCtx(ctx, "api-auth:: newer")
+		require.NoError(t, err)
+		require.Nil(t, user)
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		migrated, err := authRedisRepo.MigrateUsersCtx(ctx, "api-auth:*")
+		require.NoError(t, err)
+		require.Equal(t, 1, migrated)
+
+		raw, err := mr.Get("api-auth:: legacy-2")
+		require.NoError(t, err)
+		require.NotContains(t, raw, "$2a$10$hash")
+
+		raw, err = mr.Get("api-auth:: newer")
+		require.NoError(t, err)
+		require.Equal(t, `{"v":99,"user":{}}`, raw)
+	})
+}
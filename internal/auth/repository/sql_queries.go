@@ -1,7 +1,12 @@
 package repository
 
+// Soft deleted users are left out of every query but getAvatarsQuery and the ones restoring or purging them
 const (
-	createUserQuery = `INSERT INTO users(first_name, last_name, email, password, role, about, avatar, phone_number, address, city, gender, postcode, birthday, created_at, updated_at, login_date) VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'user'), $6, $7, $8, $9, $10, $11, $12, $13, now(), now(), now()) RETURNING *`
+	// Columns of models.User, generated columns like search_vector are left out
+	returningUser = ` RETURNING user_id, first_name, last_name, email, password, role, about, avatar, avatars, phone_number,
+						address, city, country, gender, postcode, birthday, created_at, updated_at, login_date, version, deactivated_at`
+
+	createUserQuery = `INSERT INTO users(first_name, last_name, email, password, role, about, avatar, phone_number, address, city, gender, postcode, birthday, created_at, updated_at, login_date) VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'user'), $6, $7, $8, $9, $10, $11, $12, $13, now(), now(), now())` + returningUser
 
 	updateUserQuery = `UPDATE users 
 						SET first_name = COALESCE(NULLIF($1, ''), first_name),
@@ -10,42 +15,99 @@ const (
 						    role = COALESCE(NULLIF($4, ''), role),
 						    about = COALESCE(NULLIF($5, ''), about),
 						    avatar = COALESCE(NULLIF($6, ''), avatar),
+						    avatars = COALESCE($13::jsonb, avatars),
 						    phone_number = COALESCE(NULLIF($7, ''), phone_number),
 						    address = COALESCE(NULLIF($8, ''), address),
 						    city = COALESCE(NULLIF($9, ''), city),
 						    gender = COALESCE(NULLIF($10, ''), gender),
 						    postcode = COALESCE(NULLIF($11, 0), postcode),
 						    birthday = COALESCE(NULLIF($12, '')::date, birthday),
-						    updated_at = now()
-						WHERE user_id = $13
-						RETURNING *
-						`
+						    updated_at = now(),
+						    version = version + 1
+						WHERE user_id = $14 AND version = $15 AND deleted_at IS NULL` + returningUser
+
+	patchUserQuery = `UPDATE users SET %s, updated_at = now(), version = version + 1 WHERE user_id = %s AND version = %s AND deleted_at IS NULL` + returningUser
 
-	findUserByEmail = `SELECT user_id, first_name, last_name, email, role, about, avatar, phone_number, 
-						address, city, gender, postcode, birthday, created_at, updated_at, login_date, password
+	findUserByEmail = `SELECT user_id, first_name, last_name, email, role, about, avatar, avatars, phone_number, 
+						address, city, country, gender, postcode, birthday, created_at, updated_at, login_date, version, deactivated_at, password
 					 FROM users 
-					 WHERE email = $1`
+					 WHERE email = $1 AND deleted_at IS NULL`
 
-	getTotalCount = `SELECT COUNT(user_id) FROM users 
-						WHERE first_name ILIKE '%' || $1 || '%' or last_name ILIKE '%' || $1 || '%'`
+	// Text of the search highlight, HTML escaped so the marks are its only markup
+	searchHeadlineText = `replace(replace(replace(replace(replace(hits.first_name || ' ' || hits.last_name || COALESCE(', ' || hits.city, ''),
+						'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
 
-	findUsers = `SELECT user_id, first_name, last_name, email, role, about, avatar, phone_number, address,
-					city, gender, postcode, birthday, created_at, updated_at, login_date 
-					FROM users 
-					WHERE first_name ILIKE '%' || $1 || '%' or last_name ILIKE '%' || $1 || '%'
-					ORDER BY first_name, last_name
-					OFFSET $2 LIMIT $3
-				`
+	// Search hits with their rank, %[1]s is the match condition, %[2]s the rank and %[3]s the tsquery
+	searchUsers = `SELECT hits.*, ts_headline('simple', ` + searchHeadlineText + `, %[3]s,
+						'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS highlight
+					FROM (SELECT user_id, first_name, last_name, email, role, about, avatar, avatars, phone_number, address,
+							city, country, gender, postcode, birthday, created_at, updated_at, login_date, version, deactivated_at, (%[2]s)::real AS rank
+						FROM users
+						WHERE deleted_at IS NULL AND %[1]s) AS hits`
+
+	getSearchTotal = `SELECT COUNT(user_id) FROM users WHERE deleted_at IS NULL AND %[1]s`
 
 	getTotal = `SELECT COUNT(user_id) FROM users`
 
-	getUsers = `SELECT user_id, first_name, last_name, email, role, about, avatar, phone_number, 
-       			 address, city, gender, postcode, birthday, created_at, updated_at, login_date
-				 FROM users 
-				 ORDER BY COALESCE(NULLIF($1, ''), first_name) OFFSET $2 LIMIT $3`
+	getUsers = `SELECT user_id, first_name, last_name, email, role, about, avatar, avatars, phone_number, 
+       			 address, city, country, gender, postcode, birthday, created_at, updated_at, login_date, version, deactivated_at
+				 FROM users`
 
-	getUserQuery = `SELECT user_id, first_name, last_name, email, role, about, avatar, phone_number, 
-					address, city, gender, postcode, birthday, created_at, updated_at, login_date  
+	// The previous avatars are read locked so concurrent replacements each get the ones they replaced
+	updateAvatarQuery = `UPDATE users 
+						SET avatar = $1,
+						    avatars = $2::jsonb,
+						    updated_at = now(),
+						    version = version + 1
+						FROM (SELECT user_id AS previous_user_id, avatars AS previous_avatars
+							FROM users
+							WHERE user_id = $3 AND deleted_at IS NULL
+							FOR UPDATE) AS previous
+						WHERE user_id = previous_user_id` + returningUser + `, previous_avatars`
+
+	// Soft deleted users are included, their avatars are kept until they are purged
+	getAvatarsQuery = `SELECT avatars FROM users WHERE avatars IS NOT NULL`
+
+	getUserQuery = `SELECT user_id, first_name, last_name, email, role, about, avatar, avatars, phone_number, 
+					address, city, country, gender, postcode, birthday, created_at, updated_at, login_date, version, deactivated_at  
 					FROM users 
-					WHERE user_id = $1`
+					WHERE user_id = $1 AND deleted_at IS NULL`
+
+	deactivateUserQuery = `UPDATE users SET deactivated_at = now(), updated_at = now(), version = version + 1
+						WHERE user_id = $1 AND deactivated_at IS NULL AND deleted_at IS NULL`
+
+	deleteUserQuery = `UPDATE users SET deleted_at = now(), deactivated_at = COALESCE(deactivated_at, now()), updated_at = now(), version = version + 1
+						WHERE user_id = $1 AND deleted_at IS NULL`
+
+	findInactiveUserByEmail = `SELECT user_id, first_name, last_name, email, role, about, avatar, avatars, phone_number, 
+						address, city, country, gender, postcode, birthday, created_at, updated_at, login_date, version, deactivated_at, deleted_at, password
+					 FROM users 
+					 WHERE email = $1 AND deactivated_at IS NOT NULL`
+
+	restoreUserQuery = `UPDATE users SET deleted_at = NULL, deactivated_at = NULL, updated_at = now(), version = version + 1
+						WHERE user_id = $1 AND deactivated_at IS NOT NULL AND (deleted_at IS NULL OR deleted_at > $2)` + returningUser
+
+	// Anonymise users deleted before $1, their avatar objects are left to the avatar reconcile job
+	purgeDeletedUsersQuery = `UPDATE users 
+						SET first_name = 'Deleted',
+						    last_name = 'User',
+						    email = 'deleted-' || user_id || '` + purgedEmailDomain + `',
+						    password = '!',
+						    role = 'user',
+						    about = '',
+						    avatar = NULL,
+						    avatars = NULL,
+						    phone_number = NULL,
+						    address = NULL,
+						    city = NULL,
+						    country = NULL,
+						    gender = 'unknown',
+						    postcode = NULL,
+						    birthday = NULL,
+						    updated_at = now(),
+						    version = version + 1
+						WHERE deleted_at < $1 AND email NOT LIKE '%` + purgedEmailDomain + `'`
+
+	// Purged users get an unreachable email, it keeps the unique constraint and marks them as purged
+	purgedEmailDomain = "@deleted.invalid"
 )
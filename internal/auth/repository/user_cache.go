@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Cached user encodings, bump userCacheVersion and decode the older ones when cachedUser changes:
+//  1. models.User encoded as json, with the password hash
+//  2. cachedUser in an envelope
+const userCacheVersion = 2
+
+// Fields of models.User that are never cached
+var userCacheExcluded = map[string]bool{
+	"Password": true,
+}
+
+// Cached encoding of an unknown, newer version
+var errUserCacheVersion = errors.New("unknown user cache version")
+
+// Versioned cached user
+type userCacheEnvelope struct {
+	Version int             `json:"v"`
+	User    json.RawMessage `json:"user"`
+}
+
+// User as cached in redis, credentials are left out
+type cachedUser struct {
+	UserID        uuid.UUID             `json:"user_id"`
+	FirstName     string                `json:"first_name"`
+	LastName      string                `json:"last_name"`
+	Email         string                `json:"email,omitempty"`
+	Role          *string               `json:"role,omitempty"`
+	About         *string               `json:"about,omitempty"`
+	Avatar        *string               `json:"avatar,omitempty"`
+	Avatars       models.AvatarVariants `json:"avatars,omitempty"`
+	PhoneNumber   *string               `json:"phone_number,omitempty"`
+	Address       *string               `json:"address,omitempty"`
+	City          *string               `json:"city,omitempty"`
+	Country       *string               `json:"country,omitempty"`
+	Gender        *string               `json:"gender,omitempty"`
+	Postcode      *int                  `json:"postcode,omitempty"`
+	Birthday      *time.Time            `json:"birthday,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+	LoginDate     time.Time             `json:"login_date"`
+	Version       int                   `json:"version,omitempty"`
+	DeactivatedAt *time.Time            `json:"deactivated_at,omitempty"`
+	DeletedAt     *time.Time            `json:"deleted_at,omitempty"`
+}
+
+func newCachedUser(u *models.User) *cachedUser {
+	return &cachedUser{
+		UserID:        u.UserID,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Email:         u.Email,
+		Role:          u.Role,
+		About:         u.About,
+		Avatar:        u.Avatar,
+		Avatars:       u.Avatars,
+		PhoneNumber:   u.PhoneNumber,
+		Address:       u.Address,
+		City:          u.City,
+		Country:       u.Country,
+		Gender:        u.Gender,
+		Postcode:      u.Postcode,
+		Birthday:      u.Birthday,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		LoginDate:     u.LoginDate,
+		Version:       u.Version,
+		DeactivatedAt: u.DeactivatedAt,
+		DeletedAt:     u.DeletedAt,
+	}
+}
+
+func (c *cachedUser) user() *models.User {
+	return &models.User{
+		UserID:        c.UserID,
+		FirstName:     c.FirstName,
+		LastName:      c.LastName,
+		Email:         c.Email,
+		Role:          c.Role,
+		About:         c.About,
+		Avatar:        c.Avatar,
+		Avatars:       c.Avatars,
+		PhoneNumber:   c.PhoneNumber,
+		Address:       c.Address,
+		City:          c.City,
+		Country:       c.Country,
+		Gender:        c.Gender,
+		Postcode:      c.Postcode,
+		Birthday:      c.Birthday,
+		CreatedAt:     c.CreatedAt,
+		UpdatedAt:     c.UpdatedAt,
+		LoginDate:     c.LoginDate,
+		Version:       c.Version,
+		DeactivatedAt: c.DeactivatedAt,
+		DeletedAt:     c.DeletedAt,
+	}
+}
+
+// Encode user in the current cache version
+func encodeCachedUser(u *models.User) ([]byte, error) {
+	user, err := json.Marshal(newCachedUser(u))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&userCacheEnvelope{Version: userCacheVersion, User: user})
+}
+
+// Decode cached user of any known version, outdated is set when it should be encoded again
+func decodeCachedUser(data []byte) (user *models.User, outdated bool, err error) {
+	envelope := &userCacheEnvelope{}
+	if err = json.Unmarshal(data, envelope); err != nil {
+		return nil, false, err
+	}
+
+	cached := &cachedUser{}
+	switch {
+	case envelope.Version == 0:
+		// Version 1 has no envelope, the password hash is dropped as cachedUser has no field for it
+		if err = json.Unmarshal(data, cached); err != nil {
+			return nil, false, err
+		}
+		outdated = true
+	case envelope.Version == userCacheVersion:
+		if err = json.Unmarshal(envelope.User, cached); err != nil {
+			return nil, false, err
+		}
+	default:
+		return nil, false, errors.Wrapf(errUserCacheVersion, "version %d", envelope.Version)
+	}
+
+	return cached.user(), outdated, nil
+}
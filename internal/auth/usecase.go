@@ -5,15 +5,28 @@ import (
 	"context"
 
 	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 	"github.com/google/uuid"
 )
 
 type UseCase interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
 	Register(ctx context.Context, user *models.User) (*models.UserWithToken, error)
 	Login(ctx context.Context, user *models.User) (*models.UserWithToken, error)
-	FindByName(ctx context.Context, name string, query *utils.PaginationQuery) (*models.UsersList, error)
-	GetUsers(ctx context.Context, pq *utils.PaginationQuery) (*models.UsersList, error)
+	FindByName(ctx context.Context, search *models.UserSearch, query *utils.PaginationQuery) (*models.UserSearchList, error)
+	GetUsers(ctx context.Context, filter *models.UserFilter, pq *utils.PaginationQuery) (*models.UsersList, error)
 	GetByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+	Patch(ctx context.Context, userID uuid.UUID, ifMatch string, patch []byte) (*models.User, error)
 	UploadAvatar(ctx context.Context, userID uuid.UUID, file models.UploadInput) (*models.User, error)
+	PresignAvatar(ctx context.Context, userID uuid.UUID, contentType string, size int64) (*models.AvatarUpload, error)
+	ConfirmAvatar(ctx context.Context, userID uuid.UUID, uploadID uuid.UUID) (*models.User, error)
+	DeleteAvatar(ctx context.Context, userID uuid.UUID) (*models.User, error)
+	GetAvatar(ctx context.Context, userID uuid.UUID, size int) (*storage.Object, error)
+	ReconcileAvatars(ctx context.Context, remove bool) ([]storage.ObjectInfo, error)
+	Deactivate(ctx context.Context, userID uuid.UUID) error
+	Delete(ctx context.Context, userID uuid.UUID) error
+	Restore(ctx context.Context, user *models.User) (*models.User, error)
+	PurgeDeleted(ctx context.Context) (int64, error)
+	MigrateUserCache(ctx context.Context) (int, error)
 }
@@ -1,23 +1,37 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/config"
 	"github.com/fekuna/go-rest-clean-architecture/internal/auth"
 	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/cache"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/imaging"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
 
 const (
-	basePrefix    = "api-auth:"
-	cacheDuration = 3600
+	basePrefix      = "api-auth:"
+	cacheDuration   = 3600
+	avatarURLFormat = "/api/v1/auth/%s/avatar?size=%d&v=%s"
+
+	avatarUploadExpire  = 15 * time.Minute
+	maxAvatarUploadSize = 10 << 20 // 10MB
 )
 
 // Auth UseCase
@@ -26,28 +40,48 @@ type authUC struct {
 	authRepo  auth.Repository
 	redisRepo auth.RedisRepository
 	awsRepo   auth.AWSRepository
+	tx        postgres.Transactor
 	logger    logger.Logger
+	userCache *cache.Cache[*models.User]
 }
 
 // Auth UseCase constructor
-func NewAuthUseCase(cfg *config.Config, authRepo auth.Repository, redisRepo auth.RedisRepository, awsRepo auth.AWSRepository, log logger.Logger) auth.UseCase {
-	return &authUC{cfg: cfg, authRepo: authRepo, redisRepo: redisRepo, awsRepo: awsRepo, logger: log}
+func NewAuthUseCase(cfg *config.Config, authRepo auth.Repository, redisRepo auth.RedisRepository, awsRepo auth.AWSRepository, tx postgres.Transactor, bus cache.Bus, log logger.Logger) auth.UseCase {
+	return &authUC{
+		cfg:       cfg,
+		authRepo:  authRepo,
+		redisRepo: redisRepo,
+		awsRepo:   awsRepo,
+		tx:        tx,
+		logger:    log,
+		userCache: newUserCache(cfg, redisRepo, bus, log),
+	}
+}
+
+// Run fn in a transaction the repositories join through ctx
+func (u *authUC) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return u.tx.WithinTx(ctx, fn)
 }
 
 // Create new user
 func (u *authUC) Register(ctx context.Context, user *models.User) (*models.UserWithToken, error) {
 	// TODO: open tracing
 
-	existsUser, err := u.authRepo.FindByEmail(ctx, user)
-	if existsUser != nil || err == nil {
-		return nil, httpErrors.NewRestErrorWithMessage(http.StatusBadRequest, httpErrors.ErrEmailAlreadyExists, nil)
-	}
-
-	if err = user.PrepareCreate(); err != nil {
+	if err := user.PrepareCreate(); err != nil {
 		return nil, httpErrors.NewBadRequestError(errors.Wrap(err, "authUC.Register.PrepareCreate"))
 	}
 
-	createdUser, err := u.authRepo.Register(ctx, user)
+	// The email check and insert are one transaction so concurrent registrations can't both pass the check
+	var createdUser *models.User
+	err := u.WithinTx(ctx, func(ctx context.Context) error {
+		existsUser, err := u.authRepo.FindByEmail(ctx, user)
+		if existsUser != nil || err == nil {
+			return httpErrors.NewRestErrorWithMessage(http.StatusBadRequest, httpErrors.ErrEmailAlreadyExists, nil)
+		}
+
+		createdUser, err = u.authRepo.Register(ctx, user)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +98,8 @@ func (u *authUC) Register(ctx context.Context, user *models.User) (*models.UserW
 	}, nil
 }
 
-// Update existing user
+// Update existing user, a compare-and-swap on user.Version.
+// The password is not written, changing it would have to rotate the sessions of the user.
 func (u *authUC) Update(ctx context.Context, user *models.User) (*models.User, error) {
 	// TODO: Open Tracing
 
@@ -74,13 +109,106 @@ func (u *authUC) Update(ctx context.Context, user *models.User) (*models.User, e
 
 	updatedUser, err := u.authRepo.Update(ctx, user)
 	if err != nil {
+		// Changed by someone else since user.Version was read
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, u.preconditionFailed(ctx, user.UserID, err)
+		}
 		return nil, err
 	}
 
 	updatedUser.SanitizePassword()
 
-	if err = u.redisRepo.DeleteUserCtx(ctx, u.GenerateUserKey(user.UserID.String())); err != nil {
-		u.logger.Errorf("AuthUC.Update.DeleteUserCtx: %s", err)
+	if err = u.userCache.Delete(ctx, u.GenerateUserKey(user.UserID.String())); err != nil {
+		u.logger.Errorf("AuthUC.Update.userCache.Delete: %s", err)
+	}
+
+	updatedUser.SanitizePassword()
+
+	return updatedUser, nil
+}
+
+// Apply a JSON merge patch to the user, null clears a field and members left out stay as they are.
+// The user must still match the If-Match entity tags, the update is a compare-and-swap on its version.
+func (u *authUC) Patch(ctx context.Context, userID uuid.UUID, ifMatch string, patch []byte) (*models.User, error) {
+	// TODO: Open Tracing
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return nil, httpErrors.NewRestError(http.StatusBadRequest, "merge patch must be a JSON object", err)
+	}
+
+	for field, value := range members {
+		nullable, ok := models.UserPatchFields[field]
+		if !ok {
+			return nil, httpErrors.NewRestError(http.StatusBadRequest, fmt.Sprintf("field %s can't be patched", field), nil)
+		}
+		if !nullable && string(value) == "null" {
+			return nil, httpErrors.NewRestError(http.StatusBadRequest, fmt.Sprintf("field %s can't be cleared", field), nil)
+		}
+	}
+
+	// A lagging replica would fail the version check of a fresh entity tag
+	ctx = postgres.WithPrimary(ctx)
+	user, err := u.authRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !utils.MatchETag(ifMatch, user.ETag()) {
+		return nil, u.preconditionFailed(ctx, userID, nil)
+	}
+	if len(members) == 0 {
+		user.SanitizePassword()
+		return user, nil
+	}
+
+	doc, err := json.Marshal(user)
+	if err != nil {
+		return nil, errors.Wrap(err, "authUC.Patch.Marshal")
+	}
+
+	merged, err := utils.MergePatch(doc, patch)
+	if err != nil {
+		return nil, httpErrors.NewRestError(http.StatusBadRequest, "invalid merge patch", err)
+	}
+
+	patched := &models.User{}
+	if err = json.Unmarshal(merged, patched); err != nil {
+		return nil, httpErrors.NewRestError(http.StatusBadRequest, "invalid merge patch, "+err.Error(), err)
+	}
+	patched.UserID = user.UserID
+
+	if err = patched.PrepareUpdate(); err != nil {
+		return nil, httpErrors.NewBadRequestError(errors.Wrap(err, "authUC.Patch.PrepareUpdate"))
+	}
+	if err = utils.ValidateStruct(ctx, patched); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{}, len(members))
+	for field := range members {
+		value := patched.PatchValue(field)
+		// Validation lets empty values of optional formats through, fields that can't be cleared can't be blanked either
+		if !models.UserPatchFields[field] && isEmptyPatchValue(value) {
+			return nil, httpErrors.NewRestError(http.StatusBadRequest, fmt.Sprintf("field %s can't be empty", field), nil)
+		}
+		fields[field] = value
+	}
+
+	updatedUser, err := u.authRepo.Patch(ctx, userID, user.Version, fields)
+	if err != nil {
+		// Changed by someone else since it was read
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, u.preconditionFailed(ctx, userID, err)
+		}
+		// Email is the only unique patch field
+		if httpErrors.IsUniqueViolation(err) {
+			return nil, httpErrors.NewRestError(http.StatusConflict, httpErrors.ExistsEmailError.Error(), err)
+		}
+		return nil, err
+	}
+
+	if err = u.userCache.Delete(ctx, u.GenerateUserKey(userID.String())); err != nil {
+		u.logger.Errorf("AuthUC.Patch.userCache.Delete: %s", err)
 	}
 
 	updatedUser.SanitizePassword()
@@ -88,6 +216,25 @@ func (u *authUC) Update(ctx context.Context, user *models.User) (*models.User, e
 	return updatedUser, nil
 }
 
+// The entity tag the client sent may come from a stale cached copy, drop it so the next read gets the current version
+func (u *authUC) preconditionFailed(ctx context.Context, userID uuid.UUID, cause error) error {
+	if err := u.userCache.Delete(ctx, u.GenerateUserKey(userID.String())); err != nil {
+		u.logger.Errorf("AuthUC.preconditionFailed.userCache.Delete: %s", err)
+	}
+	return httpErrors.NewRestError(http.StatusPreconditionFailed, httpErrors.PreconditionFailed.Error(), cause)
+}
+
+func isEmptyPatchValue(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case *string:
+		return v == nil || *v == ""
+	default:
+		return value == nil
+	}
+}
+
 // Login user, returns user model with jwt token
 func (u *authUC) Login(ctx context.Context, user *models.User) (*models.UserWithToken, error) {
 	// TODO: open tracing
@@ -102,6 +249,10 @@ func (u *authUC) Login(ctx context.Context, user *models.User) (*models.UserWith
 		return nil, httpErrors.NewUnauthorizedError(errors.Wrap(err, "authUC.GetUsers.ComparePasswords"))
 	}
 
+	if foundUser.IsDeactivated() {
+		return nil, httpErrors.NewRestError(http.StatusForbidden, httpErrors.AccountDeactivated.Error(), nil)
+	}
+
 	foundUser.SanitizePassword()
 
 	token, err := utils.GenerateJWTToken(foundUser, u.cfg)
@@ -115,81 +266,385 @@ func (u *authUC) Login(ctx context.Context, user *models.User) (*models.UserWith
 	}, nil
 }
 
-// Find users by name
-func (u *authUC) FindByName(ctx context.Context, name string, query *utils.PaginationQuery) (*models.UsersList, error) {
+// Search users by name, email and city
+func (u *authUC) FindByName(ctx context.Context, search *models.UserSearch, query *utils.PaginationQuery) (*models.UserSearchList, error) {
 	// TODO: Open Tracing
-	return u.authRepo.FindByName(ctx, name, query)
+	search.Query = strings.TrimSpace(search.Query)
+	if search.Query == "" {
+		return nil, httpErrors.NewBadRequestError("name is required")
+	}
+
+	switch search.Mode {
+	case "":
+		search.Mode = models.SearchModeFuzzy
+	case models.SearchModeFuzzy, models.SearchModePrefix, models.SearchModeExact:
+	default:
+		return nil, httpErrors.NewRestError(http.StatusBadRequest, "invalid mode, must be prefix, fuzzy or exact", nil)
+	}
+
+	return u.authRepo.FindByName(ctx, search, query)
 }
 
-// Get users with paginate
-func (u *authUC) GetUsers(ctx context.Context, pq *utils.PaginationQuery) (*models.UsersList, error) {
+// Get filtered users with paginate
+func (u *authUC) GetUsers(ctx context.Context, filter *models.UserFilter, pq *utils.PaginationQuery) (*models.UsersList, error) {
 	// TODO: Open Tracing
 
-	return u.authRepo.GetUsers(ctx, pq)
+	return u.authRepo.GetUsers(ctx, filter, pq)
 }
 
 // Get user by ID
 func (u *authUC) GetByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
 	// TODO: Open Tracing
-	cachedUser, err := u.redisRepo.GetByIDCtx(ctx, u.GenerateUserKey(userID.String()))
+	cachedUser, err := u.userCache.Get(ctx, u.GenerateUserKey(userID.String()), func(ctx context.Context) (*models.User, error) {
+		// Fills outlive the replica lag, a replica could cache a user from before its last change
+		return u.authRepo.GetByID(postgres.WithPrimary(ctx), userID)
+	})
 	if err != nil {
-		u.logger.Errorf("authUC.GetByID.GetByIDCtx: %v", err)
+		return nil, err
 	}
 
-	if cachedUser != nil {
-		return cachedUser, nil
-	}
+	// Cached users are shared, callers get their own copy to modify
+	user := cachedUser.Clone()
+	user.SanitizePassword()
+
+	return user, nil
+}
+
+// Upload user avatar, stores every processed size of the image and removes the previous one
+func (u *authUC) UploadAvatar(ctx context.Context, userID uuid.UUID, file models.UploadInput) (*models.User, error) {
+	// TODO: Open Tracing
 
-	fmt.Println("Auth Repo Get By ID")
 	user, err := u.authRepo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = u.redisRepo.SetUserCtx(ctx, u.GenerateUserKey(userID.String()), cacheDuration, user); err != nil {
-		u.logger.Errorf("authUC.GetByID.SetUserCtx: %v", err)
+	data, err := io.ReadAll(file.File)
+	if err != nil {
+		return nil, httpErrors.NewBadRequestError(errors.Wrap(err, "authUC.UploadAvatar.ReadAll"))
 	}
 
-	user.SanitizePassword()
+	return u.storeAvatar(ctx, user, uuid.New().String(), data, u.avatarBucket())
+}
 
-	return user, nil
+// Presign a direct avatar upload, the bucket and object name are chosen by the server
+func (u *authUC) PresignAvatar(ctx context.Context, userID uuid.UUID, contentType string, size int64) (*models.AvatarUpload, error) {
+	// TODO: Open Tracing
+
+	if !utils.IsAllowedImageMimeType(contentType) {
+		return nil, httpErrors.NewBadRequestError(httpErrors.NotAllowedImageHeader.Error())
+	}
+	if size <= 0 || size > maxAvatarUploadSize {
+		return nil, httpErrors.NewBadRequestError(fmt.Sprintf("avatar size must be between 1 and %d bytes", maxAvatarUploadSize))
+	}
+
+	uploadID := uuid.New()
+	presigned, err := u.awsRepo.PresignPutObject(ctx, models.UploadInput{
+		Name:        utils.GetAvatarUploadName(userID.String(), uploadID.String()),
+		Size:        size,
+		ContentType: contentType,
+		BucketName:  u.avatarBucket(),
+	}, avatarUploadExpire)
+	if err != nil {
+		return nil, httpErrors.NewInternalServerError(errors.Wrap(err, "authUC.PresignAvatar.PresignPutObject"))
+	}
+
+	return &models.AvatarUpload{
+		UploadID:  uploadID,
+		Method:    presigned.Method,
+		URL:       presigned.URL,
+		Headers:   presigned.Headers,
+		MaxSize:   size,
+		ExpiresAt: presigned.ExpiresAt,
+	}, nil
 }
 
-// Upload user avatar
-func (u *authUC) UploadAvatar(ctx context.Context, userID uuid.UUID, file models.UploadInput) (*models.User, error) {
+// Confirm a direct avatar upload, the uploaded object is validated and processed like a regular upload
+func (u *authUC) ConfirmAvatar(ctx context.Context, userID uuid.UUID, uploadID uuid.UUID) (*models.User, error) {
 	// TODO: Open Tracing
 
-	fmt.Println("owkowkwok 1")
-	uploadInfo, err := u.awsRepo.PutObject(ctx, file)
-	fmt.Println("owkowkwok 2")
+	user, err := u.authRepo.GetByID(ctx, userID)
 	if err != nil {
-		return nil, httpErrors.NewInternalServerError(errors.Wrap(err, "authUC.UploadAvatar.PutObject"))
+		return nil, err
 	}
 
-	fmt.Println("owkowkwok 3")
-	avatarURL := u.generateAWSMinioURL(file.BucketName, uploadInfo.Key)
+	key := utils.GetAvatarUploadName(userID.String(), uploadID.String())
+	object, err := u.awsRepo.GetObject(ctx, u.avatarBucket(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, httpErrors.NewNotFoundError("avatar upload not found")
+		}
+		return nil, httpErrors.NewInternalServerError(errors.Wrap(err, "authUC.ConfirmAvatar.GetObject"))
+	}
+	defer object.Close()
 
-	fmt.Println("owkowkwok 4")
-	updatedUser, err := u.authRepo.Update(ctx, &models.User{
-		UserID: userID,
-		Avatar: &avatarURL,
-	})
+	if object.Info.Size > maxAvatarUploadSize {
+		u.removeAvatarUpload(ctx, key)
+		return nil, httpErrors.NewBadRequestError(fmt.Sprintf("avatar size must not exceed %d bytes", maxAvatarUploadSize))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(object, maxAvatarUploadSize+1))
+	if err != nil {
+		return nil, httpErrors.NewInternalServerError(errors.Wrap(err, "authUC.ConfirmAvatar.ReadAll"))
+	}
+	if len(data) > maxAvatarUploadSize {
+		u.removeAvatarUpload(ctx, key)
+		return nil, httpErrors.NewBadRequestError(fmt.Sprintf("avatar size must not exceed %d bytes", maxAvatarUploadSize))
+	}
+	if !utils.IsAllowedImageContentType(data) {
+		u.removeAvatarUpload(ctx, key)
+		return nil, httpErrors.NewBadRequestError(httpErrors.NotAllowedImageHeader.Error())
+	}
+
+	updatedUser, err := u.storeAvatar(ctx, user, uploadID.String(), data, u.avatarBucket())
 	if err != nil {
-		fmt.Println("owkowkwok error 1")
 		return nil, err
 	}
 
+	u.removeAvatarUpload(ctx, key)
+
+	return updatedUser, nil
+}
+
+// Process avatar image, store every size and replace the previous avatar of the user
+func (u *authUC) storeAvatar(ctx context.Context, user *models.User, uploadID string, data []byte, bucket string) (*models.User, error) {
+	variants, err := imaging.ProcessAvatar(data, imaging.DefaultAvatarOptions)
+	if err != nil {
+		return nil, httpErrors.NewBadRequestError(errors.Wrap(err, "authUC.storeAvatar.ProcessAvatar"))
+	}
+
+	avatars := make(models.AvatarVariants, 0, len(variants))
+	for _, variant := range variants {
+		uploadInfo, err := u.awsRepo.PutObject(ctx, models.UploadInput{
+			File:        bytes.NewReader(variant.Data),
+			Name:        utils.GetAvatarFileName(user.UserID.String(), uploadID, variant.Size, variant.Extension),
+			Size:        int64(len(variant.Data)),
+			ContentType: variant.ContentType,
+			BucketName:  bucket,
+		})
+		if err != nil {
+			u.removeAvatarObjects(ctx, avatars)
+			return nil, httpErrors.NewInternalServerError(errors.Wrap(err, "authUC.storeAvatar.PutObject"))
+		}
+
+		avatars = append(avatars, models.AvatarVariant{
+			Size:   variant.Size,
+			URL:    u.generateAvatarURL(user.UserID, variant.Size, uploadID),
+			Bucket: bucket,
+			Key:    uploadInfo.Key,
+		})
+	}
+
+	avatarURL := avatars.Largest().URL
+	// The replaced avatars come from the update, a lagging or concurrent read of the user may miss the current ones
+	updatedUser, previous, err := u.authRepo.UpdateAvatar(ctx, user.UserID, &avatarURL, avatars)
+	if err != nil {
+		u.removeAvatarObjects(ctx, avatars)
+		return nil, err
+	}
+
+	u.removeAvatarObjects(ctx, previous)
+	if err = u.userCache.Delete(ctx, u.GenerateUserKey(user.UserID.String())); err != nil {
+		u.logger.Errorf("authUC.storeAvatar.userCache.Delete: %s", err)
+	}
+
 	updatedUser.SanitizePassword()
 
 	return updatedUser, nil
 }
 
+// Delete user avatar with all stored sizes
+func (u *authUC) DeleteAvatar(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	// TODO: Open Tracing
+
+	updatedUser, previous, err := u.authRepo.UpdateAvatar(ctx, userID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	u.removeAvatarObjects(ctx, previous)
+	if err = u.userCache.Delete(ctx, u.GenerateUserKey(userID.String())); err != nil {
+		u.logger.Errorf("authUC.DeleteAvatar.userCache.Delete: %s", err)
+	}
+
+	updatedUser.SanitizePassword()
+
+	return updatedUser, nil
+}
+
+// Get avatar object, the smallest stored size not below the requested one, the caller must close it
+func (u *authUC) GetAvatar(ctx context.Context, userID uuid.UUID, size int) (*storage.Object, error) {
+	// TODO: Open Tracing
+
+	user, err := u.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	avatar := user.Avatars.Largest()
+	if avatar == nil {
+		return nil, httpErrors.NewNotFoundError("user has no avatar")
+	}
+	for i := range user.Avatars {
+		if user.Avatars[i].Size >= size {
+			avatar = &user.Avatars[i]
+			break
+		}
+	}
+
+	return u.awsRepo.GetObject(ctx, avatar.Bucket, avatar.Key)
+}
+
+// Find avatar objects no user references, optionally removing them.
+// Objects younger than the configured minimum age are skipped, they may belong to an upload in progress.
+func (u *authUC) ReconcileAvatars(ctx context.Context, remove bool) ([]storage.ObjectInfo, error) {
+	// TODO: Open Tracing
+
+	usersAvatars, err := u.authRepo.GetAvatars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]bool)
+	// Unconfirmed direct uploads are only found by listing the upload bucket
+	buckets := map[string]bool{u.avatarBucket(): true}
+	for _, avatars := range usersAvatars {
+		for _, avatar := range avatars {
+			referenced[avatar.Bucket+"/"+avatar.Key] = true
+			buckets[avatar.Bucket] = true
+		}
+	}
+
+	cutoff := time.Now().Add(-time.Duration(u.cfg.Jobs.AvatarOrphanMinAge) * time.Second)
+	orphans := make([]storage.ObjectInfo, 0)
+	for bucket := range buckets {
+		objects, err := u.awsRepo.ListObjects(ctx, bucket, utils.AvatarFilePrefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range objects {
+			if referenced[bucket+"/"+object.Key] || object.LastModified.After(cutoff) {
+				continue
+			}
+			orphans = append(orphans, object)
+
+			if remove {
+				if err = u.awsRepo.RemoveObject(ctx, bucket, object.Key); err != nil {
+					u.logger.Errorf("authUC.ReconcileAvatars.RemoveObject: %v", err)
+				}
+			}
+		}
+	}
+
+	return orphans, nil
+}
+
+// Best effort removal of stored avatar objects
+func (u *authUC) removeAvatarObjects(ctx context.Context, avatars models.AvatarVariants) {
+	for _, avatar := range avatars {
+		if err := u.awsRepo.RemoveObject(ctx, avatar.Bucket, avatar.Key); err != nil {
+			u.logger.Errorf("authUC.removeAvatarObjects.RemoveObject: %v", err)
+		}
+	}
+}
+
+// Best effort removal of a direct avatar upload
+func (u *authUC) removeAvatarUpload(ctx context.Context, key string) {
+	if err := u.awsRepo.RemoveObject(ctx, u.avatarBucket(), key); err != nil {
+		u.logger.Errorf("authUC.removeAvatarUpload.RemoveObject: %v", err)
+	}
+}
+
+// Deactivate user, sessions stop working until the account is restored
+func (u *authUC) Deactivate(ctx context.Context, userID uuid.UUID) error {
+	// TODO: Open Tracing
+
+	if err := u.authRepo.Deactivate(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := u.userCache.Delete(ctx, u.GenerateUserKey(userID.String())); err != nil {
+		u.logger.Errorf("AuthUC.Deactivate.userCache.Delete: %s", err)
+	}
+
+	return nil
+}
+
+// Soft delete user, it can be restored within the restore window and is purged after it
+func (u *authUC) Delete(ctx context.Context, userID uuid.UUID) error {
+	// TODO: Open Tracing
+
+	if err := u.authRepo.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := u.userCache.Delete(ctx, u.GenerateUserKey(userID.String())); err != nil {
+		u.logger.Errorf("AuthUC.Delete.userCache.Delete: %s", err)
+	}
+
+	return nil
+}
+
+// Restore deactivated or deleted user signing in with its credentials
+func (u *authUC) Restore(ctx context.Context, user *models.User) (*models.User, error) {
+	// TODO: Open Tracing
+
+	foundUser, err := u.authRepo.FindInactiveByEmail(ctx, strings.ToLower(strings.TrimSpace(user.Email)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = foundUser.ComparePasswords(user.Password); err != nil {
+		return nil, httpErrors.NewUnauthorizedError(errors.Wrap(err, "authUC.Restore.ComparePasswords"))
+	}
+
+	deletedAfter := time.Now().Add(-u.restoreWindow())
+	if foundUser.DeletedAt != nil && !foundUser.DeletedAt.After(deletedAfter) {
+		return nil, httpErrors.NewRestError(http.StatusGone, httpErrors.RestoreWindowExpired.Error(), nil)
+	}
+
+	restoredUser, err := u.authRepo.Restore(ctx, foundUser.UserID, deletedAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.userCache.Delete(ctx, u.GenerateUserKey(restoredUser.UserID.String())); err != nil {
+		u.logger.Errorf("AuthUC.Restore.userCache.Delete: %s", err)
+	}
+
+	restoredUser.SanitizePassword()
+
+	return restoredUser, nil
+}
+
+// Anonymise users deleted longer than the restore window ago
+func (u *authUC) PurgeDeleted(ctx context.Context) (int64, error) {
+	// TODO: Open Tracing
+	return u.authRepo.PurgeDeleted(ctx, time.Now().Add(-u.restoreWindow()))
+}
+
+// Cache users cached by older versions again without their credentials
+func (u *authUC) MigrateUserCache(ctx context.Context) (int, error) {
+	// TODO: Open Tracing
+	return u.redisRepo.MigrateUsersCtx(ctx, basePrefix+"*")
+}
+
+func (u *authUC) restoreWindow() time.Duration {
+	return time.Duration(u.cfg.Jobs.AccountRestoreWindow) * time.Second
+}
+
+// Avatar bucket is chosen by config, never by the client
+func (u *authUC) avatarBucket() string {
+	return u.cfg.Store.Buckets.Avatars.Name
+}
+
 // Generate User Key
 func (u *authUC) GenerateUserKey(userID string) string {
 	return fmt.Sprintf("%s: %s", basePrefix, userID)
 }
 
-// Generate AWS minio URL
-func (u *authUC) generateAWSMinioURL(bucket string, key string) string {
-	return fmt.Sprintf("%s/minio/%s/%s", u.cfg.AWS.MinioEndpoint, bucket, key)
+// Generate avatar proxy URL, the upload id busts client caches when the avatar is replaced
+func (u *authUC) generateAvatarURL(userID uuid.UUID, size int, uploadID string) string {
+	return fmt.Sprintf(avatarURLFormat, userID.String(), size, uploadID)
 }
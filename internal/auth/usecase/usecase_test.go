@@ -1,17 +1,27 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"fmt"
+	"image"
+	"image/png"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/config"
 	"github.com/fekuna/go-rest-clean-architecture/internal/auth/mock"
 	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -36,7 +46,7 @@ func TestAuthUC_Register(t *testing.T) {
 
 	apiLogger := logger.NewApiLogger(cfg)
 	mockAuthRepo := mock.NewMockRepository(ctrl)
-	authUC := NewAuthUseCase(cfg, mockAuthRepo, nil, nil, apiLogger)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, nil, nil, passThroughTx{}, nil, apiLogger)
 
 	user := &models.User{
 		Email:    "email@gmail.com",
@@ -76,7 +86,7 @@ func TestAuthUC_GetByID(t *testing.T) {
 	apiLogger := logger.NewApiLogger(cfg)
 	mockAuthRepo := mock.NewMockRepository(ctrl)
 	mockRedisRepo := mock.NewMockRedisRepository(ctrl)
-	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, apiLogger)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, passThroughTx{}, nil, apiLogger)
 
 	user := &models.User{
 		Password: "123456",
@@ -87,9 +97,9 @@ func TestAuthUC_GetByID(t *testing.T) {
 	ctx := context.Background()
 	// TODO: Open Tracing
 
-	mockRedisRepo.EXPECT().GetByIDCtx(ctx, key).Return(nil, nil)
-	mockAuthRepo.EXPECT().GetByID(ctx, gomock.Eq(user.UserID)).Return(user, nil)
-	mockRedisRepo.EXPECT().SetUserCtx(ctx, key, cacheDuration, user).Return(nil)
+	mockRedisRepo.EXPECT().GetByIDCtx(gomock.Any(), key).Return(nil, nil)
+	mockAuthRepo.EXPECT().GetByID(gomock.Any(), gomock.Eq(user.UserID)).Return(user, nil)
+	mockRedisRepo.EXPECT().SetUserCtx(gomock.Any(), key, cacheDuration, user).Return(nil)
 
 	u, err := authUC.GetByID(ctx, user.UserID)
 	require.NoError(t, err)
@@ -97,6 +107,166 @@ func TestAuthUC_GetByID(t *testing.T) {
 	require.NotNil(t, u)
 }
 
+func TestAuthUC_GetByIDCached(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := &config.Config{
+		Logger: config.Logger{
+			Development: true,
+			Encoding:    "json",
+		},
+		Cache: config.Cache{
+			LocalSize:   10,
+			LocalTTL:    60,
+			NegativeTTL: 60,
+		},
+	}
+
+	apiLogger := logger.NewApiLogger(cfg)
+	mockAuthRepo := mock.NewMockRepository(ctrl)
+	mockRedisRepo := mock.NewMockRedisRepository(ctrl)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, passThroughTx{}, nil, apiLogger)
+
+	ctx := context.Background()
+
+	t.Run("Local tier", func(t *testing.T) {
+		role := "user"
+		user := &models.User{UserID: uuid.New(), FirstName: "FirstName", Role: &role}
+		key := fmt.Sprintf("%s: %s", basePrefix, user.UserID)
+
+		mockRedisRepo.EXPECT().GetByIDCtx(gomock.Any(), key).Return(user, nil).Times(1)
+
+		for i := 0; i < 3; i++ {
+			u, err := authUC.GetByID(ctx, user.UserID)
+			require.NoError(t, err)
+			require.Equal(t, user.FirstName, u.FirstName)
+			require.NotSame(t, user, u)
+			require.NotSame(t, user.Role, u.Role)
+
+			*u.Role = models.RoleAdmin
+		}
+		require.Equal(t, "user", role)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		userID := uuid.New()
+		key := fmt.Sprintf("%s: %s", basePrefix, userID)
+
+		mockRedisRepo.EXPECT().GetByIDCtx(gomock.Any(), key).Return(nil, nil).Times(1)
+		mockAuthRepo.EXPECT().GetByID(gomock.Any(), userID).Return(nil, errors.Wrap(sql.ErrNoRows, "authRepo.GetByID.GetContext")).Times(1)
+
+		for i := 0; i < 2; i++ {
+			_, err := authUC.GetByID(ctx, userID)
+			require.ErrorIs(t, err, sql.ErrNoRows)
+		}
+	})
+}
+
+func TestAuthUC_Patch(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := &config.Config{
+		Logger: config.Logger{
+			Development: true,
+			Encoding:    "json",
+		},
+	}
+
+	apiLogger := logger.NewApiLogger(cfg)
+	mockAuthRepo := mock.NewMockRepository(ctrl)
+	mockRedisRepo := mock.NewMockRedisRepository(ctrl)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, passThroughTx{}, nil, apiLogger)
+
+	about := "about"
+	phone := "+62811"
+	user := &models.User{
+		UserID:      uuid.New(),
+		FirstName:   "FirstName",
+		LastName:    "LastName",
+		Email:       "email@gmail.com",
+		About:       &about,
+		PhoneNumber: &phone,
+		Version:     2,
+	}
+	key := fmt.Sprintf("%s: %s", basePrefix, user.UserID)
+	ctx := context.Background()
+	// Patch reads from the primary, the version it checks must not lag behind
+	primaryCtx := postgres.WithPrimary(ctx)
+
+	t.Run("Clear and set", func(t *testing.T) {
+		fields := map[string]interface{}{
+			"about":      (*string)(nil),
+			"first_name": "Renamed",
+		}
+
+		mockAuthRepo.EXPECT().GetByID(primaryCtx, user.UserID).Return(user, nil)
+		mockAuthRepo.EXPECT().Patch(primaryCtx, user.UserID, 2, gomock.Eq(fields)).Return(&models.User{UserID: user.UserID, FirstName: "Renamed", Version: 3}, nil)
+		mockRedisRepo.EXPECT().DeleteUserCtx(primaryCtx, key).Return(nil)
+
+		updated, err := authUC.Patch(ctx, user.UserID, `"2"`, []byte(`{"about": null, "first_name": "Renamed"}`))
+		require.NoError(t, err)
+		require.Equal(t, "Renamed", updated.FirstName)
+	})
+
+	t.Run("Required field", func(t *testing.T) {
+		_, err := authUC.Patch(ctx, user.UserID, `"2"`, []byte(`{"first_name": null}`))
+		require.Error(t, err)
+	})
+
+	t.Run("Unknown field", func(t *testing.T) {
+		_, err := authUC.Patch(ctx, user.UserID, `"2"`, []byte(`{"password": "secret"}`))
+		require.Error(t, err)
+	})
+
+	t.Run("Invalid value", func(t *testing.T) {
+		mockAuthRepo.EXPECT().GetByID(primaryCtx, user.UserID).Return(user, nil)
+
+		_, err := authUC.Patch(ctx, user.UserID, `"2"`, []byte(`{"email": "not an email"}`))
+		require.Error(t, err)
+	})
+
+	t.Run("Empty required field", func(t *testing.T) {
+		mockAuthRepo.EXPECT().GetByID(primaryCtx, user.UserID).Return(user, nil)
+
+		_, err := authUC.Patch(ctx, user.UserID, `"2"`, []byte(`{"email": " "}`))
+		require.Equal(t, http.StatusBadRequest, httpErrors.ParseErrors(err).Status())
+	})
+
+	t.Run("Taken email", func(t *testing.T) {
+		mockAuthRepo.EXPECT().GetByID(primaryCtx, user.UserID).Return(user, nil)
+		mockAuthRepo.EXPECT().Patch(primaryCtx, user.UserID, 2, gomock.Eq(map[string]interface{}{"email": "taken@gmail.com"})).
+			Return(nil, errors.Wrap(errors.New(`ERROR: duplicate key value violates unique constraint "users_email_key" (SQLSTATE 23505)`), "authRepo.Patch.GetContext"))
+
+		_, err := authUC.Patch(ctx, user.UserID, `"2"`, []byte(`{"email": "taken@gmail.com"}`))
+		require.Equal(t, http.StatusConflict, httpErrors.ParseErrors(err).Status())
+	})
+
+	t.Run("Stale version", func(t *testing.T) {
+		mockAuthRepo.EXPECT().GetByID(primaryCtx, user.UserID).Return(user, nil)
+
+		// The stale tag may have come from the cache, the next read must not serve it again
+		mockRedisRepo.EXPECT().DeleteUserCtx(primaryCtx, key).Return(nil)
+
+		_, err := authUC.Patch(ctx, user.UserID, `"1"`, []byte(`{"about": null}`))
+		require.Equal(t, http.StatusPreconditionFailed, httpErrors.ParseErrors(err).Status())
+	})
+
+	t.Run("Concurrent update", func(t *testing.T) {
+		mockAuthRepo.EXPECT().GetByID(primaryCtx, user.UserID).Return(user, nil)
+		mockAuthRepo.EXPECT().Patch(primaryCtx, user.UserID, 2, gomock.Any()).Return(nil, errors.Wrap(sql.ErrNoRows, "authRepo.Patch.GetContext"))
+		mockRedisRepo.EXPECT().DeleteUserCtx(primaryCtx, key).Return(nil)
+
+		_, err := authUC.Patch(ctx, user.UserID, `"2"`, []byte(`{"about": null}`))
+		require.Equal(t, http.StatusPreconditionFailed, httpErrors.ParseErrors(err).Status())
+	})
+}
+
 func TestAuthUC_FindByName(t *testing.T) {
 	t.Parallel()
 
@@ -118,9 +288,9 @@ func TestAuthUC_FindByName(t *testing.T) {
 	apiLogger := logger.NewApiLogger(cfg)
 	mockAuthRepo := mock.NewMockRepository(ctrl)
 	mockRedisRepo := mock.NewMockRedisRepository(ctrl)
-	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, apiLogger)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, passThroughTx{}, nil, apiLogger)
 
-	userName := "name"
+	search := &models.UserSearch{Query: " name "}
 	query := &utils.PaginationQuery{
 		Size:    10,
 		Page:    1,
@@ -129,14 +299,17 @@ func TestAuthUC_FindByName(t *testing.T) {
 	ctx := context.Background()
 	// TODO: Open Tracing
 
-	usersList := &models.UsersList{}
+	usersList := &models.UserSearchList{}
 
-	mockAuthRepo.EXPECT().FindByName(ctx, gomock.Eq(userName), query).Return(usersList, nil)
+	mockAuthRepo.EXPECT().FindByName(ctx, gomock.Eq(&models.UserSearch{Query: "name", Mode: models.SearchModeFuzzy}), query).Return(usersList, nil)
 
-	userList, err := authUC.FindByName(ctx, userName, query)
+	userList, err := authUC.FindByName(ctx, search, query)
 	require.NoError(t, err)
 	require.Nil(t, err)
 	require.NotNil(t, userList)
+
+	_, err = authUC.FindByName(ctx, &models.UserSearch{Query: "name", Mode: "regex"}, query)
+	require.Error(t, err)
 }
 
 func TestAuthUC_GetUsers(t *testing.T) {
@@ -160,7 +333,7 @@ func TestAuthUC_GetUsers(t *testing.T) {
 	apiLogger := logger.NewApiLogger(cfg)
 	mockAuthRepo := mock.NewMockRepository(ctrl)
 	mockRedisRepo := mock.NewMockRedisRepository(ctrl)
-	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, apiLogger)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, passThroughTx{}, nil, apiLogger)
 
 	query := &utils.PaginationQuery{
 		Size:    10,
@@ -172,9 +345,11 @@ func TestAuthUC_GetUsers(t *testing.T) {
 
 	usersList := &models.UsersList{}
 
-	mockAuthRepo.EXPECT().GetUsers(ctx, query).Return(usersList, nil)
+	filter := &models.UserFilter{}
 
-	users, err := authUC.GetUsers(ctx, query)
+	mockAuthRepo.EXPECT().GetUsers(ctx, filter, query).Return(usersList, nil)
+
+	users, err := authUC.GetUsers(ctx, filter, query)
 	require.NoError(t, err)
 	require.Nil(t, err)
 	require.NotNil(t, users)
@@ -201,7 +376,7 @@ func TestAuthUC_Login(t *testing.T) {
 	apiLogger := logger.NewApiLogger(cfg)
 	mockAuthRepo := mock.NewMockRepository(ctrl)
 	mockRedisRepo := mock.NewMockRedisRepository(ctrl)
-	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, apiLogger)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, passThroughTx{}, nil, apiLogger)
 
 	ctx := context.Background()
 	// TODO: Open Tracing
@@ -226,3 +401,265 @@ func TestAuthUC_Login(t *testing.T) {
 	require.Nil(t, err)
 	require.NotNil(t, userWithToken)
 }
+
+func TestAuthUC_UploadAvatar(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := &config.Config{
+		Store: config.Store{
+			Buckets: config.Buckets{
+				Avatars: config.Bucket{Name: "avatars", Public: true},
+			},
+		},
+		Server: config.ServerConfig{
+			JwtSecretKey: "secret",
+		},
+		Logger: config.Logger{
+			Development:       true,
+			DisableCaller:     false,
+			DisableStacktrace: false,
+			Encoding:          "json",
+		},
+	}
+
+	apiLogger := logger.NewApiLogger(cfg)
+	mockAuthRepo := mock.NewMockRepository(ctrl)
+	mockRedisRepo := mock.NewMockRedisRepository(ctrl)
+	mockAWSRepo := mock.NewMockAWSRepository(ctrl)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, mockAWSRepo, passThroughTx{}, nil, apiLogger)
+
+	ctx := context.Background()
+	// TODO: Open Tracing
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 600, 400))))
+
+	userID := uuid.New()
+	bucket := cfg.Store.Buckets.Avatars.Name
+	// A lagging read of the user, the update returns the avatars it actually replaced
+	stale := &models.User{
+		UserID:  userID,
+		Avatars: models.AvatarVariants{{Size: 64, Bucket: bucket, Key: "stale_64.png"}},
+	}
+	previous := models.AvatarVariants{{Size: 64, Bucket: bucket, Key: "old_64.png"}}
+
+	mockAuthRepo.EXPECT().GetByID(ctx, userID).Return(stale, nil)
+	mockAWSRepo.EXPECT().PutObject(ctx, gomock.Any()).Times(3).DoAndReturn(
+		func(ctx context.Context, input models.UploadInput) (*storage.ObjectInfo, error) {
+			require.Equal(t, bucket, input.BucketName)
+			require.Equal(t, "image/png", input.ContentType)
+			return &storage.ObjectInfo{Bucket: input.BucketName, Key: input.Name, Size: input.Size}, nil
+		})
+	mockAuthRepo.EXPECT().UpdateAvatar(ctx, userID, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, userID uuid.UUID, avatar *string, avatars models.AvatarVariants) (*models.User, models.AvatarVariants, error) {
+			require.Len(t, avatars, 3)
+			require.Equal(t, avatars.Largest().URL, *avatar)
+			return &models.User{UserID: userID, Avatar: avatar, Avatars: avatars}, previous, nil
+		})
+	mockAWSRepo.EXPECT().RemoveObject(ctx, bucket, "old_64.png").Return(nil)
+	mockRedisRepo.EXPECT().DeleteUserCtx(ctx, gomock.Any()).Return(nil)
+
+	updatedUser, err := authUC.UploadAvatar(ctx, userID, models.UploadInput{
+		File:       buf,
+		BucketName: "client-bucket",
+	})
+	require.NoError(t, err)
+	// The 400 pixel crop is not upscaled to 512
+	require.Equal(t, []int{64, 256, 400}, []int{
+		updatedUser.Avatars[0].Size, updatedUser.Avatars[1].Size, updatedUser.Avatars[2].Size,
+	})
+}
+
+type nopReadSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopReadSeekCloser) Close() error { return nil }
+
+func TestAuthUC_ConfirmAvatar(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := &config.Config{
+		Store: config.Store{
+			Buckets: config.Buckets{
+				Avatars: config.Bucket{Name: "avatars", Public: true},
+			},
+		},
+		Logger: config.Logger{
+			Development: true,
+			Encoding:    "json",
+		},
+	}
+
+	apiLogger := logger.NewApiLogger(cfg)
+	mockAuthRepo := mock.NewMockRepository(ctrl)
+	mockRedisRepo := mock.NewMockRedisRepository(ctrl)
+	mockAWSRepo := mock.NewMockAWSRepository(ctrl)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, mockAWSRepo, passThroughTx{}, nil, apiLogger)
+
+	ctx := context.Background()
+	bucket := cfg.Store.Buckets.Avatars.Name
+	userID := uuid.New()
+	uploadID := uuid.New()
+	uploadKey := utils.GetAvatarUploadName(userID.String(), uploadID.String())
+
+	t.Run("Valid image", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 300, 300))))
+
+		mockAuthRepo.EXPECT().GetByID(ctx, userID).Return(&models.User{UserID: userID}, nil)
+		mockAWSRepo.EXPECT().GetObject(ctx, bucket, uploadKey).Return(&storage.Object{
+			ReadSeekCloser: nopReadSeekCloser{bytes.NewReader(buf.Bytes())},
+			Info:           storage.ObjectInfo{Bucket: bucket, Key: uploadKey, Size: int64(buf.Len())},
+		}, nil)
+		mockAWSRepo.EXPECT().PutObject(ctx, gomock.Any()).Times(3).DoAndReturn(
+			func(ctx context.Context, input models.UploadInput) (*storage.ObjectInfo, error) {
+				require.Equal(t, bucket, input.BucketName)
+				return &storage.ObjectInfo{Bucket: input.BucketName, Key: input.Name, Size: input.Size}, nil
+			})
+		mockAuthRepo.EXPECT().UpdateAvatar(ctx, userID, gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, userID uuid.UUID, avatar *string, avatars models.AvatarVariants) (*models.User, models.AvatarVariants, error) {
+				return &models.User{UserID: userID, Avatar: avatar, Avatars: avatars}, nil, nil
+			})
+		mockRedisRepo.EXPECT().DeleteUserCtx(ctx, gomock.Any()).Return(nil)
+		mockAWSRepo.EXPECT().RemoveObject(ctx, bucket, uploadKey).Return(nil)
+
+		updatedUser, err := authUC.ConfirmAvatar(ctx, userID, uploadID)
+		require.NoError(t, err)
+		require.Len(t, updatedUser.Avatars, 3)
+	})
+
+	t.Run("Not an image", func(t *testing.T) {
+		data := []byte("<html>definitely not an image</html>")
+
+		mockAuthRepo.EXPECT().GetByID(ctx, userID).Return(&models.User{UserID: userID}, nil)
+		mockAWSRepo.EXPECT().GetObject(ctx, bucket, uploadKey).Return(&storage.Object{
+			ReadSeekCloser: nopReadSeekCloser{bytes.NewReader(data)},
+			Info:           storage.ObjectInfo{Bucket: bucket, Key: uploadKey, Size: int64(len(data))},
+		}, nil)
+		mockAWSRepo.EXPECT().RemoveObject(ctx, bucket, uploadKey).Return(nil)
+
+		_, err := authUC.ConfirmAvatar(ctx, userID, uploadID)
+		require.Error(t, err)
+	})
+}
+
+func TestAuthUC_ReconcileAvatars(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := &config.Config{
+		Store: config.Store{
+			Buckets: config.Buckets{
+				Avatars: config.Bucket{Name: "avatars", Public: true},
+			},
+		},
+		Logger: config.Logger{
+			Development: true,
+			Encoding:    "json",
+		},
+		Jobs: config.Jobs{
+			AvatarOrphanMinAge: 3600,
+		},
+	}
+
+	apiLogger := logger.NewApiLogger(cfg)
+	mockAuthRepo := mock.NewMockRepository(ctrl)
+	mockAWSRepo := mock.NewMockAWSRepository(ctrl)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, nil, mockAWSRepo, passThroughTx{}, nil, apiLogger)
+
+	ctx := context.Background()
+	bucket := cfg.Store.Buckets.Avatars.Name
+	old := time.Now().Add(-2 * time.Hour)
+
+	mockAuthRepo.EXPECT().GetAvatars(ctx).Return([]models.AvatarVariants{
+		{{Size: 64, Bucket: bucket, Key: "userid_1/a_64.png"}},
+	}, nil)
+	mockAWSRepo.EXPECT().ListObjects(ctx, bucket, utils.AvatarFilePrefix).Return([]storage.ObjectInfo{
+		{Bucket: bucket, Key: "userid_1/a_64.png", LastModified: old},
+		{Bucket: bucket, Key: "userid_1/orphan_64.png", LastModified: old},
+		{Bucket: bucket, Key: "userid_1/uploading_64.png", LastModified: time.Now()},
+	}, nil)
+	mockAWSRepo.EXPECT().RemoveObject(ctx, bucket, "userid_1/orphan_64.png").Return(nil)
+
+	orphans, err := authUC.ReconcileAvatars(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, orphans, 1)
+	require.Equal(t, "userid_1/orphan_64.png", orphans[0].Key)
+}
+
+func TestAuthUC_Restore(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := &config.Config{
+		Logger: config.Logger{
+			Development: true,
+			Encoding:    "json",
+		},
+		Jobs: config.Jobs{
+			AccountRestoreWindow: 3600,
+		},
+	}
+
+	apiLogger := logger.NewApiLogger(cfg)
+	mockAuthRepo := mock.NewMockRepository(ctrl)
+	mockRedisRepo := mock.NewMockRedisRepository(ctrl)
+	authUC := NewAuthUseCase(cfg, mockAuthRepo, mockRedisRepo, nil, passThroughTx{}, nil, apiLogger)
+
+	hashPassword, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	credentials := &models.User{Email: "Email@gmail.com", Password: "123456"}
+
+	t.Run("Within window", func(t *testing.T) {
+		deletedAt := time.Now().Add(-time.Minute)
+		user := &models.User{UserID: uuid.New(), Password: string(hashPassword), DeactivatedAt: &deletedAt, DeletedAt: &deletedAt}
+
+		mockAuthRepo.EXPECT().FindInactiveByEmail(ctx, "email@gmail.com").Return(user, nil)
+		mockAuthRepo.EXPECT().Restore(ctx, user.UserID, gomock.Any()).Return(&models.User{UserID: user.UserID, Password: string(hashPassword)}, nil)
+		mockRedisRepo.EXPECT().DeleteUserCtx(ctx, fmt.Sprintf("%s: %s", basePrefix, user.UserID)).Return(nil)
+
+		restored, err := authUC.Restore(ctx, credentials)
+		require.NoError(t, err)
+		require.Empty(t, restored.Password)
+	})
+
+	t.Run("Window expired", func(t *testing.T) {
+		deletedAt := time.Now().Add(-2 * time.Hour)
+		user := &models.User{UserID: uuid.New(), Password: string(hashPassword), DeactivatedAt: &deletedAt, DeletedAt: &deletedAt}
+
+		mockAuthRepo.EXPECT().FindInactiveByEmail(ctx, "email@gmail.com").Return(user, nil)
+
+		_, err := authUC.Restore(ctx, credentials)
+		require.Equal(t, http.StatusGone, httpErrors.ParseErrors(err).Status())
+	})
+
+	t.Run("Deactivated login", func(t *testing.T) {
+		deactivatedAt := time.Now()
+		user := &models.User{UserID: uuid.New(), Password: string(hashPassword), DeactivatedAt: &deactivatedAt}
+
+		mockAuthRepo.EXPECT().FindByEmail(ctx, gomock.Eq(credentials)).Return(user, nil)
+
+		_, err := authUC.Login(ctx, credentials)
+		require.Equal(t, http.StatusForbidden, httpErrors.ParseErrors(err).Status())
+	})
+}
+
+// Runs functions without a transaction, so mocks see the caller's context
+type passThroughTx struct{}
+
+func (passThroughTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/config"
+	"github.com/fekuna/go-rest-clean-architecture/internal/auth"
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/cache"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+const userCacheName = "users"
+
+// Users cache, an in-process LRU in front of redis kept in sync across instances by the bus, bus may be nil
+func newUserCache(cfg *config.Config, redisRepo auth.RedisRepository, bus cache.Bus, log logger.Logger) *cache.Cache[*models.User] {
+	remoteTTL := time.Duration(cfg.Cache.RemoteTTL) * time.Second
+	if remoteTTL <= 0 {
+		remoteTTL = cacheDuration * time.Second
+	}
+
+	return cache.New[*models.User](&userRedisCache{redisRepo: redisRepo}, cache.Options{
+		Name:        userCacheName,
+		LocalSize:   cfg.Cache.LocalSize,
+		LocalTTL:    time.Duration(cfg.Cache.LocalTTL) * time.Second,
+		RemoteTTL:   remoteTTL,
+		NegativeTTL: time.Duration(cfg.Cache.NegativeTTL) * time.Second,
+		Jitter:      cfg.Cache.Jitter,
+		IsNotFound: func(err error) bool {
+			return errors.Is(err, sql.ErrNoRows)
+		},
+		OnError: func(op string, key string, err error) {
+			log.Errorf("authUC.userCache.%s key: %s, error: %v", op, key, err)
+		},
+		Bus: bus,
+	})
+}
+
+// Redis tier of the users cache
+type userRedisCache struct {
+	redisRepo auth.RedisRepository
+}
+
+func (r *userRedisCache) Get(ctx context.Context, key string) (*models.User, bool, error) {
+	user, err := r.redisRepo.GetByIDCtx(ctx, key)
+	if err != nil || user == nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
+
+func (r *userRedisCache) Set(ctx context.Context, key string, user *models.User, ttl time.Duration) error {
+	seconds := int(ttl / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return r.redisRepo.SetUserCtx(ctx, key, seconds, user)
+}
+
+func (r *userRedisCache) Delete(ctx context.Context, key string) error {
+	return r.redisRepo.DeleteUserCtx(ctx, key)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/dgrijalva/jwt-go"
 	"github.com/fekuna/go-rest-clean-architecture/config"
 	"github.com/fekuna/go-rest-clean-architecture/internal/auth"
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 	"github.com/google/uuid"
@@ -30,10 +31,9 @@ func (mw *MiddlewareManager) AuthSessionMiddleware(next echo.HandlerFunc) echo.H
 
 		sid := cookie.Value
 
-		fmt.Println("[DEBUG]: session-id(cookie.Value)", sid)
 		sess, err := mw.sessUC.GetSessionByID(c.Request().Context(), cookie.Value)
 		if err != nil {
-			mw.logger.Errorf("GetSessionByID RequestID: %s, CookieValue: %s, Error: %s", utils.GetRequestID(c), cookie.Value, err.Error())
+			mw.logger.Errorf("GetSessionByID RequestID: %s, Error: %s", utils.GetRequestID(c), err.Error())
 
 			return c.JSON(http.StatusUnauthorized, httpErrors.NewUnauthorizedError(httpErrors.Unauthorized))
 		}
@@ -44,6 +44,13 @@ func (mw *MiddlewareManager) AuthSessionMiddleware(next echo.HandlerFunc) echo.H
 			return c.JSON(http.StatusUnauthorized, httpErrors.NewUnauthorizedError(httpErrors.Unauthorized))
 		}
 
+		if user.IsDeactivated() {
+			mw.logger.Errorf("AuthSessionMiddleware RequestID: %s, userID: %s, Error: %s", utils.GetRequestID(c), user.UserID.String(), httpErrors.AccountDeactivated)
+			return c.JSON(http.StatusUnauthorized, httpErrors.NewUnauthorizedError(httpErrors.AccountDeactivated))
+		}
+
+		mw.refreshSession(c, sid, sess)
+
 		c.Set("sid", sid)
 		c.Set("uid", sess.SessionID)
 		c.Set("user", user)
@@ -52,17 +59,65 @@ func (mw *MiddlewareManager) AuthSessionMiddleware(next echo.HandlerFunc) echo.H
 		c.SetRequest(c.Request().WithContext(ctx))
 
 		mw.logger.Info(
-			"SessionMiddleware, RequestID: %s, IP: %s, userID: %s, CookieSessionID: %s",
+			"SessionMiddleware, RequestID: %s, IP: %s, userID: %s, SessionID: %s",
 			utils.GetRequestID(c),
 			utils.GetIPAddress(c),
 			user.UserID.String(),
-			cookie.Value,
+			sess.SessionID,
 		)
 
 		return next(c)
 	}
 }
 
+// Optional auth sessions middleware, sets the user when the session is valid and lets anonymous requests through
+func (mw *MiddlewareManager) OptionalAuthSessionMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cookie, err := c.Cookie(mw.cfg.Session.Name)
+		if err != nil {
+			return next(c)
+		}
+
+		sess, err := mw.sessUC.GetSessionByID(c.Request().Context(), cookie.Value)
+		if err != nil {
+			mw.logger.Infof("OptionalAuthSessionMiddleware RequestID: %s, GetSessionByID: %s", utils.GetRequestID(c), err.Error())
+			return next(c)
+		}
+
+		user, err := mw.authUC.GetByID(c.Request().Context(), sess.UserID)
+		if err != nil {
+			mw.logger.Infof("OptionalAuthSessionMiddleware RequestID: %s, GetByID: %s", utils.GetRequestID(c), err.Error())
+			return next(c)
+		}
+		if user.IsDeactivated() {
+			return next(c)
+		}
+
+		mw.refreshSession(c, cookie.Value, sess)
+
+		c.Set("sid", cookie.Value)
+		c.Set("uid", sess.SessionID)
+		c.Set("user", user)
+
+		ctx := context.WithValue(c.Request().Context(), utils.UserCtxKey{}, user)
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		return next(c)
+	}
+}
+
+// Slide the session expiry on activity, the cookie is set again to expire with it
+func (mw *MiddlewareManager) refreshSession(c echo.Context, token string, sess *models.Session) {
+	refreshed, err := mw.sessUC.RefreshSession(c.Request().Context(), sess)
+	if err != nil {
+		mw.logger.Errorf("RefreshSession RequestID: %s, Error: %s", utils.GetRequestID(c), err.Error())
+		return
+	}
+	if refreshed {
+		c.SetCookie(utils.CreateSessionCookie(mw.cfg, token, sess.ExpiresAt))
+	}
+}
+
 // JWT way of auth using cookie or Authorization header
 func (mw *MiddlewareManager) AuthJWTMiddleware(authUC auth.UseCase, cfg *config.Config) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -142,6 +197,9 @@ func (mw *MiddlewareManager) validateJWTToken(tokenString string, authUC auth.Us
 		if err != nil {
 			return err
 		}
+		if u.IsDeactivated() {
+			return httpErrors.AccountDeactivated
+		}
 
 		c.Set("user", u)
 
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/fekuna/go-rest-clean-architecture/pkg/tlsconfig"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Client certificate Middleware, the identity of a verified client certificate is set as "client" and in the request context
+func (mw *MiddlewareManager) ClientIdentityMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		identity := tlsconfig.IdentityFromState(c.Request().TLS)
+		if identity == nil {
+			return next(c)
+		}
+
+		c.Set("client", identity)
+		ctx := context.WithValue(c.Request().Context(), utils.ClientIdentityCtxKey{}, identity)
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fekuna/go-rest-clean-architecture/pkg/csrf"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+var (
+	defaultCORSMethods = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodPost,
+		http.MethodDelete,
+	}
+	defaultCORSHeaders = []string{
+		echo.HeaderOrigin,
+		echo.HeaderContentType,
+		echo.HeaderAccept,
+		echo.HeaderXRequestID,
+		"If-Match",
+		csrf.CSRFHeader,
+	}
+	defaultCORSExposeHeaders = []string{csrf.CSRFHeader, echo.HeaderXRequestID, "ETag"}
+)
+
+// CORS Middleware of the manager origins and the CORS config, unset lists take the defaults above.
+// Origins may hold wildcards, * allows any and https://*.example.com the subdomains of example.com.
+func (mw *MiddlewareManager) CORS() (echo.MiddlewareFunc, error) {
+	// Echo allows any origin when none are set, with credentials it reflects the origin of every request
+	if len(mw.origins) == 0 && mw.cfg.CORS.AllowCredentials {
+		return nil, errors.New("cors credentials need the allowed origins to be set")
+	}
+	for _, origin := range mw.origins {
+		if origin == "*" && mw.cfg.CORS.AllowCredentials {
+			return nil, errors.New("cors credentials can't be allowed for any origin")
+		}
+	}
+
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins:     mw.origins,
+		AllowMethods:     orDefault(mw.cfg.CORS.AllowMethods, defaultCORSMethods),
+		AllowHeaders:     orDefault(mw.cfg.CORS.AllowHeaders, defaultCORSHeaders),
+		ExposeHeaders:    orDefault(mw.cfg.CORS.ExposeHeaders, defaultCORSExposeHeaders),
+		AllowCredentials: mw.cfg.CORS.AllowCredentials,
+		MaxAge:           mw.cfg.CORS.MaxAge,
+	}), nil
+}
+
+func orDefault(values []string, def []string) []string {
+	if len(values) == 0 {
+		return def
+	}
+	return values
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/fekuna/go-rest-clean-architecture/pkg/csrf"
@@ -9,30 +10,54 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-// CSRF Middleware
+// CSRF Middleware, unsafe methods must send the token of their session, with the matching cookie on double submit
 func (mw *MiddlewareManager) CSRF(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
-		if !mw.cfg.Server.CSRF {
+		if !mw.cfg.Server.CSRF || isSafeMethod(ctx.Request().Method) {
 			return next(ctx)
 		}
 
 		token := ctx.Request().Header.Get(csrf.CSRFHeader)
 		if token == "" {
-			mw.logger.Errorf("CSRF Middleware get CSRF header, Token: %s, Error: %s, RequestId: %s",
-				token,
-				"empty CSRF token",
-				utils.GetRequestID(ctx),
-			)
-			return ctx.JSON(http.StatusForbidden, httpErrors.NewRestError(http.StatusForbidden, "Invalid CSRF Token", "no CSRF Token"))
+			return mw.csrfError(ctx, httpErrors.CSRFNotPresented)
 		}
 
-		sid, ok := ctx.Get("sid").(string)
-		if !csrf.ValidateToken(token, sid, mw.logger) || !ok {
-			mw.logger.Errorf("CSRF Middleware csrf.ValidateToken Token: %s, Error: %s, RequestId: %s", token, "empty Token", utils.GetRequestID(ctx))
+		if mw.cfg.CSRF.DoubleSubmit {
+			cookie, err := ctx.Cookie(mw.cfg.CSRF.CookieName)
+			if err != nil {
+				return mw.csrfError(ctx, httpErrors.CSRFNotPresented)
+			}
+			if !csrf.Equal(cookie.Value, token) {
+				return mw.csrfError(ctx, httpErrors.WrongCSRFToken)
+			}
+		}
 
-			return ctx.JSON(http.StatusForbidden, httpErrors.NewRestError(http.StatusForbidden, "Invalid CSRF Token", "no CSRF Token"))
+		sid, ok := ctx.Get("sid").(string)
+		if !ok {
+			return mw.csrfError(ctx, httpErrors.WrongCSRFToken)
+		}
+		if err := mw.csrf.ValidateToken(token, sid); err != nil {
+			if errors.Is(err, csrf.ErrExpired) {
+				return mw.csrfError(ctx, httpErrors.ExpiredCSRFError)
+			}
+			return mw.csrfError(ctx, httpErrors.WrongCSRFToken)
 		}
 
 		return next(ctx)
 	}
 }
+
+func (mw *MiddlewareManager) csrfError(ctx echo.Context, err error) error {
+	mw.logger.Errorf("CSRF Middleware, Error: %s, RequestId: %s", err, utils.GetRequestID(ctx))
+	return ctx.JSON(http.StatusForbidden, httpErrors.NewRestError(http.StatusForbidden, err.Error(), nil))
+}
+
+// Methods that don't change state, RFC 7231
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
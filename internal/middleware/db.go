@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/fekuna/go-rest-clean-architecture/internal/session"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Read your writes middleware, a client that wrote recently reads from the primary instead of a lagging replica.
+// Clients are told apart by the stored id of their session, never by the token itself or a spoofable address.
+// A write that signs in counts for the session it starts.
+// The router's write tracker is shared by instances, when it fails reads go to the primary.
+func (mw *MiddlewareManager) ReadYourWritesMiddleware(router *postgres.Router) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			writers := make([]string, 0, 2)
+			if cookie, err := c.Cookie(mw.cfg.Session.Name); err == nil && cookie.Value != "" {
+				writer := session.HashToken(cookie.Value)
+				writers = append(writers, writer)
+
+				sticky, err := router.IsSticky(ctx, writer)
+				if err != nil {
+					mw.logger.Warnf("ReadYourWritesMiddleware IsSticky, RequestID: %s, Error: %s", utils.GetRequestID(c), err)
+				}
+				if sticky || err != nil {
+					c.SetRequest(c.Request().WithContext(postgres.WithPrimary(ctx)))
+				}
+			}
+
+			err := next(c)
+
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				if err == nil && c.Response().Status < http.StatusBadRequest {
+					writers = append(writers, mw.startedSessions(c)...)
+					for _, writer := range writers {
+						if markErr := router.MarkWrite(ctx, writer); markErr != nil {
+							mw.logger.Warnf("ReadYourWritesMiddleware MarkWrite, RequestID: %s, Error: %s", utils.GetRequestID(c), markErr)
+						}
+					}
+				}
+			}
+
+			return err
+		}
+	}
+}
+
+// Stored ids of the sessions the response sets cookies of
+func (mw *MiddlewareManager) startedSessions(c echo.Context) []string {
+	var started []string
+	for _, cookie := range (&http.Response{Header: c.Response().Header()}).Cookies() {
+		if cookie.Name == mw.cfg.Session.Name && cookie.Value != "" && cookie.MaxAge >= 0 {
+			started = append(started, session.HashToken(cookie.Value))
+		}
+	}
+	return started
+}
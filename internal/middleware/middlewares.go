@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"time"
+
 	"github.com/fekuna/go-rest-clean-architecture/config"
 	"github.com/fekuna/go-rest-clean-architecture/internal/auth"
 	"github.com/fekuna/go-rest-clean-architecture/internal/session"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/csrf"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
 )
 
@@ -13,10 +16,18 @@ type MiddlewareManager struct {
 	authUC  auth.UseCase
 	cfg     *config.Config
 	origins []string
+	csrf    *csrf.Signer
 	logger  logger.Logger
 }
 
 // Middleware manager constructor
 func NewMiddlewareManager(sessUC session.UCSession, authUC auth.UseCase, cfg *config.Config, origins []string, logger logger.Logger) *MiddlewareManager {
-	return &MiddlewareManager{sessUC: sessUC, authUC: authUC, cfg: cfg, origins: origins, logger: logger}
+	return &MiddlewareManager{
+		sessUC:  sessUC,
+		authUC:  authUC,
+		cfg:     cfg,
+		origins: origins,
+		csrf:    csrf.NewSigner([]byte(cfg.CSRF.Secret), time.Duration(cfg.CSRF.TTL)*time.Second),
+		logger:  logger,
+	}
 }
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fekuna/go-rest-clean-architecture/config"
+	"github.com/labstack/echo/v4"
+)
+
+// Security headers option, tunes the config headers of a route group
+type SecurityHeadersOption func(headers *config.SecurityHeaders)
+
+// Content security policy of the route group
+func WithContentSecurityPolicy(policy string) SecurityHeadersOption {
+	return func(headers *config.SecurityHeaders) {
+		headers.ContentSecurityPolicy = policy
+	}
+}
+
+// Referrer policy of the route group
+func WithReferrerPolicy(policy string) SecurityHeadersOption {
+	return func(headers *config.SecurityHeaders) {
+		headers.ReferrerPolicy = policy
+	}
+}
+
+// Permissions policy of the route group
+func WithPermissionsPolicy(policy string) SecurityHeadersOption {
+	return func(headers *config.SecurityHeaders) {
+		headers.PermissionsPolicy = policy
+	}
+}
+
+// Security headers Middleware of the config, options tune them for a route group.
+// Headers of a group middleware replace the ones set by the middleware of the server, empty values are left out.
+func (mw *MiddlewareManager) SecurityHeaders(opts ...SecurityHeadersOption) echo.MiddlewareFunc {
+	headers := mw.cfg.SecurityHeaders
+	for _, opt := range opts {
+		opt(&headers)
+	}
+
+	hsts := ""
+	if headers.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(headers.HSTSMaxAge)
+		if headers.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if headers.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			h := c.Response().Header()
+			setHeader(h, echo.HeaderContentSecurityPolicy, headers.ContentSecurityPolicy)
+			setHeader(h, echo.HeaderReferrerPolicy, headers.ReferrerPolicy)
+			setHeader(h, "Permissions-Policy", headers.PermissionsPolicy)
+			if headers.ContentTypeNosniff {
+				h.Set(echo.HeaderXContentTypeOptions, "nosniff")
+			}
+			// Browsers ignore HSTS over plain http
+			if c.Scheme() == "https" {
+				setHeader(h, echo.HeaderStrictTransportSecurity, hsts)
+			}
+			return next(c)
+		}
+	}
+}
+
+func setHeader(h http.Header, key string, value string) {
+	if value != "" {
+		h.Set(key, value)
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Avatar image variant
+type AvatarVariant struct {
+	Size   int    `json:"size"`
+	URL    string `json:"url"`
+	Bucket string `json:"bucket,omitempty"`
+	Key    string `json:"key,omitempty"`
+}
+
+// Avatar variants ordered from the smallest to the largest, stored as jsonb
+type AvatarVariants []AvatarVariant
+
+// Value implements driver.Valuer
+func (a AvatarVariants) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner
+func (a *AvatarVariants) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("AvatarVariants.Scan: unsupported type %T", src)
+	}
+}
+
+// Presigned avatar upload, the client sends the image to URL and then confirms the upload id
+type AvatarUpload struct {
+	UploadID  uuid.UUID         `json:"upload_id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	MaxSize   int64             `json:"max_size"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// Largest variant, nil if there are none
+func (a AvatarVariants) Largest() *AvatarVariant {
+	if len(a) == 0 {
+		return nil
+	}
+	return &a[len(a)-1]
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Who a user's fields are shown to, each level sees the fields of the levels below it
+type Visibility int
+
+const (
+	VisibilityPublic Visibility = iota
+	VisibilityOwner
+	VisibilityAdmin
+)
+
+// Lowest visibility each user field is shown at, keyed by its json name.
+// Fields missing here, like the password, are never shown.
+var UserFieldVisibility = map[string]Visibility{
+	"user_id":        VisibilityPublic,
+	"first_name":     VisibilityPublic,
+	"last_name":      VisibilityPublic,
+	"about":          VisibilityPublic,
+	"avatars":        VisibilityPublic,
+	"city":           VisibilityPublic,
+	"country":        VisibilityPublic,
+	"gender":         VisibilityPublic,
+	"created_at":     VisibilityPublic,
+	"email":          VisibilityOwner,
+	"role":           VisibilityOwner,
+	"phone_number":   VisibilityOwner,
+	"address":        VisibilityOwner,
+	"postcode":       VisibilityOwner,
+	"birthday":       VisibilityOwner,
+	"updated_at":     VisibilityOwner,
+	"login_date":     VisibilityAdmin,
+	"deactivated_at": VisibilityAdmin,
+}
+
+// Other names accepted for user fields
+var userFieldAliases = map[string]string{
+	"avatar": "avatars",
+}
+
+// Visibility of a user's fields to the viewer, a nil viewer is anonymous
+func UserVisibility(viewer *User, userID uuid.UUID) Visibility {
+	switch {
+	case viewer == nil:
+		return VisibilityPublic
+	case viewer.IsAdmin():
+		return VisibilityAdmin
+	case viewer.UserID == userID:
+		return VisibilityOwner
+	default:
+		return VisibilityPublic
+	}
+}
+
+// Parse comma separated user field names, empty means every visible field
+func ParseUserFields(fields string) ([]string, error) {
+	if strings.TrimSpace(fields) == "" {
+		return nil, nil
+	}
+
+	parsed := make([]string, 0)
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if alias, ok := userFieldAliases[field]; ok {
+			field = alias
+		}
+		if _, ok := UserFieldVisibility[field]; !ok {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		parsed = append(parsed, field)
+	}
+
+	return parsed, nil
+}
+
+// User fields picked for a viewer, encoded as json
+type UserProjection map[string]json.RawMessage
+
+// Project user to the requested fields the visibility allows, empty fields means all of them
+func (u *User) Project(visibility Visibility, fields []string) (UserProjection, error) {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+
+	all := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	projection := make(UserProjection, len(all))
+	for field, value := range all {
+		if fieldVisibility, ok := UserFieldVisibility[field]; !ok || fieldVisibility > visibility {
+			continue
+		}
+		if len(fields) > 0 && !containsField(fields, field) {
+			continue
+		}
+		projection[field] = value
+	}
+
+	return projection, nil
+}
+
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Users list response with projected users
+type UsersProjectionList struct {
+	Pagination
+	Users []UserProjection `json:"users"`
+}
+
+// Project every user of the list, each by the viewer's visibility of it
+func (l *UsersList) Project(viewer *User, fields []string) (*UsersProjectionList, error) {
+	projected := &UsersProjectionList{
+		Pagination: l.Pagination,
+		Users:      make([]UserProjection, 0, len(l.Users)),
+	}
+
+	for _, user := range l.Users {
+		projection, err := user.Project(UserVisibility(viewer, user.UserID), fields)
+		if err != nil {
+			return nil, err
+		}
+		projected.Users = append(projected.Users, projection)
+	}
+
+	return projected, nil
+}
+
+// User search response with projected hits
+type UserSearchProjectionList struct {
+	Pagination
+	Users []UserProjection `json:"users"`
+}
+
+// Project every hit of the search like users of a list, rank and highlight are kept as they only show public fields
+func (l *UserSearchList) Project(viewer *User, fields []string) (*UserSearchProjectionList, error) {
+	projected := &UserSearchProjectionList{
+		Pagination: l.Pagination,
+		Users:      make([]UserProjection, 0, len(l.Users)),
+	}
+
+	for _, hit := range l.Users {
+		projection, err := hit.User.Project(UserVisibility(viewer, hit.UserID), fields)
+		if err != nil {
+			return nil, err
+		}
+		if projection["rank"], err = json.Marshal(hit.Rank); err != nil {
+			return nil, err
+		}
+		if hit.Highlight != "" {
+			if projection["highlight"], err = json.Marshal(hit.Highlight); err != nil {
+				return nil, err
+			}
+		}
+		projected.Users = append(projected.Users, projection)
+	}
+
+	return projected, nil
+}
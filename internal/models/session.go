@@ -1,8 +1,23 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
 
+	"github.com/google/uuid"
+)
+
+// Session, its id is the hash of the token held by the client.
+// ExpiresAt slides with activity up to MaxExpiresAt.
 type Session struct {
-	SessionID string    `json:"session_id" redis:"session_id"`
-	UserID    uuid.UUID `json:"user_id" redis:"user_id"`
+	SessionID    string    `json:"session_id" db:"session_id" redis:"session_id"`
+	UserID       uuid.UUID `json:"user_id" db:"user_id" redis:"user_id"`
+	RememberMe   bool      `json:"remember_me" db:"remember_me" redis:"remember_me"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at" redis:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at" db:"expires_at" redis:"expires_at"`
+	MaxExpiresAt time.Time `json:"max_expires_at" db:"max_expires_at" redis:"max_expires_at"`
+}
+
+// Check if the session is past its absolute lifetime, sessions without one never are
+func (s *Session) IsPastLifetime(now time.Time) bool {
+	return !s.MaxExpiresAt.IsZero() && !now.Before(s.MaxExpiresAt)
 }
@@ -1,33 +1,86 @@
 package models
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/fekuna/go-rest-clean-architecture/pkg/query"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // User full model
 type User struct {
-	UserID      uuid.UUID  `json:"user_id" db:"user_id" redis:"user_id" validate:"omitempty"`
-	FirstName   string     `json:"first_name" db:"first_name" redis:"first_name" validate:"required,lte=30"`
-	LastName    string     `json:"last_name" db:"last_name" redis:"last_name" validate:"required,lte=30"`
-	Email       string     `json:"email,omitempty" db:"email" redis:"email" validate:"omitempty,lte=60,email"`
-	Password    string     `json:"password,omitempty" db:"password" redis:"password" validate:"omitempty,required,gte=6"`
-	Role        *string    `json:"role,omitempty" db:"role" redis:"role" validate:"omitempty,lte=10"`
-	About       *string    `json:"about,omitempty" db:"about" redis:"about" validate:"omitempty,lte=1024"`
-	Avatar      *string    `json:"avatar,omitempty" db:"avatar" redis:"avatar" validate:"omitempty,lte=512,url"`
-	PhoneNumber *string    `json:"phone_number,omitempty" db:"phone_number" redis:"phone_number" validate:"omitempty,lte=20"`
-	Address     *string    `json:"address,omitempty" db:"address" redis:"address" validate:"omitempty,lte=250"`
-	City        *string    `json:"city,omitempty" db:"city" redis:"city" validate:"omitempty,lte=24"`
-	Country     *string    `json:"country,omitempty" db:"country" redis:"country" validate:"omitempty,lte=24"`
-	Gender      *string    `json:"gender,omitempty" db:"gender" redis:"gender" validate:"omitempty,lte=10"`
-	Postcode    *int       `json:"postcode,omitempty" db:"postcode" redis:"postcode" validate:"omitempty"`
-	Birthday    *time.Time `json:"birthday,omitempty" db:"birthday" redis:"birthday" validate:"omitempty,lte=10"`
-	CreatedAt   time.Time  `json:"created_at,omitempty" db:"created_at" redis:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at,omitempty" db:"updated_at" redis:"updated_at"`
-	LoginDate   time.Time  `json:"login_date" db:"login_date" redis:"login_date"`
+	UserID      uuid.UUID      `json:"user_id" db:"user_id" redis:"user_id" validate:"omitempty"`
+	FirstName   string         `json:"first_name" db:"first_name" redis:"first_name" validate:"required,lte=30"`
+	LastName    string         `json:"last_name" db:"last_name" redis:"last_name" validate:"required,lte=30"`
+	Email       string         `json:"email,omitempty" db:"email" redis:"email" validate:"omitempty,lte=60,email"`
+	Password    string         `json:"password,omitempty" db:"password" redis:"password" validate:"omitempty,required,gte=6"`
+	Role        *string        `json:"role,omitempty" db:"role" redis:"role" validate:"omitempty,lte=10"`
+	About       *string        `json:"about,omitempty" db:"about" redis:"about" validate:"omitempty,lte=1024"`
+	Avatar      *string        `json:"-" db:"avatar" redis:"avatar" validate:"omitempty,lte=512,url"`
+	Avatars     AvatarVariants `json:"avatars,omitempty" db:"avatars" redis:"avatars" validate:"omitempty"`
+	PhoneNumber *string        `json:"phone_number,omitempty" db:"phone_number" redis:"phone_number" validate:"omitempty,lte=20"`
+	Address     *string        `json:"address,omitempty" db:"address" redis:"address" validate:"omitempty,lte=250"`
+	City        *string        `json:"city,omitempty" db:"city" redis:"city" validate:"omitempty,lte=24"`
+	Country     *string        `json:"country,omitempty" db:"country" redis:"country" validate:"omitempty,lte=24"`
+	Gender      *string        `json:"gender,omitempty" db:"gender" redis:"gender" validate:"omitempty,lte=10"`
+	Postcode    *int           `json:"postcode,omitempty" db:"postcode" redis:"postcode" validate:"omitempty"`
+	Birthday    *time.Time     `json:"birthday,omitempty" db:"birthday" redis:"birthday" validate:"omitempty,lte=10"`
+	CreatedAt   time.Time      `json:"created_at,omitempty" db:"created_at" redis:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at,omitempty" db:"updated_at" redis:"updated_at"`
+	LoginDate   time.Time      `json:"login_date" db:"login_date" redis:"login_date"`
+	Version     int            `json:"version,omitempty" db:"version" redis:"version"`
+
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty" db:"deactivated_at" redis:"deactivated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty" db:"deleted_at" redis:"deleted_at"`
+}
+
+const RoleAdmin = "admin"
+
+// Check if user has admin role
+func (u *User) IsAdmin() bool {
+	return u != nil && u.Role != nil && *u.Role == RoleAdmin
+}
+
+// Entity tag of the stored user version
+func (u *User) ETag() string {
+	return `"` + strconv.Itoa(u.Version) + `"`
+}
+
+// Check if user account is deactivated, deleted accounts are deactivated too
+func (u *User) IsDeactivated() bool {
+	return u.DeactivatedAt != nil
+}
+
+// Deep copy of the user, changes to it never reach the original
+func (u *User) Clone() *User {
+	clone := *u
+	clone.Role = clonePtr(u.Role)
+	clone.About = clonePtr(u.About)
+	clone.Avatar = clonePtr(u.Avatar)
+	clone.PhoneNumber = clonePtr(u.PhoneNumber)
+	clone.Address = clonePtr(u.Address)
+	clone.City = clonePtr(u.City)
+	clone.Country = clonePtr(u.Country)
+	clone.Gender = clonePtr(u.Gender)
+	clone.Postcode = clonePtr(u.Postcode)
+	clone.Birthday = clonePtr(u.Birthday)
+	clone.DeactivatedAt = clonePtr(u.DeactivatedAt)
+	clone.DeletedAt = clonePtr(u.DeletedAt)
+	if u.Avatars != nil {
+		clone.Avatars = append(AvatarVariants(nil), u.Avatars...)
+	}
+	return &clone
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
 }
 
 // Hash user password with bcrypt
@@ -89,12 +142,164 @@ func (u *User) PrepareUpdate() error {
 
 // All Users response
 type UsersList struct {
-	TotalCount int     `json:"total_count"`
-	TotalPages int     `json:"total_pages"`
-	Page       int     `json:"page"`
-	Size       int     `json:"size"`
-	HasMore    bool    `json:"has_more"`
-	Users      []*User `json:"users"`
+	Pagination
+	Users []*User `json:"users"`
+}
+
+// Fields users can be sorted by, mapped to their sort expressions.
+// Nullable columns are coalesced so cursors can compare them, SortValue must match.
+var UserSortFields = map[string]string{
+	"first_name": "first_name",
+	"last_name":  "last_name",
+	"email":      "email",
+	"role":       "role",
+	"city":       "COALESCE(city, '')",
+	"country":    "COALESCE(country, '')",
+	"gender":     "gender",
+	"birthday":   "COALESCE(birthday, '-infinity')",
+	"created_at": "created_at",
+	"updated_at": "COALESCE(updated_at, '-infinity')",
+	"login_date": "login_date",
+	"user_id":    "user_id",
+}
+
+// Value of a sort field as text, as compared by the sort expression
+func (u *User) SortValue(field string) string {
+	switch field {
+	case "first_name":
+		return u.FirstName
+	case "last_name":
+		return u.LastName
+	case "email":
+		return u.Email
+	case "role":
+		return stringValue(u.Role, "")
+	case "city":
+		return stringValue(u.City, "")
+	case "country":
+		return stringValue(u.Country, "")
+	case "gender":
+		return stringValue(u.Gender, "")
+	case "birthday":
+		if u.Birthday == nil {
+			return "-infinity"
+		}
+		return u.Birthday.Format("2006-01-02")
+	case "created_at":
+		return u.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		if u.UpdatedAt.IsZero() {
+			return "-infinity"
+		}
+		return u.UpdatedAt.Format(time.RFC3339Nano)
+	case "login_date":
+		return u.LoginDate.Format(time.RFC3339Nano)
+	case "user_id":
+		return u.UserID.String()
+	default:
+		return ""
+	}
+}
+
+func stringValue(s *string, def string) string {
+	if s == nil {
+		return def
+	}
+	return *s
+}
+
+// Users list filter, nil fields are not filtered by
+type UserFilter struct {
+	Role          *string
+	City          *string
+	Country       *string
+	Gender        *string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	HasAvatar     *bool
+	Sort          query.Sort
+}
+
+// User fields a merge patch may set, mapped to whether null clears them
+var UserPatchFields = map[string]bool{
+	"first_name":   false,
+	"last_name":    false,
+	"email":        false,
+	"role":         false,
+	"gender":       false,
+	"about":        true,
+	"phone_number": true,
+	"address":      true,
+	"city":         true,
+	"country":      true,
+	"postcode":     true,
+	"birthday":     true,
+}
+
+// Value of a patch field, its column has the same name
+func (u *User) PatchValue(field string) interface{} {
+	switch field {
+	case "first_name":
+		return u.FirstName
+	case "last_name":
+		return u.LastName
+	case "email":
+		return u.Email
+	case "role":
+		return u.Role
+	case "gender":
+		return u.Gender
+	case "about":
+		return u.About
+	case "phone_number":
+		return u.PhoneNumber
+	case "address":
+		return u.Address
+	case "city":
+		return u.City
+	case "country":
+		return u.Country
+	case "postcode":
+		return u.Postcode
+	case "birthday":
+		return u.Birthday
+	default:
+		return nil
+	}
+}
+
+// User search modes
+const (
+	SearchModeFuzzy  = "fuzzy"
+	SearchModePrefix = "prefix"
+	SearchModeExact  = "exact"
+)
+
+// User search query
+type UserSearch struct {
+	Query string
+	Mode  string
+}
+
+// User search hit with its relevance and the matched text highlighted, the highlight is escaped HTML with the matches in mark tags
+type UserSearchHit struct {
+	User
+	Rank      float32 `json:"rank" db:"rank"`
+	Highlight string  `json:"highlight,omitempty" db:"highlight"`
+}
+
+// Value of a sort field as text, rank included
+func (h *UserSearchHit) SortValue(field string) string {
+	if field == "rank" {
+		return strconv.FormatFloat(float64(h.Rank), 'g', -1, 32)
+	}
+	return h.User.SortValue(field)
+}
+
+// User search response
+type UserSearchList struct {
+	Pagination
+	Users []*UserSearchHit `json:"users"`
 }
 
 // Find user query
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Config keys whose values are replaced in the admin config dump, matched case insensitively on their name
+var redactedConfigKeys = []string{"password", "secret", "token", "signingkey", "accesskey", "uri"}
+
+const redacted = "[REDACTED]"
+
+// Admin listener, nil when disabled
+func (s *Server) newAdminServer() (*http.Server, error) {
+	if !s.cfg.Admin.Enabled {
+		return nil, nil
+	}
+	if s.cfg.Admin.Token == "" && !isLoopback(s.cfg.Admin.Addr) {
+		return nil, fmt.Errorf("admin listener on %q requires a token or a loopback address", s.cfg.Admin.Addr)
+	}
+
+	return &http.Server{
+		Addr:              s.cfg.Admin.Addr,
+		Handler:           s.adminAuth(s.adminMux()),
+		ReadHeaderTimeout: 5 * time.Second,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}, nil
+}
+
+// Admin routes, profiles take as long as they are asked to so no write timeout is set
+func (s *Server) adminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	mux.HandleFunc("/buildinfo", s.adminBuildInfo)
+	mux.HandleFunc("/config", s.adminConfig)
+	mux.HandleFunc("/loglevel", s.adminLogLevel)
+
+	return mux
+}
+
+// Require the bearer admin token when one is set, the comparison is constant time.
+// Without one the listener is loopback only and requests must name a loopback host, so pages rebinding
+// their DNS name to 127.0.0.1 can't reach it from a browser.
+func (s *Server) adminAuth(next http.Handler) http.Handler {
+	if s.cfg.Admin.Token == "" {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isLoopbackHost(r.Host) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	want := sha256.Sum256([]byte(s.cfg.Admin.Token))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		got := sha256.Sum256([]byte(token))
+		if subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) adminBuildInfo(w http.ResponseWriter, r *http.Request) {
+	info := map[string]interface{}{
+		"app_version": s.cfg.Server.AppVersion,
+		"go_version":  runtime.Version(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info["path"] = build.Path
+		info["main"] = build.Main
+		settings := make(map[string]string, len(build.Settings))
+		for _, setting := range build.Settings {
+			settings[setting.Key] = setting.Value
+		}
+		info["settings"] = settings
+	}
+	writeAdminJSON(w, http.StatusOK, info)
+}
+
+// Runtime config with secrets redacted
+func (s *Server) adminConfig(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(s.cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var cfg interface{}
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, redactConfig(cfg))
+}
+
+// Get the log level, or change it with PUT {"level": "debug"}
+func (s *Server) adminLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.logger.SetLevel(strings.ToLower(req.Level)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Infof("Admin log level changed to %s", req.Level)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]string{"level": s.logger.Level()})
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Replace the non empty values of secret keys in decoded json
+func redactConfig(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if isRedactedKey(key) && field != "" && field != nil {
+				value[key] = redacted
+				continue
+			}
+			value[key] = redactConfig(field)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactConfig(item)
+		}
+	}
+	return v
+}
+
+func isRedactedKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range redactedConfigKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the listen address only binds loopback interfaces, an empty host binds every interface
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	return isLoopbackName(host)
+}
+
+// Check if the Host header of a request names a loopback address, the port is optional
+func isLoopbackHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = strings.Trim(hostport, "[]")
+	}
+	return isLoopbackName(host)
+}
+
+func isLoopbackName(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Serve the admin listener, closed by graceful shutdown
+func (s *Server) runAdminServer(admin *http.Server) {
+	if admin == nil {
+		return
+	}
+	go func() {
+		s.logger.Infof("Admin server is listening on %s", admin.Addr)
+		if err := admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorf("Error admin ListenAndServe: %s", err)
+		}
+	}()
+}
+
+// Stop the admin listener, in flight profiles are cut off by ctx
+func (s *Server) shutdownAdminServer(ctx context.Context, admin *http.Server) {
+	if admin == nil {
+		return
+	}
+	if err := admin.Shutdown(ctx); err != nil {
+		s.logger.Errorf("Error admin Shutdown: %s", err)
+	}
+}
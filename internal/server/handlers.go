@@ -1,14 +1,21 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
 	authHttp "github.com/fekuna/go-rest-clean-architecture/internal/auth/delivery/http"
 	authRepository "github.com/fekuna/go-rest-clean-architecture/internal/auth/repository"
 	authUseCase "github.com/fekuna/go-rest-clean-architecture/internal/auth/usecase"
 	apiMiddlewares "github.com/fekuna/go-rest-clean-architecture/internal/middleware"
+	"github.com/fekuna/go-rest-clean-architecture/internal/session"
 	sessRepository "github.com/fekuna/go-rest-clean-architecture/internal/session/repository"
 	"github.com/fekuna/go-rest-clean-architecture/internal/session/usecase"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/cache"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/csrf"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/utils"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -18,31 +25,114 @@ import (
 func (s *Server) MapHandlers(e *echo.Echo) error {
 	// TODO: setup metrics
 
+	if s.cfg.Server.CSRF && s.cfg.CSRF.Secret == "" {
+		// Tokens of a generated secret don't survive restarts or work across instances
+		if s.cfg.Server.Mode != "Development" {
+			return errors.New("csrf secret is required when csrf is enabled, set CSRF_SECRET")
+		}
+		secret, err := csrf.NewSecret()
+		if err != nil {
+			return err
+		}
+		s.cfg.CSRF.Secret = string(secret)
+		s.logger.Warn("CSRF_SECRET is not set, signing csrf tokens with a generated secret")
+	}
+
 	// Init repositories
 	aRepo := authRepository.NewAuthRepository(s.db)
-	sRepo := sessRepository.NewSessionRepository(s.redisClient, s.cfg)
+	sessStore, err := sessRepository.NewStore(s.cfg, s.redisClient, s.db)
+	if err != nil {
+		return err
+	}
 	authRedisRepo := authRepository.NewAuthRedisRepo(s.redisClient)
-	aAWSRepo := authRepository.NewAuthAWSRepository(s.awsClient)
+	aAWSRepo := authRepository.NewAuthAWSRepository(s.store)
 
 	// Init useCase
-	authUC := authUseCase.NewAuthUseCase(s.cfg, aRepo, authRedisRepo, aAWSRepo, s.logger)
-	sessUC := usecase.NewSessionUseCase(sRepo, s.cfg)
+	txManager := postgres.NewTxManager(s.db.Primary())
+	var cacheBus cache.Bus
+	if s.cfg.Cache.InvalidationChannel != "" {
+		redisBus := cache.NewRedisBus(s.redisClient, s.cfg.Cache.InvalidationChannel, s.logger)
+		s.addWorker("cache-invalidation", redisBus.Run)
+		cacheBus = redisBus
+	}
+	authUC := authUseCase.NewAuthUseCase(s.cfg, aRepo, authRedisRepo, aAWSRepo, txManager, cacheBus, s.logger)
+
+	var legacySessions session.LegacyStore
+	if s.cfg.Session.MigrateLegacy {
+		legacySessions = sessRepository.NewLegacyRedisStore(s.redisClient)
+	}
+	sessUC := usecase.NewSessionUseCase(sessStore, legacySessions, s.cfg)
+
+	if s.cfg.Session.MigrateLegacy {
+		s.addWorker("session-migration", func(ctx context.Context) {
+			migrated, err := sessUC.MigrateLegacy(ctx)
+			if err != nil {
+				s.logger.Errorf("Session migration: %s", err)
+				return
+			}
+			s.logger.Infof("Session migration moved %d sessions to the session store", migrated)
+		})
+	}
+
+	s.addJob("session-cleanup", s.cfg.Jobs.SessionCleanupInterval, func(ctx context.Context) error {
+		deleted, err := sessUC.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+		s.logger.Infof("Session cleanup removed %d expired sessions", deleted)
+		return nil
+	})
+
+	s.addWorker("user-cache-migration", func(ctx context.Context) {
+		migrated, err := authUC.MigrateUserCache(ctx)
+		if err != nil {
+			s.logger.Errorf("User cache migration: %s", err)
+			return
+		}
+		s.logger.Infof("User cache migration cached %d users again", migrated)
+	})
+
+	s.addJob("replica-check", s.cfg.Postgres.ReplicaCheckInterval, s.db.CheckReplicas)
+
+	s.addJob("avatar-reconcile", s.cfg.Jobs.AvatarReconcileInterval, func(ctx context.Context) error {
+		orphans, err := authUC.ReconcileAvatars(ctx, s.cfg.Jobs.AvatarReconcileDelete)
+		if err != nil {
+			return err
+		}
+		s.logger.Infof("Avatar reconcile found %d orphaned objects, removed: %v", len(orphans), s.cfg.Jobs.AvatarReconcileDelete)
+		return nil
+	})
+
+	s.addJob("account-purge", s.cfg.Jobs.AccountPurgeInterval, func(ctx context.Context) error {
+		purged, err := authUC.PurgeDeleted(ctx)
+		if err != nil {
+			return err
+		}
+		s.logger.Infof("Account purge anonymised %d deleted users", purged)
+		return nil
+	})
 
 	// Init handlers
 	authHandlers := authHttp.NewAuthHandlers(s.cfg, authUC, sessUC, s.logger)
 
-	mw := apiMiddlewares.NewMiddlewareManager(sessUC, authUC, s.cfg, []string{"*"}, s.logger)
+	mw := apiMiddlewares.NewMiddlewareManager(sessUC, authUC, s.cfg, s.cfg.CORS.AllowOrigins, s.logger)
 
 	e.Use(mw.RequestLoggerMiddleware)
+	if s.cfg.Server.SSL && s.cfg.Server.TLS.ClientCAFile != "" {
+		e.Use(mw.ClientIdentityMiddleware)
+	}
+	e.Use(mw.ReadYourWritesMiddleware(s.db))
 
 	if s.cfg.Server.SSL {
 		e.Pre(middleware.HTTPSRedirect())
 	}
 
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderXRequestID},
-	}))
+	cors, err := mw.CORS()
+	if err != nil {
+		return err
+	}
+	e.Use(cors)
+	e.Use(mw.SecurityHeaders())
 	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
 		StackSize:         1 << 10, // 1KB
 		DisablePrintStack: true,
@@ -58,10 +148,21 @@ func (s *Server) MapHandlers(e *echo.Echo) error {
 
 	authHttp.MapAuthRoutes(authGroup, authHandlers, mw)
 
+	if s.cfg.Store.Driver == storage.DriverFS {
+		// Uploaded files are sandboxed so a stored document can't run scripts on the api origin
+		filesHeaders := mw.SecurityHeaders(apiMiddlewares.WithContentSecurityPolicy("default-src 'none'; img-src 'self'; sandbox"))
+		v1.GET("/files/:bucket/*", storage.FileHandler(s.store, storage.PublicBuckets(s.cfg)...), filesHeaders)
+		v1.PUT("/files/:bucket/*", storage.UploadHandler(s.store))
+	}
+
 	health.GET("", func(c echo.Context) error {
 		s.logger.Infof("Health check RequestID: %s", utils.GetRequestID(c))
 		return c.JSON(http.StatusOK, map[string]string{"status": "OK"})
 	})
 
+	health.GET("/cache", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, cache.AllStats())
+	})
+
 	return nil
 }
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// Periodic background job
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// Background worker started once with the jobs, long running ones return when their context is cancelled
+type worker struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+// Register background job, a non positive interval in seconds disables it
+func (s *Server) addJob(name string, intervalSeconds int, run func(ctx context.Context) error) {
+	if intervalSeconds <= 0 {
+		s.logger.Infof("Job %s disabled", name)
+		return
+	}
+	s.jobs = append(s.jobs, job{name: name, interval: time.Duration(intervalSeconds) * time.Second, run: run})
+}
+
+// Register background worker
+func (s *Server) addWorker(name string, run func(ctx context.Context)) {
+	s.workers = append(s.workers, worker{name: name, run: run})
+}
+
+// Start registered jobs and workers, they stop when ctx is cancelled
+func (s *Server) startJobs(ctx context.Context) {
+	for _, j := range s.jobs {
+		go s.runJob(ctx, j)
+	}
+	for _, w := range s.workers {
+		s.logger.Infof("Worker %s started", w.name)
+		go w.run(ctx)
+	}
+}
+
+func (s *Server) runJob(ctx context.Context, j job) {
+	s.logger.Infof("Job %s started, interval: %s", j.name, j.interval)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.run(ctx); err != nil {
+				s.logger.Errorf("Job %s: %s", j.name, err)
+			}
+		}
+	}
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
@@ -9,14 +11,16 @@ import (
 	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/config"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/storage"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/tlsconfig"
 	"github.com/go-redis/redis/v8"
-	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
-	"github.com/minio/minio-go/v7"
 )
 
 const (
+	// Certificate files when the TLS config has none
 	certFile       = "ssl/server.crt"
 	keyFile        = "ssl/server.pem"
 	maxHeaderBytes = 1 << 20
@@ -27,15 +31,17 @@ const (
 type Server struct {
 	echo        *echo.Echo
 	cfg         *config.Config
-	db          *sqlx.DB
+	db          *postgres.Router
 	redisClient *redis.Client
-	awsClient   *minio.Client
+	store       storage.ObjectStore
 	logger      logger.Logger
+	jobs        []job
+	workers     []worker
 }
 
 // NewServer New Server Constructor
-func NewServer(cfg *config.Config, db *sqlx.DB, redisClient *redis.Client, awsS3Client *minio.Client, logger logger.Logger) *Server {
-	return &Server{echo: echo.New(), cfg: cfg, db: db, redisClient: redisClient, awsClient: awsS3Client, logger: logger}
+func NewServer(cfg *config.Config, db *postgres.Router, redisClient *redis.Client, store storage.ObjectStore, logger logger.Logger) *Server {
+	return &Server{echo: echo.New(), cfg: cfg, db: db, redisClient: redisClient, store: store, logger: logger}
 }
 
 func (s *Server) Run() error {
@@ -44,25 +50,35 @@ func (s *Server) Run() error {
 			return err
 		}
 
-		s.echo.Server.ReadTimeout = time.Second * s.cfg.Server.ReadTimeout
-		s.echo.Server.WriteTimeout = time.Second * s.cfg.Server.WriteTimeout
+		tlsConfig, err := s.tlsConfig()
+		if err != nil {
+			return err
+		}
+		admin, err := s.newAdminServer()
+		if err != nil {
+			return err
+		}
+
+		jobsCtx, cancelJobs := context.WithCancel(context.Background())
+		defer cancelJobs()
+		s.startJobs(jobsCtx)
+
+		server := &http.Server{
+			Addr:           s.cfg.Server.Port,
+			ReadTimeout:    time.Second * s.cfg.Server.ReadTimeout,
+			WriteTimeout:   time.Second * s.cfg.Server.WriteTimeout,
+			MaxHeaderBytes: maxHeaderBytes,
+			TLSConfig:      tlsConfig,
+		}
 
 		go func() {
 			s.logger.Infof("server is listening on PORT: %s", s.cfg.Server.Port)
-			s.echo.Server.ReadTimeout = time.Second * s.cfg.Server.ReadTimeout
-			s.echo.Server.WriteTimeout = time.Second * s.cfg.Server.WriteTimeout
-			s.echo.Server.MaxHeaderBytes = maxHeaderBytes
-			if err := s.echo.StartTLS(s.cfg.Server.Port, certFile, keyFile); err != nil {
+			if err := s.echo.StartServer(server); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				s.logger.Fatalf("Error starting TLS Server: ", err)
 			}
 		}()
 
-		go func() {
-			s.logger.Infof("Starting Debug Server on PORT: %s", s.cfg.Server.PprofPort)
-			if err := http.ListenAndServe(s.cfg.Server.PprofPort, http.DefaultServeMux); err != nil {
-				s.logger.Errorf("Error PPROF ListenAndServe: %s", err)
-			}
-		}()
+		s.runAdminServer(admin)
 
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
@@ -72,8 +88,9 @@ func (s *Server) Run() error {
 		ctx, shutdown := context.WithTimeout(context.Background(), ctxTimeout*time.Second)
 		defer shutdown()
 
+		s.shutdownAdminServer(ctx, admin)
 		s.logger.Info("Server Exited Properly")
-		return s.echo.Server.Shutdown(ctx)
+		return server.Shutdown(ctx)
 	}
 
 	server := &http.Server{
@@ -94,6 +111,16 @@ func (s *Server) Run() error {
 		return err
 	}
 
+	admin, err := s.newAdminServer()
+	if err != nil {
+		return err
+	}
+	s.runAdminServer(admin)
+
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+	s.startJobs(jobsCtx)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
@@ -102,6 +129,30 @@ func (s *Server) Run() error {
 	ctx, shutdown := context.WithTimeout(context.Background(), ctxTimeout*time.Second)
 	defer shutdown()
 
+	s.shutdownAdminServer(ctx, admin)
 	s.logger.Info("Server Exited Properly")
 	return s.echo.Server.Shutdown(ctx)
 }
+
+// Server TLS config of the settings, certificates are reloaded by a worker on file changes and SIGHUP
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	tlsCfg := s.cfg.Server.TLS
+	if tlsCfg.CertFile == "" {
+		tlsCfg.CertFile = certFile
+	}
+	if tlsCfg.KeyFile == "" {
+		tlsCfg.KeyFile = keyFile
+	}
+
+	reloader, err := tlsconfig.NewReloader(tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.ClientCAFile, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := tlsconfig.NewServerConfig(tlsCfg, reloader)
+	if err != nil {
+		return nil, err
+	}
+
+	s.addWorker("tls-reload", reloader.Watch)
+	return tlsConfig, nil
+}
@@ -10,6 +10,7 @@ import (
 
 	models "github.com/fekuna/go-rest-clean-architecture/internal/models"
 	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
 )
 
 // MockUCSession is a mock of UCSession interface.
@@ -36,45 +37,120 @@ func (m *MockUCSession) EXPECT() *MockUCSessionMockRecorder {
 }
 
 // CreateSession mocks base method.
-func (m *MockUCSession) CreateSession(ctx context.Context, session *models.Session, expire int) (string, error) {
+func (m *MockUCSession) CreateSession(ctx context.Context, session *models.Session) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateSession", ctx, session, expire)
+	ret := m.ctrl.Call(m, "CreateSession", ctx, session)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateSession indicates an expected call of CreateSession.
-func (mr *MockUCSessionMockRecorder) CreateSession(ctx, session, expire interface{}) *gomock.Call {
+func (mr *MockUCSessionMockRecorder) CreateSession(ctx, session interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockUCSession)(nil).CreateSession), ctx, session, expire)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockUCSession)(nil).CreateSession), ctx, session)
 }
 
 // DeleteByID mocks base method.
-func (m *MockUCSession) DeleteByID(ctx context.Context, sessionID string) error {
+func (m *MockUCSession) DeleteByID(ctx context.Context, token string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteByID", ctx, sessionID)
+	ret := m.ctrl.Call(m, "DeleteByID", ctx, token)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteByID indicates an expected call of DeleteByID.
-func (mr *MockUCSessionMockRecorder) DeleteByID(ctx, sessionID interface{}) *gomock.Call {
+func (mr *MockUCSessionMockRecorder) DeleteByID(ctx, token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByID", reflect.TypeOf((*MockUCSession)(nil).DeleteByID), ctx, sessionID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByID", reflect.TypeOf((*MockUCSession)(nil).DeleteByID), ctx, token)
+}
+
+// DeleteExpired mocks base method.
+func (m *MockUCSession) DeleteExpired(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired.
+func (mr *MockUCSessionMockRecorder) DeleteExpired(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockUCSession)(nil).DeleteExpired), ctx)
+}
+
+// DeleteUserSessions mocks base method.
+func (m *MockUCSession) DeleteUserSessions(ctx context.Context, userID uuid.UUID, keep string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserSessions", ctx, userID, keep)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserSessions indicates an expected call of DeleteUserSessions.
+func (mr *MockUCSessionMockRecorder) DeleteUserSessions(ctx, userID, keep interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserSessions", reflect.TypeOf((*MockUCSession)(nil).DeleteUserSessions), ctx, userID, keep)
 }
 
 // GetSessionByID mocks base method.
-func (m *MockUCSession) GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error) {
+func (m *MockUCSession) GetSessionByID(ctx context.Context, token string) (*models.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetSessionByID", ctx, sessionID)
+	ret := m.ctrl.Call(m, "GetSessionByID", ctx, token)
 	ret0, _ := ret[0].(*models.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetSessionByID indicates an expected call of GetSessionByID.
-func (mr *MockUCSessionMockRecorder) GetSessionByID(ctx, sessionID interface{}) *gomock.Call {
+func (mr *MockUCSessionMockRecorder) GetSessionByID(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionByID", reflect.TypeOf((*MockUCSession)(nil).GetSessionByID), ctx, token)
+}
+
+// MigrateLegacy mocks base method.
+func (m *MockUCSession) MigrateLegacy(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateLegacy", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MigrateLegacy indicates an expected call of MigrateLegacy.
+func (mr *MockUCSessionMockRecorder) MigrateLegacy(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateLegacy", reflect.TypeOf((*MockUCSession)(nil).MigrateLegacy), ctx)
+}
+
+// RefreshSession mocks base method.
+func (m *MockUCSession) RefreshSession(ctx context.Context, session *models.Session) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshSession", ctx, session)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshSession indicates an expected call of RefreshSession.
+func (mr *MockUCSessionMockRecorder) RefreshSession(ctx, session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSession", reflect.TypeOf((*MockUCSession)(nil).RefreshSession), ctx, session)
+}
+
+// RotateSession mocks base method.
+func (m *MockUCSession) RotateSession(ctx context.Context, token string) (string, *models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateSession", ctx, token)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(*models.Session)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RotateSession indicates an expected call of RotateSession.
+func (mr *MockUCSessionMockRecorder) RotateSession(ctx, token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionByID", reflect.TypeOf((*MockUCSession)(nil).GetSessionByID), ctx, sessionID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateSession", reflect.TypeOf((*MockUCSession)(nil).RotateSession), ctx, token)
 }
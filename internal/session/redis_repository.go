@@ -1,14 +0,0 @@
-//go:generate mockgen -source redis_repository.go -destination mock/redis_repository_mock.go -package mock
-package session
-
-import (
-	"context"
-
-	"github.com/fekuna/go-rest-clean-architecture/internal/models"
-)
-
-type SessRepository interface {
-	CreateSession(ctx context.Context, session *models.Session, expire int) (string, error)
-	GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error)
-	DeleteByID(ctx context.Context, sessionID string) error
-}
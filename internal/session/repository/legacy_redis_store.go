@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/internal/session"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Earlier versions stored sessions under "api-session:: <uuid>" and used the key as cookie value
+	legacyKeyPrefix  = "api-session:: "
+	legacyScanCount  = 100
+	legacyScanMatch  = legacyKeyPrefix + "*"
+	legacyNoExpiry   = time.Duration(-1)
+	legacyMissingKey = time.Duration(-2)
+)
+
+// Sessions stored in redis by earlier versions
+type legacyRedisStore struct {
+	redisClient *redis.Client
+}
+
+// Legacy redis sessions constructor
+func NewLegacyRedisStore(redisClient *redis.Client) session.LegacyStore {
+	return &legacyRedisStore{redisClient: redisClient}
+}
+
+// Get and remove legacy session, the token is its key
+func (s *legacyRedisStore) Take(ctx context.Context, token string) (*models.Session, time.Duration, error) {
+	// TODO: Open Tracing
+	if !isLegacyToken(token) {
+		return nil, 0, nil
+	}
+
+	var get *redis.StringCmd
+	var ttl *redis.DurationCmd
+	_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, token)
+		ttl = pipe.PTTL(ctx, token)
+		pipe.Del(ctx, token)
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "legacyRedisStore.Take.TxPipelined")
+	}
+
+	sess := &models.Session{}
+	if err = json.Unmarshal([]byte(get.Val()), sess); err != nil {
+		return nil, 0, errors.Wrap(err, "legacyRedisStore.Take.json.Unmarshal")
+	}
+
+	expire := ttl.Val()
+	if expire == legacyNoExpiry || expire == legacyMissingKey {
+		expire = 0
+	}
+	return sess, expire, nil
+}
+
+// Store legacy session again, a session taken but not moved to the store is given back
+func (s *legacyRedisStore) Put(ctx context.Context, token string, sess *models.Session, expire time.Duration) error {
+	// TODO: Open Tracing
+	sessBytes, err := json.Marshal(sess)
+	if err != nil {
+		return errors.Wrap(err, "legacyRedisStore.Put.json.Marshal")
+	}
+	if err = s.redisClient.Set(ctx, token, sessBytes, expire).Err(); err != nil {
+		return errors.Wrap(err, "legacyRedisStore.Put.redisClient.Set")
+	}
+	return nil
+}
+
+// Call fn with the token of every legacy session
+func (s *legacyRedisStore) Scan(ctx context.Context, fn func(token string) error) error {
+	// TODO: Open Tracing
+	iter := s.redisClient.Scan(ctx, 0, legacyScanMatch, legacyScanCount).Iterator()
+	for iter.Next(ctx) {
+		if err := fn(iter.Val()); err != nil {
+			return err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return errors.Wrap(err, "legacyRedisStore.Scan")
+	}
+	return nil
+}
+
+// Check if the cookie value is a session key of earlier versions
+func isLegacyToken(token string) bool {
+	return strings.HasPrefix(token, legacyKeyPrefix)
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/internal/session"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// In-memory session store for development and tests, sessions are lost on restart and not shared between instances
+type memoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]models.Session
+}
+
+// In-memory session store constructor
+func NewMemoryStore() session.Store {
+	return &memoryStore{sessions: make(map[string]models.Session)}
+}
+
+// Create session
+func (s *memoryStore) Create(ctx context.Context, sess *models.Session, expire time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *sess
+	if stored.ExpiresAt.IsZero() {
+		stored.ExpiresAt = time.Now().Add(expire)
+	}
+	s.sessions[sess.SessionID] = stored
+	return nil
+}
+
+// Get session by id
+func (s *memoryStore) Get(ctx context.Context, sessionID string) (*models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sess, ok := s.sessions[sessionID]
+	if !ok || !time.Now().Before(sess.ExpiresAt) {
+		return nil, errors.Wrap(session.ErrSessionNotFound, "memoryStore.Get")
+	}
+	return &sess, nil
+}
+
+// Extend session to its expiry
+func (s *memoryStore) Touch(ctx context.Context, sess *models.Session, expire time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.sessions[sess.SessionID]
+	if !ok || !time.Now().Before(stored.ExpiresAt) {
+		return errors.Wrap(session.ErrSessionNotFound, "memoryStore.Touch")
+	}
+	stored.ExpiresAt = time.Now().Add(expire)
+	s.sessions[sess.SessionID] = stored
+	return nil
+}
+
+// Replace session oldID by sess
+func (s *memoryStore) Rotate(ctx context.Context, oldID string, sess *models.Session, expire time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.sessions[oldID]
+	if !ok || !time.Now().Before(old.ExpiresAt) {
+		return errors.Wrap(session.ErrSessionNotFound, "memoryStore.Rotate")
+	}
+	delete(s.sessions, oldID)
+
+	stored := *sess
+	if stored.ExpiresAt.IsZero() {
+		stored.ExpiresAt = time.Now().Add(expire)
+	}
+	s.sessions[sess.SessionID] = stored
+	return nil
+}
+
+// Live sessions of the user, oldest first
+func (s *memoryStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make([]*models.Session, 0)
+	now := time.Now()
+	for _, sess := range s.sessions {
+		if sess.UserID == userID && now.Before(sess.ExpiresAt) {
+			sess := sess
+			sessions = append(sessions, &sess)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+// Delete session by id
+func (s *memoryStore) Delete(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+// Remove expired sessions
+func (s *memoryStore) DeleteExpired(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for id, sess := range s.sessions {
+		if !now.Before(sess.ExpiresAt) {
+			delete(s.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/internal/session"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Postgres session store, every query goes to the primary so a new session is found right away
+type pgStore struct {
+	db *postgres.Router
+}
+
+// Postgres session store constructor
+func NewPostgresStore(db *postgres.Router) session.Store {
+	return &pgStore{db: db}
+}
+
+// Create session
+func (s *pgStore) Create(ctx context.Context, sess *models.Session, expire time.Duration) error {
+	// TODO: Open Tracing
+	if _, err := s.db.Writer(ctx).ExecContext(
+		ctx,
+		createSessionQuery,
+		sess.SessionID,
+		sess.UserID,
+		sess.RememberMe,
+		sess.CreatedAt,
+		sess.ExpiresAt,
+		sess.MaxExpiresAt,
+	); err != nil {
+		return errors.Wrap(err, "pgStore.Create.ExecContext")
+	}
+	return nil
+}
+
+// Get session by id
+func (s *pgStore) Get(ctx context.Context, sessionID string) (*models.Session, error) {
+	// TODO: Open Tracing
+	sess := &models.Session{}
+	if err := s.db.Writer(ctx).GetContext(ctx, sess, getSessionQuery, sessionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.Wrap(session.ErrSessionNotFound, "pgStore.Get")
+		}
+		return nil, errors.Wrap(err, "pgStore.Get.GetContext")
+	}
+	return sess, nil
+}
+
+// Extend session to its expiry
+func (s *pgStore) Touch(ctx context.Context, sess *models.Session, expire time.Duration) error {
+	// TODO: Open Tracing
+	result, err := s.db.Writer(ctx).ExecContext(ctx, touchSessionQuery, sess.SessionID, sess.ExpiresAt)
+	if err != nil {
+		return errors.Wrap(err, "pgStore.Touch.ExecContext")
+	}
+	touched, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "pgStore.Touch.RowsAffected")
+	}
+	if touched == 0 {
+		return errors.Wrap(session.ErrSessionNotFound, "pgStore.Touch")
+	}
+	return nil
+}
+
+// Replace session oldID by sess, the row is renamed by a single update
+func (s *pgStore) Rotate(ctx context.Context, oldID string, sess *models.Session, expire time.Duration) error {
+	// TODO: Open Tracing
+	result, err := s.db.Writer(ctx).ExecContext(ctx, rotateSessionQuery, oldID, sess.SessionID, sess.ExpiresAt)
+	if err != nil {
+		return errors.Wrap(err, "pgStore.Rotate.ExecContext")
+	}
+	rotated, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "pgStore.Rotate.RowsAffected")
+	}
+	if rotated == 0 {
+		return errors.Wrap(session.ErrSessionNotFound, "pgStore.Rotate")
+	}
+	return nil
+}
+
+// Live sessions of the user, oldest first
+func (s *pgStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	// TODO: Open Tracing
+	sessions := make([]*models.Session, 0)
+	if err := s.db.Writer(ctx).SelectContext(ctx, &sessions, getUserSessionsQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "pgStore.ListByUser.SelectContext")
+	}
+	return sessions, nil
+}
+
+// Delete session by id
+func (s *pgStore) Delete(ctx context.Context, sessionID string) error {
+	// TODO: Open Tracing
+	if _, err := s.db.Writer(ctx).ExecContext(ctx, deleteSessionQuery, sessionID); err != nil {
+		return errors.Wrap(err, "pgStore.Delete.ExecContext")
+	}
+	return nil
+}
+
+// Remove expired sessions
+func (s *pgStore) DeleteExpired(ctx context.Context) (int64, error) {
+	// TODO: Open Tracing
+	result, err := s.db.Writer(ctx).ExecContext(ctx, deleteExpiredSessionsQuery)
+	if err != nil {
+		return 0, errors.Wrap(err, "pgStore.DeleteExpired.ExecContext")
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "pgStore.DeleteExpired.RowsAffected")
+	}
+	return deleted, nil
+}
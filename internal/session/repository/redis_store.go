@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/internal/session"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Redis session store, redis expires the sessions.
+// Each user has a set of its session ids, it expires the longest session lifetime after its last session write.
+// Deleted sessions leave it at once, ids of expired ones are removed from it when listed.
+type redisStore struct {
+	redisClient *redis.Client
+	prefix      string
+	lifetime    time.Duration
+}
+
+// Redis session store constructor, keys are the session ids under prefix.
+// lifetime is the longest a session can live, the user sets are kept at least that long.
+func NewRedisStore(redisClient *redis.Client, prefix string, lifetime time.Duration) session.Store {
+	return &redisStore{redisClient: redisClient, prefix: prefix, lifetime: lifetime}
+}
+
+// Create session
+func (s *redisStore) Create(ctx context.Context, sess *models.Session, expire time.Duration) error {
+	// TODO: Open Tracing
+	sessBytes, err := json.Marshal(sess)
+	if err != nil {
+		return errors.Wrap(err, "redisStore.Create.json.Marshal")
+	}
+	if _, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.key(sess.SessionID), sessBytes, expire)
+		pipe.SAdd(ctx, s.userKey(sess.UserID), sess.SessionID)
+		pipe.Expire(ctx, s.userKey(sess.UserID), s.userExpire(expire))
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "redisStore.Create.redisClient.TxPipelined")
+	}
+
+	return nil
+}
+
+// Get session by id
+func (s *redisStore) Get(ctx context.Context, sessionID string) (*models.Session, error) {
+	// TODO: Open Tracing
+	sessBytes, err := s.redisClient.Get(ctx, s.key(sessionID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errors.Wrap(session.ErrSessionNotFound, "redisStore.Get")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "redisStore.Get.redisClient.Get")
+	}
+
+	sess := &models.Session{}
+	if err = json.Unmarshal(sessBytes, sess); err != nil {
+		return nil, errors.Wrap(err, "redisStore.Get.json.Unmarshal")
+	}
+	return sess, nil
+}
+
+// Extend session to its expiry, the session is rewritten only while it exists
+func (s *redisStore) Touch(ctx context.Context, sess *models.Session, expire time.Duration) error {
+	// TODO: Open Tracing
+	sessBytes, err := json.Marshal(sess)
+	if err != nil {
+		return errors.Wrap(err, "redisStore.Touch.json.Marshal")
+	}
+	touched, err := s.redisClient.SetXX(ctx, s.key(sess.SessionID), sessBytes, expire).Result()
+	if err != nil {
+		return errors.Wrap(err, "redisStore.Touch.redisClient.SetXX")
+	}
+	if !touched {
+		return errors.Wrap(session.ErrSessionNotFound, "redisStore.Touch")
+	}
+	if err = s.redisClient.Expire(ctx, s.userKey(sess.UserID), s.userExpire(expire)).Err(); err != nil {
+		return errors.Wrap(err, "redisStore.Touch.redisClient.Expire")
+	}
+	return nil
+}
+
+// Replace session oldID by sess, the old session must not change meanwhile
+func (s *redisStore) Rotate(ctx context.Context, oldID string, sess *models.Session, expire time.Duration) error {
+	// TODO: Open Tracing
+	sessBytes, err := json.Marshal(sess)
+	if err != nil {
+		return errors.Wrap(err, "redisStore.Rotate.json.Marshal")
+	}
+
+	oldKey := s.key(oldID)
+	err = s.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+		exists, err := tx.Exists(ctx, oldKey).Result()
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return session.ErrSessionNotFound
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, oldKey)
+			pipe.Set(ctx, s.key(sess.SessionID), sessBytes, expire)
+			pipe.SRem(ctx, s.userKey(sess.UserID), oldID)
+			pipe.SAdd(ctx, s.userKey(sess.UserID), sess.SessionID)
+			pipe.Expire(ctx, s.userKey(sess.UserID), s.userExpire(expire))
+			return nil
+		})
+		return err
+	}, oldKey)
+	if err != nil {
+		return errors.Wrap(err, "redisStore.Rotate.redisClient.Watch")
+	}
+	return nil
+}
+
+// Live sessions of the user, oldest first
+func (s *redisStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	// TODO: Open Tracing
+	ids, err := s.redisClient.SMembers(ctx, s.userKey(userID)).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "redisStore.ListByUser.redisClient.SMembers")
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, s.key(id))
+	}
+	values, err := s.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "redisStore.ListByUser.redisClient.MGet")
+	}
+
+	sessions := make([]*models.Session, 0, len(values))
+	stale := make([]interface{}, 0)
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			stale = append(stale, ids[i])
+			continue
+		}
+		sess := &models.Session{}
+		if err = json.Unmarshal([]byte(data), sess); err != nil {
+			return nil, errors.Wrap(err, "redisStore.ListByUser.json.Unmarshal")
+		}
+		sessions = append(sessions, sess)
+	}
+
+	if len(stale) > 0 {
+		if err = s.redisClient.SRem(ctx, s.userKey(userID), stale...).Err(); err != nil {
+			return nil, errors.Wrap(err, "redisStore.ListByUser.redisClient.SRem")
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+// Delete session by id, it is removed from the set of its user too
+func (s *redisStore) Delete(ctx context.Context, sessionID string) error {
+	// TODO: Open Tracing
+	sess, err := s.Get(ctx, sessionID)
+	if errors.Is(err, session.ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "redisStore.Delete.Get")
+	}
+
+	if _, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.key(sessionID))
+		pipe.SRem(ctx, s.userKey(sess.UserID), sessionID)
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "redisStore.Delete.redisClient.TxPipelined")
+	}
+	return nil
+}
+
+// Redis expires sessions by itself
+func (s *redisStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (s *redisStore) key(sessionID string) string {
+	return s.prefix + ":" + sessionID
+}
+
+// Expiry of a user set written with a session expiring in expire
+func (s *redisStore) userExpire(expire time.Duration) time.Duration {
+	if expire > s.lifetime {
+		return expire
+	}
+	return s.lifetime
+}
+
+func (s *redisStore) userKey(userID uuid.UUID) string {
+	return s.prefix + ":user:" + userID.String()
+}
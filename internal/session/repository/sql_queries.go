@@ -0,0 +1,17 @@
+package repository
+
+const (
+	createSessionQuery = `INSERT INTO sessions (session_id, user_id, remember_me, created_at, expires_at, max_expires_at) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	getSessionQuery = `SELECT session_id, user_id, remember_me, created_at, expires_at, max_expires_at FROM sessions WHERE session_id = $1 AND expires_at > now()`
+
+	touchSessionQuery = `UPDATE sessions SET expires_at = $2 WHERE session_id = $1 AND expires_at > now()`
+
+	rotateSessionQuery = `UPDATE sessions SET session_id = $2, expires_at = $3 WHERE session_id = $1 AND expires_at > now()`
+
+	getUserSessionsQuery = `SELECT session_id, user_id, remember_me, created_at, expires_at, max_expires_at FROM sessions WHERE user_id = $1 AND expires_at > now() ORDER BY created_at`
+
+	deleteSessionQuery = `DELETE FROM sessions WHERE session_id = $1`
+
+	deleteExpiredSessionsQuery = `DELETE FROM sessions WHERE expires_at <= now()`
+)
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/config"
+	"github.com/fekuna/go-rest-clean-architecture/internal/session"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
+	"github.com/go-redis/redis/v8"
+)
+
+// Session store backends
+const (
+	StoreRedis    = "redis"
+	StorePostgres = "postgres"
+	StoreMemory   = "memory"
+)
+
+// Session store of the configured backend, redis when none is set
+func NewStore(cfg *config.Config, redisClient *redis.Client, db *postgres.Router) (session.Store, error) {
+	switch cfg.Session.Store {
+	case "", StoreRedis:
+		return NewRedisStore(redisClient, cfg.Session.Prefix, longestLifetime(cfg.Session)), nil
+	case StorePostgres:
+		return NewPostgresStore(db), nil
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown session store %q", cfg.Session.Store)
+	}
+}
+
+// Longest session lifetime of the config
+func longestLifetime(cfg config.Session) time.Duration {
+	longest := 0
+	for _, seconds := range []int{cfg.Expire, cfg.MaxLifetime, cfg.RememberMeExpire, cfg.RememberMeMaxLifetime} {
+		if seconds > longest {
+			longest = seconds
+		}
+	}
+	return time.Duration(longest) * time.Second
+}
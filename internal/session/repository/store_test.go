@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis"
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/internal/session"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/postgres"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return mr, client
+}
+
+func TestStores(t *testing.T) {
+	t.Parallel()
+
+	mr, client := setupRedis(t)
+	stores := map[string]session.Store{
+		StoreRedis:  NewRedisStore(client, "api-session", time.Hour),
+		StoreMemory: NewMemoryStore(),
+	}
+
+	for name, store := range stores {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			sess := &models.Session{
+				SessionID: uuid.New().String(),
+				UserID:    uuid.New(),
+				CreatedAt: time.Now(),
+				ExpiresAt: time.Now().Add(time.Minute),
+			}
+
+			require.NoError(t, store.Create(ctx, sess, time.Minute))
+
+			found, err := store.Get(ctx, sess.SessionID)
+			require.NoError(t, err)
+			require.Equal(t, sess.UserID, found.UserID)
+
+			sess.ExpiresAt = time.Now().Add(time.Hour)
+			require.NoError(t, store.Touch(ctx, sess, time.Hour))
+			found, err = store.Get(ctx, sess.SessionID)
+			require.NoError(t, err)
+			require.WithinDuration(t, sess.ExpiresAt, found.ExpiresAt, time.Second)
+
+			sessions, err := store.ListByUser(ctx, sess.UserID)
+			require.NoError(t, err)
+			require.Len(t, sessions, 1)
+
+			oldID := sess.SessionID
+			sess.SessionID = uuid.New().String()
+			require.NoError(t, store.Rotate(ctx, oldID, sess, time.Hour))
+			_, err = store.Get(ctx, oldID)
+			require.ErrorIs(t, err, session.ErrSessionNotFound)
+			require.ErrorIs(t, store.Rotate(ctx, oldID, sess, time.Hour), session.ErrSessionNotFound)
+
+			sessions, err = store.ListByUser(ctx, sess.UserID)
+			require.NoError(t, err)
+			require.Len(t, sessions, 1)
+			require.Equal(t, sess.SessionID, sessions[0].SessionID)
+
+			require.NoError(t, store.Delete(ctx, sess.SessionID))
+			sessions, err = store.ListByUser(ctx, sess.UserID)
+			require.NoError(t, err)
+			require.Empty(t, sessions)
+
+			_, err = store.Get(ctx, sess.SessionID)
+			require.ErrorIs(t, err, session.ErrSessionNotFound)
+			require.ErrorIs(t, store.Touch(ctx, sess, time.Hour), session.ErrSessionNotFound)
+		})
+	}
+
+	t.Run("Redis key", func(t *testing.T) {
+		sess := &models.Session{SessionID: "hash", UserID: uuid.New()}
+		require.NoError(t, stores[StoreRedis].Create(context.Background(), sess, time.Minute))
+		require.True(t, mr.Exists("api-session:hash"))
+	})
+
+	t.Run("Redis user set", func(t *testing.T) {
+		ctx := context.Background()
+		sess := &models.Session{SessionID: "user-set", UserID: uuid.New()}
+		userKey := "api-session:user:" + sess.UserID.String()
+		require.NoError(t, stores[StoreRedis].Create(ctx, sess, time.Minute))
+		require.Equal(t, time.Hour, mr.TTL(userKey))
+
+		require.NoError(t, stores[StoreRedis].Delete(ctx, sess.SessionID))
+		require.False(t, mr.Exists(userKey))
+		require.NoError(t, stores[StoreRedis].Delete(ctx, sess.SessionID))
+	})
+
+	t.Run("Memory expiry", func(t *testing.T) {
+		store := stores[StoreMemory]
+		sess := &models.Session{SessionID: "expired", UserID: uuid.New(), ExpiresAt: time.Now().Add(-time.Second)}
+		require.NoError(t, store.Create(context.Background(), sess, time.Minute))
+
+		_, err := store.Get(context.Background(), sess.SessionID)
+		require.ErrorIs(t, err, session.ErrSessionNotFound)
+
+		deleted, err := store.DeleteExpired(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, int64(1), deleted)
+	})
+}
+
+func TestPostgresStore(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewPostgresStore(postgres.NewRouter(sqlx.NewDb(db, "sqlmock"), nil, 0, nil))
+	ctx := context.Background()
+
+	t.Run("Create", func(t *testing.T) {
+		sess := &models.Session{SessionID: "hash", UserID: uuid.New(), CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour), MaxExpiresAt: time.Now().Add(24 * time.Hour)}
+
+		mock.ExpectExec(createSessionQuery).WithArgs(sess.SessionID, sess.UserID, sess.RememberMe, sess.CreatedAt, sess.ExpiresAt, sess.MaxExpiresAt).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		require.NoError(t, store.Create(ctx, sess, time.Hour))
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		userID := uuid.New()
+		rows := sqlmock.NewRows([]string{"session_id", "user_id", "remember_me", "created_at", "expires_at", "max_expires_at"}).
+			AddRow("hash", userID, true, time.Now(), time.Now().Add(time.Hour), time.Now().Add(24*time.Hour))
+
+		mock.ExpectQuery(getSessionQuery).WithArgs("hash").WillReturnRows(rows)
+
+		sess, err := store.Get(ctx, "hash")
+		require.NoError(t, err)
+		require.Equal(t, userID, sess.UserID)
+		require.True(t, sess.RememberMe)
+	})
+
+	t.Run("Touch", func(t *testing.T) {
+		sess := &models.Session{SessionID: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+
+		mock.ExpectExec(touchSessionQuery).WithArgs(sess.SessionID, sess.ExpiresAt).WillReturnResult(sqlmock.NewResult(0, 1))
+		require.NoError(t, store.Touch(ctx, sess, time.Hour))
+
+		mock.ExpectExec(touchSessionQuery).WithArgs(sess.SessionID, sess.ExpiresAt).WillReturnResult(sqlmock.NewResult(0, 0))
+		require.ErrorIs(t, store.Touch(ctx, sess, time.Hour), session.ErrSessionNotFound)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mock.ExpectQuery(getSessionQuery).WithArgs("missing").WillReturnRows(sqlmock.NewRows([]string{"session_id"}))
+
+		_, err := store.Get(ctx, "missing")
+		require.ErrorIs(t, err, session.ErrSessionNotFound)
+	})
+
+	t.Run("Rotate", func(t *testing.T) {
+		sess := &models.Session{SessionID: "new", ExpiresAt: time.Now().Add(time.Hour)}
+
+		mock.ExpectExec(rotateSessionQuery).WithArgs("old", sess.SessionID, sess.ExpiresAt).WillReturnResult(sqlmock.NewResult(0, 1))
+		require.NoError(t, store.Rotate(ctx, "old", sess, time.Hour))
+
+		mock.ExpectExec(rotateSessionQuery).WithArgs("old", sess.SessionID, sess.ExpiresAt).WillReturnResult(sqlmock.NewResult(0, 0))
+		require.ErrorIs(t, store.Rotate(ctx, "old", sess, time.Hour), session.ErrSessionNotFound)
+	})
+
+	t.Run("ListByUser", func(t *testing.T) {
+		userID := uuid.New()
+		rows := sqlmock.NewRows([]string{"session_id", "user_id", "remember_me", "created_at", "expires_at", "max_expires_at"}).
+			AddRow("first", userID, false, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), time.Now().Add(time.Hour)).
+			AddRow("second", userID, false, time.Now(), time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+
+		mock.ExpectQuery(getUserSessionsQuery).WithArgs(userID).WillReturnRows(rows)
+
+		sessions, err := store.ListByUser(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		require.Equal(t, "first", sessions[0].SessionID)
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		mock.ExpectExec(deleteExpiredSessionsQuery).WillReturnResult(sqlmock.NewResult(0, 3))
+
+		deleted, err := store.DeleteExpired(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(3), deleted)
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLegacyRedisStore_Take(t *testing.T) {
+	t.Parallel()
+
+	mr, client := setupRedis(t)
+	legacy := NewLegacyRedisStore(client)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	token := "api-session:: " + uuid.New().String()
+	require.NoError(t, mr.Set(token, `{"session_id":"`+token[len("api-session:: "):]+`","user_id":"`+userID.String()+`"}`))
+	mr.SetTTL(token, time.Hour)
+
+	tokens := make([]string, 0)
+	require.NoError(t, legacy.Scan(ctx, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	}))
+	require.Equal(t, []string{token}, tokens)
+
+	sess, expire, err := legacy.Take(ctx, token)
+	require.NoError(t, err)
+	require.Equal(t, userID, sess.UserID)
+	require.Equal(t, time.Hour, expire)
+	require.False(t, mr.Exists(token))
+
+	sess, _, err = legacy.Take(ctx, token)
+	require.NoError(t, err)
+	require.Nil(t, sess)
+
+	sess, _, err = legacy.Take(ctx, "opaque-token")
+	require.NoError(t, err)
+	require.Nil(t, sess)
+
+	require.NoError(t, legacy.Put(ctx, token, &models.Session{UserID: userID}, time.Hour))
+	sess, expire, err = legacy.Take(ctx, token)
+	require.NoError(t, err)
+	require.Equal(t, userID, sess.UserID)
+	require.Equal(t, time.Hour, expire)
+}
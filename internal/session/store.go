@@ -0,0 +1,48 @@
+//go:generate mockgen -source store.go -destination mock/store_mock.go -package mock
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/google/uuid"
+)
+
+// Session is missing or expired
+var ErrSessionNotFound = errors.New("session not found")
+
+// Stored id of the session token, anything kept server side is keyed by it instead of the token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Session store, sessions are keyed by the hash of their token so stored ids can't be used as cookies
+type Store interface {
+	// Store session until expire, its ExpiresAt is set by the caller
+	Create(ctx context.Context, sess *models.Session, expire time.Duration) error
+	Get(ctx context.Context, sessionID string) (*models.Session, error)
+	// Extend existing session to its ExpiresAt, expire is the time left until then
+	Touch(ctx context.Context, sess *models.Session, expire time.Duration) error
+	// Replace session oldID by sess in one step, the old id stops working as the new one starts
+	Rotate(ctx context.Context, oldID string, sess *models.Session, expire time.Duration) error
+	// Live sessions of the user, oldest first
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+	Delete(ctx context.Context, sessionID string) error
+	// Remove expired sessions, stores expiring them by themselves remove none
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// Sessions of earlier versions, stored in redis under their cookie value
+type LegacyStore interface {
+	// Get and remove session of the token, nil when missing
+	Take(ctx context.Context, token string) (*models.Session, time.Duration, error)
+	// Store session of the token again, zero expire keeps it without expiry
+	Put(ctx context.Context, token string, sess *models.Session, expire time.Duration) error
+	// Call fn with the token of every legacy session
+	Scan(ctx context.Context, fn func(token string) error) error
+}
@@ -5,11 +5,17 @@ import (
 	"context"
 
 	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/google/uuid"
 )
 
-// Session use case
+// Session use case, sessions are looked up by the opaque token held by the client
 type UCSession interface {
-	CreateSession(ctx context.Context, session *models.Session, expire int) (string, error)
-	GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error)
-	DeleteByID(ctx context.Context, sessionID string) error
+	CreateSession(ctx context.Context, session *models.Session) (string, error)
+	GetSessionByID(ctx context.Context, token string) (*models.Session, error)
+	RefreshSession(ctx context.Context, session *models.Session) (bool, error)
+	RotateSession(ctx context.Context, token string) (string, *models.Session, error)
+	DeleteByID(ctx context.Context, token string) error
+	DeleteUserSessions(ctx context.Context, userID uuid.UUID, keep string) error
+	MigrateLegacy(ctx context.Context) (int, error)
+	DeleteExpired(ctx context.Context) (int64, error)
 }
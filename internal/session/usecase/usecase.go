@@ -2,36 +2,289 @@ package usecase
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/config"
 	"github.com/fekuna/go-rest-clean-architecture/internal/models"
 	"github.com/fekuna/go-rest-clean-architecture/internal/session"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	tokenBytes = 32
+
+	defaultRefreshInterval = time.Minute
+)
+
+// Policies of a login past the session limit of its user
+const (
+	LimitEvictOldest = "evict-oldest"
+	LimitDeny        = "deny"
 )
 
 // Session use case
 type sessionUC struct {
-	sessionRepo session.SessRepository
-	cfg         *config.Config
+	store  session.Store
+	legacy session.LegacyStore
+	cfg    *config.Config
 }
 
-func NewSessionUseCase(sessionRepo session.SessRepository, cfg *config.Config) session.UCSession {
-	return &sessionUC{sessionRepo: sessionRepo, cfg: cfg}
+// Session use case constructor, legacy may be nil when there are no sessions of earlier versions
+func NewSessionUseCase(store session.Store, legacy session.LegacyStore, cfg *config.Config) session.UCSession {
+	return &sessionUC{store: store, legacy: legacy, cfg: cfg}
 }
 
-// Create new session
-func (u *sessionUC) CreateSession(ctx context.Context, session *models.Session, expire int) (string, error) {
+// Create new session with the lifetimes of its remember me option, returns the token for the client, only its hash is stored
+func (u *sessionUC) CreateSession(ctx context.Context, sess *models.Session) (string, error) {
 	// TODO: OPEN TRACING
-	return u.sessionRepo.CreateSession(ctx, session, expire)
+	token, err := newToken()
+	if err != nil {
+		return "", errors.Wrap(err, "sessionUC.CreateSession.newToken")
+	}
+
+	if err = u.enforceLimit(ctx, sess.UserID); err != nil {
+		return "", err
+	}
+
+	idle, _ := u.lifetimes(sess.RememberMe)
+	if err = u.create(ctx, sess, token, idle); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Keep the user below its session limit before a new session, ending its oldest sessions or refusing the new one.
+// Concurrent logins may pass the limit by the number of racing requests.
+func (u *sessionUC) enforceLimit(ctx context.Context, userID uuid.UUID) error {
+	limit := u.cfg.Session.MaxPerUser
+	if limit <= 0 {
+		return nil
+	}
+
+	sessions, err := u.store.ListByUser(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "sessionUC.enforceLimit.ListByUser")
+	}
+	excess := len(sessions) - limit + 1
+	if excess <= 0 {
+		return nil
+	}
+
+	if u.cfg.Session.LimitPolicy == LimitDeny {
+		return httpErrors.NewRestError(http.StatusForbidden, httpErrors.SessionLimitReached.Error(), nil)
+	}
+	for _, sess := range sessions[:excess] {
+		if err = u.store.Delete(ctx, sess.SessionID); err != nil {
+			return errors.Wrap(err, "sessionUC.enforceLimit.Delete")
+		}
+	}
+	return nil
+}
+
+func (u *sessionUC) create(ctx context.Context, sess *models.Session, token string, expire time.Duration) error {
+	_, lifetime := u.lifetimes(sess.RememberMe)
+	if expire > lifetime {
+		expire = lifetime
+	}
+
+	sess.SessionID = session.HashToken(token)
+	sess.CreatedAt = time.Now()
+	sess.ExpiresAt = sess.CreatedAt.Add(expire)
+	sess.MaxExpiresAt = sess.CreatedAt.Add(lifetime)
+
+	return u.store.Create(ctx, sess, expire)
+}
+
+// Move the session of token to a new token, the old one stops working.
+// Sessions are rotated when the privileges of their user change so a token seen before can't be reused.
+func (u *sessionUC) RotateSession(ctx context.Context, token string) (string, *models.Session, error) {
+	// TODO: Open Tracing
+	sess, err := u.GetSessionByID(ctx, token)
+	if err != nil {
+		return "", nil, err
+	}
+	expire := time.Until(sess.ExpiresAt)
+	if expire <= 0 {
+		return "", nil, errors.Wrap(session.ErrSessionNotFound, "sessionUC.RotateSession")
+	}
+
+	rotatedToken, err := newToken()
+	if err != nil {
+		return "", nil, errors.Wrap(err, "sessionUC.RotateSession.newToken")
+	}
+
+	rotated := *sess
+	rotated.SessionID = session.HashToken(rotatedToken)
+	if err = u.store.Rotate(ctx, sess.SessionID, &rotated, expire); err != nil {
+		return "", nil, err
+	}
+	return rotatedToken, &rotated, nil
 }
 
-// Delete session by id
-func (u *sessionUC) DeleteByID(ctx context.Context, sessionID string) error {
+// Delete every session of the user but the one of keep, keep may be empty
+func (u *sessionUC) DeleteUserSessions(ctx context.Context, userID uuid.UUID, keep string) error {
 	// TODO: Open Tracing
-	return u.sessionRepo.DeleteByID(ctx, sessionID)
+	sessions, err := u.store.ListByUser(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "sessionUC.DeleteUserSessions.ListByUser")
+	}
+
+	var keepID string
+	if keep != "" {
+		keepID = session.HashToken(keep)
+	}
+	for _, sess := range sessions {
+		if sess.SessionID == keepID {
+			continue
+		}
+		if err = u.store.Delete(ctx, sess.SessionID); err != nil {
+			return errors.Wrap(err, "sessionUC.DeleteUserSessions.Delete")
+		}
+	}
+	return nil
 }
 
-// get session by id
-func (u *sessionUC) GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error) {
+// Slide session expiry by the idle timeout, capped by its absolute lifetime.
+// Refreshes closer than the refresh interval to the last one are skipped, returns if the session was refreshed.
+func (u *sessionUC) RefreshSession(ctx context.Context, sess *models.Session) (bool, error) {
 	// TODO: Open Tracing
-	return u.sessionRepo.GetSessionByID(ctx, sessionID)
+	if sess.MaxExpiresAt.IsZero() {
+		return false, nil
+	}
+
+	idle, _ := u.lifetimes(sess.RememberMe)
+	now := time.Now()
+	expiresAt := now.Add(idle)
+	if expiresAt.After(sess.MaxExpiresAt) {
+		expiresAt = sess.MaxExpiresAt
+	}
+	if expiresAt.Sub(sess.ExpiresAt) < u.refreshInterval() {
+		return false, nil
+	}
+
+	refreshed := *sess
+	refreshed.ExpiresAt = expiresAt
+	if err := u.store.Touch(ctx, &refreshed, expiresAt.Sub(now)); err != nil {
+		return false, err
+	}
+
+	sess.ExpiresAt = expiresAt
+	return true, nil
+}
+
+// Idle timeout and absolute lifetime of sessions
+func (u *sessionUC) lifetimes(rememberMe bool) (time.Duration, time.Duration) {
+	idle, lifetime := u.cfg.Session.Expire, u.cfg.Session.MaxLifetime
+	if rememberMe && u.cfg.Session.RememberMeExpire > 0 {
+		idle = u.cfg.Session.RememberMeExpire
+	}
+	if rememberMe && u.cfg.Session.RememberMeMaxLifetime > 0 {
+		lifetime = u.cfg.Session.RememberMeMaxLifetime
+	}
+	if lifetime < idle {
+		lifetime = idle
+	}
+	return time.Duration(idle) * time.Second, time.Duration(lifetime) * time.Second
+}
+
+func (u *sessionUC) refreshInterval() time.Duration {
+	if u.cfg.Session.RefreshInterval <= 0 {
+		return defaultRefreshInterval
+	}
+	return time.Duration(u.cfg.Session.RefreshInterval) * time.Second
+}
+
+// Delete session by token
+func (u *sessionUC) DeleteByID(ctx context.Context, token string) error {
+	// TODO: Open Tracing
+	if err := u.store.Delete(ctx, session.HashToken(token)); err != nil {
+		return err
+	}
+	if u.legacy != nil {
+		if _, _, err := u.legacy.Take(ctx, token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get session by token, sessions of earlier versions are moved to the store on first use
+func (u *sessionUC) GetSessionByID(ctx context.Context, token string) (*models.Session, error) {
+	// TODO: Open Tracing
+	sess, err := u.store.Get(ctx, session.HashToken(token))
+	if err == nil && sess.IsPastLifetime(time.Now()) {
+		return nil, errors.Wrap(session.ErrSessionNotFound, "sessionUC.GetSessionByID.IsPastLifetime")
+	}
+	if err == nil || !errors.Is(err, session.ErrSessionNotFound) || u.legacy == nil {
+		return sess, err
+	}
+
+	migrated, err := u.migrate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if migrated == nil {
+		return nil, errors.Wrap(session.ErrSessionNotFound, "sessionUC.GetSessionByID")
+	}
+	return migrated, nil
+}
+
+// Move every session of earlier versions to the store, their cookies keep working as tokens
+func (u *sessionUC) MigrateLegacy(ctx context.Context) (int, error) {
+	// TODO: Open Tracing
+	if u.legacy == nil {
+		return 0, nil
+	}
+
+	migrated := 0
+	err := u.legacy.Scan(ctx, func(token string) error {
+		sess, err := u.migrate(ctx, token)
+		if sess != nil {
+			migrated++
+		}
+		return err
+	})
+	return migrated, err
+}
+
+// Move legacy session of the token to the store, nil when there is none.
+// Taking it makes concurrent requests of the token migrate it once, it is given back when the store fails.
+func (u *sessionUC) migrate(ctx context.Context, token string) (*models.Session, error) {
+	sess, legacyExpire, err := u.legacy.Take(ctx, token)
+	if err != nil || sess == nil {
+		return nil, err
+	}
+	expire := legacyExpire
+	if expire <= 0 {
+		expire = time.Duration(u.cfg.Session.Expire) * time.Second
+	}
+
+	legacy := *sess
+	if err = u.create(ctx, sess, token, expire); err != nil {
+		if putErr := u.legacy.Put(ctx, token, &legacy, legacyExpire); putErr != nil {
+			return nil, errors.Wrapf(err, "sessionUC.migrate.create, legacy session lost: %v", putErr)
+		}
+		return nil, errors.Wrap(err, "sessionUC.migrate.create")
+	}
+	return sess, nil
+}
+
+// Remove expired sessions from stores that don't expire them by themselves
+func (u *sessionUC) DeleteExpired(ctx context.Context) (int64, error) {
+	// TODO: Open Tracing
+	return u.store.DeleteExpired(ctx)
+}
+
+// Random opaque session token
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
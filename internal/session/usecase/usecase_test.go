@@ -0,0 +1,249 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/config"
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/internal/session"
+	"github.com/fekuna/go-rest-clean-architecture/internal/session/mock"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionUC_CreateSession(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock.NewMockStore(ctrl)
+	sessUC := NewSessionUseCase(mockStore, nil, &config.Config{Session: config.Session{Expire: 3600}})
+
+	ctx := context.Background()
+	sess := &models.Session{UserID: uuid.New()}
+
+	var stored *models.Session
+	mockStore.EXPECT().Create(ctx, sess, time.Hour).DoAndReturn(func(ctx context.Context, sess *models.Session, expire time.Duration) error {
+		stored = sess
+		return nil
+	})
+
+	token, err := sessUC.CreateSession(ctx, sess)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEqual(t, token, stored.SessionID)
+	require.Equal(t, session.HashToken(token), stored.SessionID)
+	require.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt, time.Second)
+
+	mockStore.EXPECT().Get(ctx, session.HashToken(token)).Return(stored, nil)
+
+	found, err := sessUC.GetSessionByID(ctx, token)
+	require.NoError(t, err)
+	require.Equal(t, sess.UserID, found.UserID)
+}
+
+func TestSessionUC_RefreshSession(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock.NewMockStore(ctrl)
+	sessUC := NewSessionUseCase(mockStore, nil, &config.Config{Session: config.Session{
+		Expire:                3600,
+		MaxLifetime:           86400,
+		RememberMeExpire:      7200,
+		RememberMeMaxLifetime: 172800,
+		RefreshInterval:       60,
+	}})
+
+	ctx := context.Background()
+
+	t.Run("Remember me", func(t *testing.T) {
+		sess := &models.Session{UserID: uuid.New(), RememberMe: true}
+		mockStore.EXPECT().Create(ctx, sess, 2*time.Hour).Return(nil)
+
+		_, err := sessUC.CreateSession(ctx, sess)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().Add(48*time.Hour), sess.MaxExpiresAt, time.Second)
+	})
+
+	t.Run("Remember me without own lifetime", func(t *testing.T) {
+		sessUC := NewSessionUseCase(mockStore, nil, &config.Config{Session: config.Session{
+			Expire:           3600,
+			MaxLifetime:      86400,
+			RememberMeExpire: 7200,
+		}})
+		sess := &models.Session{UserID: uuid.New(), RememberMe: true}
+		mockStore.EXPECT().Create(ctx, sess, 2*time.Hour).Return(nil)
+
+		_, err := sessUC.CreateSession(ctx, sess)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().Add(24*time.Hour), sess.MaxExpiresAt, time.Second)
+	})
+
+	t.Run("Throttled", func(t *testing.T) {
+		sess := &models.Session{ExpiresAt: time.Now().Add(time.Hour - 30*time.Second), MaxExpiresAt: time.Now().Add(time.Hour * 24)}
+
+		refreshed, err := sessUC.RefreshSession(ctx, sess)
+		require.NoError(t, err)
+		require.False(t, refreshed)
+	})
+
+	t.Run("Slides", func(t *testing.T) {
+		sess := &models.Session{ExpiresAt: time.Now().Add(10 * time.Minute), MaxExpiresAt: time.Now().Add(time.Hour * 24)}
+		mockStore.EXPECT().Touch(ctx, gomock.Any(), gomock.Any()).Return(nil)
+
+		refreshed, err := sessUC.RefreshSession(ctx, sess)
+		require.NoError(t, err)
+		require.True(t, refreshed)
+		require.WithinDuration(t, time.Now().Add(time.Hour), sess.ExpiresAt, time.Second)
+	})
+
+	t.Run("Capped by lifetime", func(t *testing.T) {
+		maxExpiresAt := time.Now().Add(20 * time.Minute)
+		sess := &models.Session{ExpiresAt: time.Now().Add(5 * time.Minute), MaxExpiresAt: maxExpiresAt}
+		mockStore.EXPECT().Touch(ctx, gomock.Any(), gomock.Any()).Return(nil)
+
+		refreshed, err := sessUC.RefreshSession(ctx, sess)
+		require.NoError(t, err)
+		require.True(t, refreshed)
+		require.Equal(t, maxExpiresAt, sess.ExpiresAt)
+	})
+
+	t.Run("Past lifetime", func(t *testing.T) {
+		sess := &models.Session{ExpiresAt: time.Now().Add(time.Minute), MaxExpiresAt: time.Now().Add(-time.Second)}
+		mockStore.EXPECT().Get(ctx, session.HashToken("token")).Return(sess, nil)
+
+		_, err := sessUC.GetSessionByID(ctx, "token")
+		require.ErrorIs(t, err, session.ErrSessionNotFound)
+	})
+}
+
+func TestSessionUC_SessionLimit(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock.NewMockStore(ctrl)
+	cfg := &config.Config{Session: config.Session{Expire: 3600, MaxPerUser: 2, LimitPolicy: LimitEvictOldest}}
+	sessUC := NewSessionUseCase(mockStore, nil, cfg)
+
+	ctx := context.Background()
+	userID := uuid.New()
+	existing := []*models.Session{{SessionID: "oldest", UserID: userID}, {SessionID: "newer", UserID: userID}}
+
+	t.Run("Evict oldest", func(t *testing.T) {
+		mockStore.EXPECT().ListByUser(ctx, userID).Return(existing, nil)
+		mockStore.EXPECT().Delete(ctx, "oldest").Return(nil)
+		mockStore.EXPECT().Create(ctx, gomock.Any(), time.Hour).Return(nil)
+
+		_, err := sessUC.CreateSession(ctx, &models.Session{UserID: userID})
+		require.NoError(t, err)
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		denyUC := NewSessionUseCase(mockStore, nil, &config.Config{Session: config.Session{Expire: 3600, MaxPerUser: 2, LimitPolicy: LimitDeny}})
+		mockStore.EXPECT().ListByUser(ctx, userID).Return(existing, nil)
+
+		_, err := denyUC.CreateSession(ctx, &models.Session{UserID: userID})
+		require.Error(t, err)
+		require.Equal(t, http.StatusForbidden, httpErrors.ParseErrors(err).Status())
+	})
+}
+
+func TestSessionUC_RotateSession(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock.NewMockStore(ctrl)
+	sessUC := NewSessionUseCase(mockStore, nil, &config.Config{Session: config.Session{Expire: 3600}})
+
+	ctx := context.Background()
+	sess := &models.Session{SessionID: session.HashToken("token"), UserID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}
+
+	mockStore.EXPECT().Get(ctx, session.HashToken("token")).Return(sess, nil)
+	mockStore.EXPECT().Rotate(ctx, session.HashToken("token"), gomock.Any(), gomock.Any()).Return(nil)
+
+	token, rotated, err := sessUC.RotateSession(ctx, "token")
+	require.NoError(t, err)
+	require.NotEqual(t, "token", token)
+	require.Equal(t, session.HashToken(token), rotated.SessionID)
+	require.Equal(t, sess.UserID, rotated.UserID)
+	require.Equal(t, sess.ExpiresAt, rotated.ExpiresAt)
+
+	t.Run("Delete other sessions", func(t *testing.T) {
+		mockStore.EXPECT().ListByUser(ctx, sess.UserID).Return([]*models.Session{rotated, {SessionID: "other"}}, nil)
+		mockStore.EXPECT().Delete(ctx, "other").Return(nil)
+
+		require.NoError(t, sessUC.DeleteUserSessions(ctx, sess.UserID, token))
+	})
+}
+
+func TestSessionUC_LegacySession(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock.NewMockStore(ctrl)
+	mockLegacy := mock.NewMockLegacyStore(ctrl)
+	sessUC := NewSessionUseCase(mockStore, mockLegacy, &config.Config{Session: config.Session{Expire: 3600}})
+
+	ctx := context.Background()
+	userID := uuid.New()
+	token := "api-session:: " + uuid.New().String()
+	notFound := errors.Wrap(session.ErrSessionNotFound, "memoryStore.Get")
+
+	t.Run("Moved on first use", func(t *testing.T) {
+		mockStore.EXPECT().Get(ctx, session.HashToken(token)).Return(nil, notFound)
+		mockLegacy.EXPECT().Take(ctx, token).Return(&models.Session{UserID: userID}, 30*time.Minute, nil)
+		mockStore.EXPECT().Create(ctx, gomock.Any(), 30*time.Minute).Return(nil)
+
+		sess, err := sessUC.GetSessionByID(ctx, token)
+		require.NoError(t, err)
+		require.Equal(t, userID, sess.UserID)
+		require.Equal(t, session.HashToken(token), sess.SessionID)
+	})
+
+	t.Run("Given back when the store fails", func(t *testing.T) {
+		legacy := &models.Session{SessionID: "legacy", UserID: userID}
+		mockStore.EXPECT().Get(ctx, session.HashToken(token)).Return(nil, notFound)
+		mockLegacy.EXPECT().Take(ctx, token).Return(legacy, 30*time.Minute, nil)
+		mockStore.EXPECT().Create(ctx, gomock.Any(), 30*time.Minute).Return(errors.New("store down"))
+		mockLegacy.EXPECT().Put(ctx, token, &models.Session{SessionID: "legacy", UserID: userID}, 30*time.Minute).Return(nil)
+
+		_, err := sessUC.GetSessionByID(ctx, token)
+		require.Error(t, err)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		mockStore.EXPECT().Get(ctx, session.HashToken("unknown")).Return(nil, notFound)
+		mockLegacy.EXPECT().Take(ctx, "unknown").Return(nil, time.Duration(0), nil)
+
+		_, err := sessUC.GetSessionByID(ctx, "unknown")
+		require.ErrorIs(t, err, session.ErrSessionNotFound)
+	})
+
+	t.Run("Migrate all", func(t *testing.T) {
+		mockLegacy.EXPECT().Scan(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(token string) error) error {
+			return fn(token)
+		})
+		mockLegacy.EXPECT().Take(ctx, token).Return(&models.Session{UserID: userID}, time.Duration(0), nil)
+		mockStore.EXPECT().Create(ctx, gomock.Any(), time.Hour).Return(nil)
+
+		migrated, err := sessUC.MigrateLegacy(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, migrated)
+	})
+}
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_city_trgm_idx;
+DROP INDEX IF EXISTS users_email_local_trgm_idx;
+DROP INDEX IF EXISTS users_full_name_trgm_idx;
+DROP INDEX IF EXISTS users_search_vector_idx;
+
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,11 @@
+-- Sessions of the postgres session store, session_id is the sha256 hash of the cookie token
+CREATE TABLE IF NOT EXISTS sessions
+(
+    session_id CHAR(64) PRIMARY KEY,
+    user_id    UUID                     NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_expires_at_idx ON sessions (expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS max_expires_at,
+    DROP COLUMN IF EXISTS remember_me;
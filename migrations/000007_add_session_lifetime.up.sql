@@ -0,0 +1,7 @@
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS remember_me    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS max_expires_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE sessions SET max_expires_at = expires_at WHERE max_expires_at IS NULL;
+
+ALTER TABLE sessions ALTER COLUMN max_expires_at SET NOT NULL;
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	busPingInterval = 30 * time.Second
+	busMinBackoff   = time.Second
+	busMaxBackoff   = 30 * time.Second
+)
+
+// Invalidation bus shared by the caches of every instance
+type Bus interface {
+	// Tell every other instance that key of the cache changed
+	Publish(ctx context.Context, cache string, key string) error
+	// Evict keys of the cache changed on other instances, resync drops everything when messages may have been missed
+	Subscribe(cache string, evict func(key string), resync func())
+}
+
+// Invalidation message, instances ignore their own
+type invalidation struct {
+	Cache  string `json:"cache"`
+	Key    string `json:"key"`
+	Source string `json:"source"`
+}
+
+type subscriber struct {
+	evict  func(key string)
+	resync func()
+}
+
+// Invalidation bus over a redis pub/sub channel
+type RedisBus struct {
+	client      *redis.Client
+	channel     string
+	source      string
+	logger      logger.Logger
+	mu          sync.RWMutex
+	subscribers map[string]subscriber
+}
+
+// Redis invalidation bus constructor, Run receives the messages
+func NewRedisBus(client *redis.Client, channel string, log logger.Logger) *RedisBus {
+	return &RedisBus{
+		client:      client,
+		channel:     channel,
+		source:      uuid.NewString(),
+		logger:      log,
+		subscribers: make(map[string]subscriber),
+	}
+}
+
+// Publish invalidation of key of the cache
+func (b *RedisBus) Publish(ctx context.Context, cache string, key string) error {
+	payload, err := json.Marshal(&invalidation{Cache: cache, Key: key, Source: b.source})
+	if err != nil {
+		return errors.Wrap(err, "RedisBus.Publish.json.Marshal")
+	}
+	if err = b.client.Publish(ctx, b.channel, payload).Err(); err != nil {
+		return errors.Wrap(err, "RedisBus.Publish.client.Publish")
+	}
+	return nil
+}
+
+// Subscribe the cache to invalidations published by other instances
+func (b *RedisBus) Subscribe(cache string, evict func(key string), resync func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[cache] = subscriber{evict: evict, resync: resync}
+}
+
+// Receive invalidations until ctx is cancelled.
+// Messages published while the subscription is down are lost, so subscribers resync when it drops and again once it is back.
+func (b *RedisBus) Run(ctx context.Context) {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+
+	subscribed := false
+	backoff := busMinBackoff
+	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, busPingInterval)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if err = pubsub.Ping(ctx); err == nil {
+					continue
+				}
+			}
+
+			b.logger.Errorf("RedisBus.Run channel: %s, error: %v, retrying in %s", b.channel, err, backoff)
+			b.resync()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > busMaxBackoff {
+				backoff = busMaxBackoff
+			}
+			continue
+		}
+
+		backoff = busMinBackoff
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind != "subscribe" {
+				continue
+			}
+			if subscribed {
+				b.logger.Infof("RedisBus.Run channel: %s resubscribed, resyncing caches", b.channel)
+				b.resync()
+			}
+			subscribed = true
+		case *redis.Message:
+			b.dispatch(msg.Payload)
+		}
+	}
+}
+
+func (b *RedisBus) dispatch(payload string) {
+	msg := &invalidation{}
+	if err := json.Unmarshal([]byte(payload), msg); err != nil {
+		b.logger.Errorf("RedisBus.dispatch.json.Unmarshal: %v", err)
+		return
+	}
+	if msg.Source == b.source {
+		return
+	}
+
+	b.mu.RLock()
+	sub, ok := b.subscribers[msg.Cache]
+	b.mu.RUnlock()
+
+	if ok {
+		sub.evict(msg.Key)
+	}
+}
+
+func (b *RedisBus) resync() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subscribers {
+		sub.resync()
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// In-memory bus connecting the caches of simulated instances
+type memoryBus struct {
+	mu        sync.Mutex
+	instances []*memoryBusInstance
+}
+
+type memoryBusInstance struct {
+	bus         *memoryBus
+	subscribers map[string]subscriber
+}
+
+func (b *memoryBus) instance() *memoryBusInstance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	instance := &memoryBusInstance{bus: b, subscribers: make(map[string]subscriber)}
+	b.instances = append(b.instances, instance)
+	return instance
+}
+
+func (i *memoryBusInstance) Publish(ctx context.Context, cache string, key string) error {
+	i.bus.mu.Lock()
+	defer i.bus.mu.Unlock()
+
+	for _, other := range i.bus.instances {
+		if sub, ok := other.subscribers[cache]; ok && other != i {
+			sub.evict(key)
+		}
+	}
+	return nil
+}
+
+func (i *memoryBusInstance) Subscribe(cache string, evict func(key string), resync func()) {
+	i.subscribers[cache] = subscriber{evict: evict, resync: resync}
+}
+
+func TestCache_BusInvalidation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	bus := &memoryBus{}
+	opts := Options{Name: "bus-test", LocalSize: 10, LocalTTL: time.Minute}
+
+	optsA, optsB := opts, opts
+	optsA.Bus, optsB.Bus = bus.instance(), bus.instance()
+	a := New[string](nil, optsA)
+	b := New[string](nil, optsB)
+
+	version := "v1"
+	load := func(ctx context.Context) (string, error) { return version, nil }
+
+	for _, c := range []*Cache[string]{a, b} {
+		value, err := c.Get(ctx, "key", load)
+		require.NoError(t, err)
+		require.Equal(t, "v1", value)
+	}
+
+	version = "v2"
+	require.NoError(t, a.Delete(ctx, "key"))
+
+	value, err := b.Get(ctx, "key", load)
+	require.NoError(t, err)
+	require.Equal(t, "v2", value)
+}
+
+func TestRedisBus_Dispatch(t *testing.T) {
+	t.Parallel()
+
+	bus := NewRedisBus(nil, "test", nil)
+
+	evicted := make([]string, 0)
+	resyncs := 0
+	bus.Subscribe("users", func(key string) { evicted = append(evicted, key) }, func() { resyncs++ })
+
+	payload := func(msg invalidation) string {
+		data, err := json.Marshal(&msg)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	bus.dispatch(payload(invalidation{Cache: "users", Key: "user-1", Source: "other"}))
+	bus.dispatch(payload(invalidation{Cache: "users", Key: "user-2", Source: bus.source}))
+	bus.dispatch(payload(invalidation{Cache: "sessions", Key: "session-1", Source: "other"}))
+	require.Equal(t, []string{"user-1"}, evicted)
+
+	bus.resync()
+	require.Equal(t, 1, resyncs)
+}
+
+func TestCache_Purge(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newTestCache(nil)
+	load := func(ctx context.Context) (string, error) { return "value", nil }
+
+	_, err := c.Get(ctx, "key", load)
+	require.NoError(t, err)
+	require.Equal(t, 1, c.Stats().LocalEntries)
+
+	c.Purge()
+	require.Equal(t, 0, c.Stats().LocalEntries)
+}
@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Shared loads run for at most this long when the options don't say
+const defaultLoadTimeout = 10 * time.Second
+
+var errLoadAborted = errors.New("cache load aborted")
+
+// Shared cache tier, like redis
+type Remote[V any] interface {
+	// Get value of key, ok is false on a miss
+	Get(ctx context.Context, key string) (value V, ok bool, err error)
+	Set(ctx context.Context, key string, value V, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Cache options, a zero LocalSize or LocalTTL disables the in-process tier
+type Options struct {
+	// Name the cache statistics are published under, empty for unpublished
+	Name      string
+	LocalSize int
+	LocalTTL  time.Duration
+	RemoteTTL time.Duration
+	// How long not found failures are cached in process, zero disables negative caching
+	NegativeTTL time.Duration
+	// TTLs are spread by up to this fraction either way so entries set together don't expire together
+	Jitter float64
+	// Check if a load failure means not found
+	IsNotFound func(err error) bool
+	// Called on remote tier failures, the cache falls back to loading
+	OnError func(op string, key string, err error)
+	// Deletes are published on the bus and deletes of other instances evict the local tier, needs a Name
+	Bus Bus
+	// Limit of a shared load, it outlives the get that started it so one caller going away doesn't fail the others
+	LoadTimeout time.Duration
+}
+
+// Two tier cache, a bounded in-process LRU in front of a shared remote tier.
+// Concurrent misses of a key share one load.
+type Cache[V any] struct {
+	opts   Options
+	local  *LRU[V]
+	remote Remote[V]
+	group  singleflight.Group
+	// Epochs of the keys being loaded, bumped by a Delete of the key so loads started before it don't fill either tier
+	mu     sync.Mutex
+	epochs map[string]*keyEpoch
+	// Bumped by Purge, loads started before it don't fill the cache
+	generation atomic.Uint64
+	stats      counters
+}
+
+type keyEpoch struct {
+	epoch uint64
+	loads int
+}
+
+// Epoch a load started in, it is current until the key or the whole local tier is dropped
+type loadEpoch struct {
+	key        uint64
+	generation uint64
+}
+
+// Cache constructor, remote may be nil for an in-process only cache
+func New[V any](remote Remote[V], opts Options) *Cache[V] {
+	if opts.LoadTimeout <= 0 {
+		opts.LoadTimeout = defaultLoadTimeout
+	}
+	c := &Cache[V]{opts: opts, local: NewLRU[V](opts.LocalSize), remote: remote, epochs: make(map[string]*keyEpoch)}
+	c.local.evicted = func() { c.stats.evictions.Add(1) }
+	if opts.Name != "" {
+		register(opts.Name, c.Stats)
+		if opts.Bus != nil {
+			opts.Bus.Subscribe(opts.Name, c.DeleteLocal, c.Purge)
+		}
+	}
+	return c
+}
+
+// Get value of key from the local tier, the remote tier or load, in that order.
+// Loaded values are stored in both tiers, not found failures in the local tier only.
+// Concurrent gets of a key wait on one load, it runs detached from their contexts but keeps their values.
+func (c *Cache[V]) Get(ctx context.Context, key string, load func(ctx context.Context) (V, error)) (V, error) {
+	if value, ok, err := c.getLocal(key); ok {
+		return value, err
+	}
+
+	loaded := false
+	results := make(chan singleflight.Result, 1)
+	go func() {
+		// A load that panics or exits its goroutine fails the gets waiting on it instead of leaving them hanging
+		result := singleflight.Result{Err: errLoadAborted}
+		defer func() {
+			if r := recover(); r != nil {
+				result.Err = fmt.Errorf("cache load of %s panicked: %v", key, r)
+			}
+			results <- result
+		}()
+
+		result.Val, result.Err, result.Shared = c.group.Do(key, func() (interface{}, error) {
+			loaded = true
+			// The load of a get that just missed the local tier may have finished before this one started
+			if value, ok, err := c.getLocal(key); ok {
+				return value, err
+			}
+
+			loadCtx, cancel := context.WithTimeout(detach(ctx), c.opts.LoadTimeout)
+			defer cancel()
+
+			epoch := c.beginLoad(key)
+			defer c.endLoad(key)
+			return c.fetch(loadCtx, key, epoch, load)
+		})
+	}()
+
+	var zero V
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case result := <-results:
+		if result.Shared && !loaded {
+			c.stats.shared.Add(1)
+		}
+		if result.Err != nil {
+			return zero, result.Err
+		}
+		return result.Val.(V), nil
+	}
+}
+
+func (c *Cache[V]) getLocal(key string) (V, bool, error) {
+	value, ok, err := c.local.Get(key)
+	if ok {
+		if err != nil {
+			c.stats.negativeHits.Add(1)
+		} else {
+			c.stats.localHits.Add(1)
+		}
+	}
+	return value, ok, err
+}
+
+func (c *Cache[V]) fetch(ctx context.Context, key string, epoch loadEpoch, load func(ctx context.Context) (V, error)) (V, error) {
+	if c.remote != nil {
+		value, ok, err := c.remote.Get(ctx, key)
+		if err != nil {
+			c.remoteError("get", key, err)
+		}
+		if ok {
+			c.stats.remoteHits.Add(1)
+			c.setLocal(key, value, epoch)
+			return value, nil
+		}
+	}
+
+	c.stats.misses.Add(1)
+	value, err := load(ctx)
+	if err != nil {
+		if c.opts.IsNotFound != nil && c.opts.IsNotFound(err) && c.isCurrent(key, epoch) {
+			c.local.SetErr(key, err, c.jitter(c.opts.NegativeTTL))
+		}
+		return value, err
+	}
+
+	c.setRemote(ctx, key, value, epoch)
+	c.setLocal(key, value, epoch)
+
+	return value, nil
+}
+
+func (c *Cache[V]) setLocal(key string, value V, epoch loadEpoch) {
+	if c.isCurrent(key, epoch) {
+		c.local.Set(key, value, c.jitter(c.opts.LocalTTL))
+	}
+}
+
+// Store a loaded value in the remote tier unless the key was deleted since the load started.
+// A delete landing while the value is written may have been overwritten, the value is dropped again then.
+func (c *Cache[V]) setRemote(ctx context.Context, key string, value V, epoch loadEpoch) {
+	if c.remote == nil || !c.isCurrent(key, epoch) {
+		return
+	}
+	if err := c.remote.Set(ctx, key, value, c.jitter(c.opts.RemoteTTL)); err != nil {
+		c.remoteError("set", key, err)
+		return
+	}
+	if !c.isCurrent(key, epoch) {
+		if err := c.remote.Delete(ctx, key); err != nil {
+			c.remoteError("delete", key, err)
+		}
+	}
+}
+
+func (c *Cache[V]) beginLoad(key string) loadEpoch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, ok := c.epochs[key]
+	if !ok {
+		state = &keyEpoch{}
+		c.epochs[key] = state
+	}
+	state.loads++
+	return loadEpoch{key: state.epoch, generation: c.generation.Load()}
+}
+
+func (c *Cache[V]) endLoad(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state, ok := c.epochs[key]; ok {
+		if state.loads--; state.loads <= 0 {
+			delete(c.epochs, key)
+		}
+	}
+}
+
+func (c *Cache[V]) isCurrent(key string, epoch loadEpoch) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, ok := c.epochs[key]
+	return ok && state.epoch == epoch.key && c.generation.Load() == epoch.generation
+}
+
+// Drop pending loads of the key, only keys being loaded have an epoch to bump
+func (c *Cache[V]) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state, ok := c.epochs[key]; ok {
+		state.epoch++
+	}
+}
+
+// Delete key from both tiers and the local tiers of other instances, pending loads of it are not cached
+func (c *Cache[V]) Delete(ctx context.Context, key string) error {
+	c.DeleteLocal(key)
+
+	var err error
+	if c.remote != nil {
+		err = c.remote.Delete(ctx, key)
+	}
+	if c.opts.Bus != nil && c.opts.Name != "" {
+		if busErr := c.opts.Bus.Publish(ctx, c.opts.Name, key); busErr != nil && err == nil {
+			err = busErr
+		}
+	}
+
+	return err
+}
+
+// Delete key from the local tier only
+func (c *Cache[V]) DeleteLocal(key string) {
+	c.invalidate(key)
+	c.group.Forget(key)
+	c.local.Delete(key)
+}
+
+// Drop every entry of the local tier
+func (c *Cache[V]) Purge() {
+	c.generation.Add(1)
+	c.local.Clear()
+}
+
+func (c *Cache[V]) remoteError(op string, key string, err error) {
+	c.stats.remoteErrors.Add(1)
+	if c.opts.OnError != nil {
+		c.opts.OnError(op, key, err)
+	}
+}
+
+func (c *Cache[V]) jitter(ttl time.Duration) time.Duration {
+	if c.opts.Jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration((rand.Float64()*2-1)*c.opts.Jitter*float64(ttl))
+}
+
+// Hit and miss statistics of the cache
+func (c *Cache[V]) Stats() Stats {
+	return c.stats.snapshot(c.local.Len())
+}
+
+// Context keeping the values of its parent but not its cancellation
+type detachedContext struct {
+	context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
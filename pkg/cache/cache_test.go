@@ -0,0 +1,285 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errNotFound = errors.New("not found")
+
+type mapRemote struct {
+	mu     sync.Mutex
+	values map[string]string
+	ttls   map[string]time.Duration
+}
+
+func newMapRemote() *mapRemote {
+	return &mapRemote{values: make(map[string]string), ttls: make(map[string]time.Duration)}
+}
+
+func (r *mapRemote) Get(ctx context.Context, key string) (string, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, ok := r.values[key]
+	return value, ok, nil
+}
+
+func (r *mapRemote) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.values[key] = value
+	r.ttls[key] = ttl
+	return nil
+}
+
+func (r *mapRemote) Delete(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.values, key)
+	return nil
+}
+
+func newTestCache(remote Remote[string]) *Cache[string] {
+	return New[string](remote, Options{
+		LocalSize:   2,
+		LocalTTL:    time.Minute,
+		RemoteTTL:   time.Hour,
+		NegativeTTL: time.Minute,
+		IsNotFound:  func(err error) bool { return errors.Is(err, errNotFound) },
+	})
+}
+
+func TestCache_Get(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	remote := newMapRemote()
+	c := newTestCache(remote)
+
+	loads := 0
+	load := func(ctx context.Context) (string, error) {
+		loads++
+		return "value", nil
+	}
+
+	value, err := c.Get(ctx, "key", load)
+	require.NoError(t, err)
+	require.Equal(t, "value", value)
+	require.Equal(t, "value", remote.values["key"])
+
+	value, err = c.Get(ctx, "key", load)
+	require.NoError(t, err)
+	require.Equal(t, "value", value)
+	require.Equal(t, 1, loads)
+
+	c.DeleteLocal("key")
+	_, err = c.Get(ctx, "key", load)
+	require.NoError(t, err)
+	require.Equal(t, 1, loads)
+
+	stats := c.Stats()
+	require.Equal(t, uint64(1), stats.Misses)
+	require.Equal(t, uint64(1), stats.LocalHits)
+	require.Equal(t, uint64(1), stats.RemoteHits)
+
+	require.NoError(t, c.Delete(ctx, "key"))
+	_, err = c.Get(ctx, "key", load)
+	require.NoError(t, err)
+	require.Equal(t, 2, loads)
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	remote := newMapRemote()
+	c := newTestCache(remote)
+
+	loads := 0
+	load := func(ctx context.Context) (string, error) {
+		loads++
+		return "", errNotFound
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := c.Get(ctx, "missing", load)
+		require.ErrorIs(t, err, errNotFound)
+	}
+	require.Equal(t, 1, loads)
+	require.Equal(t, uint64(2), c.Stats().NegativeHits)
+	require.NotContains(t, remote.values, "missing")
+
+	failed := errors.New("connection refused")
+	for i := 0; i < 2; i++ {
+		_, err := c.Get(ctx, "failing", func(ctx context.Context) (string, error) {
+			loads++
+			return "", failed
+		})
+		require.ErrorIs(t, err, failed)
+	}
+	require.Equal(t, 3, loads)
+}
+
+func TestCache_SharedLoads(t *testing.T) {
+	t.Parallel()
+
+	c := newTestCache(nil)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		loads.Add(1)
+		<-release
+		return "value", nil
+	}
+
+	type result struct {
+		value string
+		err   error
+	}
+
+	const callers = 10
+	results := make(chan result, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			value, err := c.Get(context.Background(), "key", load)
+			results <- result{value: value, err: err}
+		}()
+	}
+
+	close(release)
+	for i := 0; i < callers; i++ {
+		res := <-results
+		require.NoError(t, res.err)
+		require.Equal(t, "value", res.value)
+	}
+
+	// Callers either waited on the load or came after it and hit the local tier
+	stats := c.Stats()
+	require.Equal(t, int32(1), loads.Load())
+	require.Equal(t, uint64(callers-1), stats.Shared+stats.LocalHits)
+}
+
+func TestCache_LoadOutlivesCaller(t *testing.T) {
+	t.Parallel()
+
+	c := newTestCache(nil)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		close(started)
+		<-release
+		return "value", ctx.Err()
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.Get(first, "key", load)
+		firstErr <- err
+	}()
+	<-started
+
+	type result struct {
+		value string
+		err   error
+	}
+	second := make(chan result, 1)
+	go func() {
+		value, err := c.Get(context.Background(), "key", load)
+		second <- result{value: value, err: err}
+	}()
+
+	cancel()
+	require.ErrorIs(t, <-firstErr, context.Canceled)
+
+	close(release)
+	res := <-second
+	require.NoError(t, res.err)
+	require.Equal(t, "value", res.value)
+}
+
+func TestCache_DeleteDuringLoad(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	remote := newMapRemote()
+	c := newTestCache(remote)
+
+	var started sync.WaitGroup
+	release := make(chan struct{})
+	load := func(value string) func(ctx context.Context) (string, error) {
+		return func(ctx context.Context) (string, error) {
+			started.Done()
+			<-release
+			return value, nil
+		}
+	}
+
+	done := make(chan error, 2)
+	started.Add(2)
+	for _, key := range []string{"key", "other"} {
+		key := key
+		go func() {
+			_, err := c.Get(ctx, key, load(key))
+			done <- err
+		}()
+	}
+	started.Wait()
+
+	require.NoError(t, c.Delete(ctx, "key"))
+	close(release)
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+
+	// The load started before the delete fills neither tier, loads of other keys are not affected
+	require.NotContains(t, remote.values, "key")
+	_, ok, _ := c.local.Get("key")
+	require.False(t, ok)
+	require.Equal(t, "other", remote.values["other"])
+	_, ok, _ = c.local.Get("other")
+	require.True(t, ok)
+}
+
+func TestCache_Eviction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newTestCache(nil)
+	load := func(ctx context.Context) (string, error) { return "value", nil }
+
+	for _, key := range []string{"a", "b", "a", "c"} {
+		_, err := c.Get(ctx, key, load)
+		require.NoError(t, err)
+	}
+
+	stats := c.Stats()
+	require.Equal(t, 2, stats.LocalEntries)
+	require.Equal(t, uint64(1), stats.Evictions)
+
+	_, ok, _ := c.local.Get("b")
+	require.False(t, ok)
+	_, ok, _ = c.local.Get("a")
+	require.True(t, ok)
+}
+
+func TestCache_Jitter(t *testing.T) {
+	t.Parallel()
+
+	c := New[string](nil, Options{Jitter: 0.1})
+	for i := 0; i < 100; i++ {
+		ttl := c.jitter(time.Hour)
+		require.GreaterOrEqual(t, ttl, 54*time.Minute)
+		require.LessOrEqual(t, ttl, 66*time.Minute)
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type lruEntry[V any] struct {
+	key     string
+	value   V
+	err     error
+	expires time.Time
+}
+
+// Bounded in-process cache evicting the least recently used entries, safe for concurrent use
+type LRU[V any] struct {
+	size    int
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	evicted func()
+}
+
+// LRU constructor, size is the max number of entries
+func NewLRU[V any](size int) *LRU[V] {
+	return &LRU[V]{size: size, order: list.New(), entries: make(map[string]*list.Element, size)}
+}
+
+// Get value of key, ok is false on a miss and err is set for cached failures like not found ids
+func (l *LRU[V]) Get(key string) (value V, ok bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.entries[key]
+	if !ok {
+		return value, false, nil
+	}
+
+	entry := elem.Value.(*lruEntry[V])
+	if time.Now().After(entry.expires) {
+		l.removeElement(elem)
+		return value, false, nil
+	}
+
+	l.order.MoveToFront(elem)
+	return entry.value, true, entry.err
+}
+
+// Set value of key for ttl
+func (l *LRU[V]) Set(key string, value V, ttl time.Duration) {
+	l.set(key, value, nil, ttl)
+}
+
+// Set failure of key for ttl, it is returned by Get instead of a value
+func (l *LRU[V]) SetErr(key string, err error, ttl time.Duration) {
+	var zero V
+	l.set(key, zero, err, ttl)
+}
+
+func (l *LRU[V]) set(key string, value V, err error, ttl time.Duration) {
+	if l.size <= 0 || ttl <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := &lruEntry[V]{key: key, value: value, err: err, expires: time.Now().Add(ttl)}
+	if elem, ok := l.entries[key]; ok {
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(entry)
+	for l.order.Len() > l.size {
+		l.removeElement(l.order.Back())
+		if l.evicted != nil {
+			l.evicted()
+		}
+	}
+}
+
+// Delete key
+func (l *LRU[V]) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[key]; ok {
+		l.removeElement(elem)
+	}
+}
+
+// Delete every key
+func (l *LRU[V]) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.order.Init()
+	l.entries = make(map[string]*list.Element, l.size)
+}
+
+// Number of entries, expired ones included until they are read or evicted
+func (l *LRU[V]) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.order.Len()
+}
+
+func (l *LRU[V]) removeElement(elem *list.Element) {
+	l.order.Remove(elem)
+	delete(l.entries, elem.Value.(*lruEntry[V]).key)
+}
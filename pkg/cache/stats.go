@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// Cache statistics, misses count loads from the source and shared the gets served by another get's load
+type Stats struct {
+	LocalHits    uint64 `json:"local_hits"`
+	RemoteHits   uint64 `json:"remote_hits"`
+	NegativeHits uint64 `json:"negative_hits"`
+	Misses       uint64 `json:"misses"`
+	Shared       uint64 `json:"shared"`
+	Evictions    uint64 `json:"evictions"`
+	RemoteErrors uint64 `json:"remote_errors"`
+	LocalEntries int    `json:"local_entries"`
+}
+
+type counters struct {
+	localHits    atomic.Uint64
+	remoteHits   atomic.Uint64
+	negativeHits atomic.Uint64
+	misses       atomic.Uint64
+	shared       atomic.Uint64
+	evictions    atomic.Uint64
+	remoteErrors atomic.Uint64
+}
+
+func (c *counters) snapshot(localEntries int) Stats {
+	return Stats{
+		LocalHits:    c.localHits.Load(),
+		RemoteHits:   c.remoteHits.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		Misses:       c.misses.Load(),
+		Shared:       c.shared.Load(),
+		Evictions:    c.evictions.Load(),
+		RemoteErrors: c.remoteErrors.Load(),
+		LocalEntries: localEntries,
+	}
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]func() Stats)
+)
+
+// Statistics of named caches are published as the "cache" expvar
+func init() {
+	expvar.Publish("cache", expvar.Func(func() interface{} { return AllStats() }))
+}
+
+// A cache created later under the same name replaces the earlier one
+func register(name string, stats func() Stats) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[name] = stats
+}
+
+// Statistics of every named cache, keyed by name
+func AllStats() map[string]Stats {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	all := make(map[string]Stats, len(registry))
+	for name, stats := range registry {
+		all[name] = stats()
+	}
+	return all
+}
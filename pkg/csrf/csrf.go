@@ -1,32 +1,114 @@
 package csrf
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
-	"io"
-
-	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
 )
 
 const (
 	CSRFHeader = "X-CSRF-TOKEN"
-	// 32 bytes
-	csrfSalt = "KbWaoi5xtDC3GEfBa9ovQdzOzXsuVU9I"
+
+	// Token payload, issue and expiry unix seconds followed by a random nonce
+	nonceBytes   = 16
+	payloadBytes = 16 + nonceBytes
+
+	defaultTTL = time.Hour
+	// Tolerated clock skew between instances for tokens issued in the future
+	maxSkew = time.Minute
 )
 
-// Create CSRF Token
-func MakeToken(sid string, logger logger.Logger) string {
-	hash := sha256.New()
-	_, err := io.WriteString(hash, csrfSalt+sid)
+var (
+	ErrMalformed = errors.New("malformed csrf value")
+	ErrSignature = errors.New("csrf signature mismatch")
+	ErrExpired   = errors.New("csrf value expired")
+)
+
+// Stateless CSRF tokens, HMAC signed over the session id with their issue time and expiry.
+// Any instance sharing the secret validates tokens of the others.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// Random signing secret, for a single development instance without a configured one
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, sha256.Size)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// CSRF token signer constructor, tokens live an hour when ttl is not set
+func NewSigner(secret []byte, ttl time.Duration) *Signer {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &Signer{secret: secret, ttl: ttl, now: time.Now}
+}
+
+// Token lifetime
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Create CSRF token bound to the session id
+func (s *Signer) MakeToken(sid string) (string, error) {
+	payload := make([]byte, payloadBytes)
+	issuedAt := s.now()
+	binary.BigEndian.PutUint64(payload[0:8], uint64(issuedAt.Unix()))
+	binary.BigEndian.PutUint64(payload[8:16], uint64(issuedAt.Add(s.ttl).Unix()))
+	if _, err := rand.Read(payload[16:]); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload, sid)), nil
+}
+
+// Validate CSRF token of the session id, the signature is compared in constant time
+func (s *Signer) ValidateToken(token string, sid string) error {
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) != payloadBytes {
+		return ErrMalformed
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
 	if err != nil {
-		logger.Errorf("Make CSRF Token", err)
+		return ErrMalformed
 	}
-	token := base64.RawStdEncoding.EncodeToString(hash.Sum(nil))
-	return token
+
+	if !hmac.Equal(mac, s.sign(payload, sid)) {
+		return ErrSignature
+	}
+
+	now := s.now()
+	issuedAt := time.Unix(int64(binary.BigEndian.Uint64(payload[0:8])), 0)
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[8:16])), 0)
+	if issuedAt.After(now.Add(maxSkew)) || !now.Before(expiresAt) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Compare header and cookie values of double submitted tokens in constant time
+func Equal(a, b string) bool {
+	return hmac.Equal([]byte(a), []byte(b))
 }
 
-// Validate CSRF token
-func ValidateToken(token string, sid string, logger logger.Logger) bool {
-	trueToken := MakeToken(sid, logger)
-	return token == trueToken
+func (s *Signer) sign(payload []byte, sid string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(payload)
+	h.Write([]byte{0})
+	h.Write([]byte(sid))
+	return h.Sum(nil)
 }
@@ -0,0 +1,57 @@
+package csrf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner_ValidateToken(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	signer := NewSigner([]byte("secret"), time.Hour)
+	signer.now = func() time.Time { return now }
+
+	token, err := signer.MakeToken("sid")
+	require.NoError(t, err)
+	require.NoError(t, signer.ValidateToken(token, "sid"))
+
+	other, err := signer.MakeToken("sid")
+	require.NoError(t, err)
+	require.NotEqual(t, token, other)
+
+	t.Run("Other session", func(t *testing.T) {
+		require.ErrorIs(t, signer.ValidateToken(token, "other"), ErrSignature)
+	})
+
+	t.Run("Other secret", func(t *testing.T) {
+		require.ErrorIs(t, NewSigner([]byte("other"), time.Hour).ValidateToken(token, "sid"), ErrSignature)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		require.ErrorIs(t, signer.ValidateToken("x"+token, "sid"), ErrMalformed)
+		tampered := []byte(token)
+		i := len(tampered) - 10
+		if tampered[i] == 'A' {
+			tampered[i] = 'B'
+		} else {
+			tampered[i] = 'A'
+		}
+		require.ErrorIs(t, signer.ValidateToken(string(tampered), "sid"), ErrSignature)
+		require.ErrorIs(t, signer.ValidateToken("token", "sid"), ErrMalformed)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired := NewSigner([]byte("secret"), time.Hour)
+		expired.now = func() time.Time { return now.Add(time.Hour) }
+		require.ErrorIs(t, expired.ValidateToken(token, "sid"), ErrExpired)
+	})
+
+	t.Run("Issued in the future", func(t *testing.T) {
+		early := NewSigner([]byte("secret"), time.Hour)
+		early.now = func() time.Time { return now.Add(-2 * maxSkew) }
+		require.ErrorIs(t, early.ValidateToken(token, "sid"), ErrExpired)
+	})
+}
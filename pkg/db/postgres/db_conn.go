@@ -9,35 +9,63 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-const (
-	maxOpenConns    = 60
-	connMaxLifetime = 120
-	maxIdleConns    = 30
-	connMaxIdleTime = 20
-)
-
 // Return new Postgresql db instance
 func NewPsqlDB(c *config.Config) (*sqlx.DB, error) {
-	dataSourceName := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s",
-		c.Postgres.PostgresqlHost,
-		c.Postgres.PostgresqlPort,
-		c.Postgres.PostgresqlUser,
-		c.Postgres.PostgresqlDbName,
-		c.Postgres.PostgresqlPassword,
-	)
-
-	db, err := sqlx.Connect(c.Postgres.PgDriver, dataSourceName)
+	db, err := sqlx.Connect(c.Postgres.PgDriver, dataSourceName(c, c.Postgres.PostgresqlHost, c.Postgres.PostgresqlPort))
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(maxOpenConns)
-	db.SetConnMaxLifetime(connMaxLifetime * time.Second)
-	db.SetMaxIdleConns(maxIdleConns)
-	db.SetConnMaxIdleTime(connMaxIdleTime * time.Second)
+	setPool(db, c.Postgres.Pool)
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// Return new Postgresql router over the primary and the configured read replicas, writes tracks read your writes stickiness.
+// Replicas start unhealthy, reads go to the primary until CheckReplicas passes for them.
+func NewPsqlRouter(c *config.Config, writes WriteTracker) (*Router, error) {
+	primary, err := NewPsqlDB(c)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]*sqlx.DB, 0, len(c.Postgres.Replicas))
+	for _, r := range c.Postgres.Replicas {
+		replica, err := sqlx.Open(c.Postgres.PgDriver, dataSourceName(c, r.Host, r.Port))
+		if err != nil {
+			primary.Close()
+			for _, opened := range replicas {
+				opened.Close()
+			}
+			return nil, err
+		}
+		pool := c.Postgres.Pool
+		if r.Pool != (config.PostgresPool{}) {
+			pool = r.Pool
+		}
+		setPool(replica, pool)
+		replicas = append(replicas, replica)
+	}
+
+	return NewRouter(primary, replicas, time.Duration(c.Postgres.ReadYourWritesWindow)*time.Second, writes), nil
+}
+
+func dataSourceName(c *config.Config, host string, port string) string {
+	return fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s",
+		host,
+		port,
+		c.Postgres.PostgresqlUser,
+		c.Postgres.PostgresqlDbName,
+		c.Postgres.PostgresqlPassword,
+	)
+}
+
+func setPool(db *sqlx.DB, pool config.PostgresPool) {
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetConnMaxLifetime(time.Duration(pool.ConnMaxLifetime) * time.Second)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxIdleTime(time.Duration(pool.ConnMaxIdleTime) * time.Second)
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const replicaPingTimeout = 2 * time.Second
+
+type primaryKey struct{}
+
+type replica struct {
+	db      *sqlx.DB
+	healthy atomic.Bool
+}
+
+// Routes reads to healthy read replicas and writes to the primary
+type Router struct {
+	primary  *sqlx.DB
+	replicas []*replica
+	next     atomic.Uint64
+
+	stickiness time.Duration
+	writes     WriteTracker
+}
+
+// Router constructor, readers that wrote within stickiness keep reading from the primary.
+// A nil writes tracker keeps writers in process, which only holds for a single instance.
+func NewRouter(primary *sqlx.DB, replicas []*sqlx.DB, stickiness time.Duration, writes WriteTracker) *Router {
+	if writes == nil {
+		writes = newMemoryWriteTracker()
+	}
+	r := &Router{primary: primary, stickiness: stickiness, writes: writes}
+	for _, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+	return r
+}
+
+// Primary database
+func (r *Router) Primary() *sqlx.DB {
+	return r.primary
+}
+
+// Connection for writes, the transaction of ctx or the primary
+func (r *Router) Writer(ctx context.Context) Querier {
+	return Conn(ctx, r.primary)
+}
+
+// Connection for reads, the transaction of ctx, the primary for sticky contexts or a healthy replica.
+// Without healthy replicas reads fall back to the primary.
+func (r *Router) Reader(ctx context.Context) Querier {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	if usePrimary, _ := ctx.Value(primaryKey{}).(bool); usePrimary || len(r.replicas) == 0 {
+		return r.primary
+	}
+
+	start := r.next.Add(1)
+	for i := 0; i < len(r.replicas); i++ {
+		replica := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if replica.healthy.Load() {
+			return replica.db
+		}
+	}
+
+	return r.primary
+}
+
+// Ping every replica and mark it healthy or not, returns the failed ones
+func (r *Router) CheckReplicas(ctx context.Context) error {
+	failed := make([]string, 0)
+	for i, replica := range r.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+		err := replica.db.PingContext(pingCtx)
+		cancel()
+
+		replica.healthy.Store(err == nil)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("replica %d: %s", i, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Router.CheckReplicas: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Remember that the writer wrote, its reads go to the primary for the stickiness window
+func (r *Router) MarkWrite(ctx context.Context, writer string) error {
+	if r.stickiness <= 0 || len(r.replicas) == 0 {
+		return nil
+	}
+	return r.writes.MarkWrite(ctx, writer, r.stickiness)
+}
+
+// Check if the writer wrote within the stickiness window
+func (r *Router) IsSticky(ctx context.Context, writer string) (bool, error) {
+	if r.stickiness <= 0 || len(r.replicas) == 0 {
+		return false, nil
+	}
+	return r.writes.WroteRecently(ctx, writer)
+}
+
+// Context whose reads go to the primary
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Close primary and replicas
+func (r *Router) Close() error {
+	err := r.primary.Close()
+	for _, replica := range r.replicas {
+		if replicaErr := replica.db.Close(); replicaErr != nil && err == nil {
+			err = replicaErr
+		}
+	}
+	return err
+}
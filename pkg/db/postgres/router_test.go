@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return sqlx.NewDb(db, "sqlmock"), mock
+}
+
+func TestRouter_Reader(t *testing.T) {
+	t.Parallel()
+
+	primary, _ := newMockDB(t)
+	healthy, healthyMock := newMockDB(t)
+	down, downMock := newMockDB(t)
+	router := NewRouter(primary, []*sqlx.DB{healthy, down}, time.Minute, nil)
+	ctx := context.Background()
+
+	t.Run("Primary before replica check", func(t *testing.T) {
+		require.Same(t, primary, router.Reader(ctx))
+	})
+
+	healthyMock.ExpectPing()
+	downMock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	require.Error(t, router.CheckReplicas(ctx))
+	require.NoError(t, healthyMock.ExpectationsWereMet())
+	require.NoError(t, downMock.ExpectationsWereMet())
+
+	t.Run("Healthy replica", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			require.Same(t, healthy, router.Reader(ctx))
+		}
+	})
+
+	t.Run("Sticky context", func(t *testing.T) {
+		require.Same(t, primary, router.Reader(WithPrimary(ctx)))
+	})
+
+	t.Run("Writer", func(t *testing.T) {
+		require.Same(t, primary, router.Writer(ctx))
+	})
+}
+
+func TestRouter_ReaderInTx(t *testing.T) {
+	t.Parallel()
+
+	primary, mock := newMockDB(t)
+	replica, _ := newMockDB(t)
+	router := NewRouter(primary, []*sqlx.DB{replica}, time.Minute, nil)
+	router.replicas[0].healthy.Store(true)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	err := NewTxManager(primary).WithinTx(context.Background(), func(ctx context.Context) error {
+		require.Same(t, router.Writer(ctx), router.Reader(ctx))
+		require.NotSame(t, replica, router.Reader(ctx))
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRouter_MarkWrite(t *testing.T) {
+	t.Parallel()
+
+	primary, _ := newMockDB(t)
+	replica, _ := newMockDB(t)
+	ctx := context.Background()
+
+	t.Run("Sticky within window", func(t *testing.T) {
+		router := NewRouter(primary, []*sqlx.DB{replica}, time.Minute, nil)
+		require.NoError(t, router.MarkWrite(ctx, "session"))
+
+		sticky, err := router.IsSticky(ctx, "session")
+		require.NoError(t, err)
+		require.True(t, sticky)
+		sticky, err = router.IsSticky(ctx, "other")
+		require.NoError(t, err)
+		require.False(t, sticky)
+	})
+
+	t.Run("Expired window", func(t *testing.T) {
+		writes := newMemoryWriteTracker()
+		router := NewRouter(primary, []*sqlx.DB{replica}, time.Minute, writes)
+		writes.writes["session"] = time.Now().Add(-time.Second)
+
+		sticky, err := router.IsSticky(ctx, "session")
+		require.NoError(t, err)
+		require.False(t, sticky)
+	})
+
+	t.Run("Shared tracker", func(t *testing.T) {
+		writes := newMemoryWriteTracker()
+		first := NewRouter(primary, []*sqlx.DB{replica}, time.Minute, writes)
+		second := NewRouter(primary, []*sqlx.DB{replica}, time.Minute, writes)
+		require.NoError(t, first.MarkWrite(ctx, "session"))
+
+		sticky, err := second.IsSticky(ctx, "session")
+		require.NoError(t, err)
+		require.True(t, sticky)
+	})
+
+	t.Run("Without replicas", func(t *testing.T) {
+		router := NewRouter(primary, nil, time.Minute, nil)
+		require.NoError(t, router.MarkWrite(ctx, "session"))
+
+		sticky, err := router.IsSticky(ctx, "session")
+		require.NoError(t, err)
+		require.False(t, sticky)
+	})
+}
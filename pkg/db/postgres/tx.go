@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	maxTxAttempts = 3
+
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// Query methods shared by *sqlx.DB and *sqlx.Tx
+type Querier interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// Runs functions in a transaction carried by their context
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// Transaction manager, repositories join its transactions through Conn
+type TxManager struct {
+	db        *sqlx.DB
+	opts      *sql.TxOptions
+	attempts  int
+	isRetried func(err error) bool
+}
+
+// Transaction manager constructor, transactions are serializable and retried on serialization failures
+func NewTxManager(db *sqlx.DB) *TxManager {
+	return &TxManager{
+		db:        db,
+		opts:      &sql.TxOptions{Isolation: sql.LevelSerializable},
+		attempts:  maxTxAttempts,
+		isRetried: IsSerializationFailure,
+	}
+}
+
+// Run fn in a transaction, committed when fn returns nil and rolled back on error or panic.
+// Serialization failures rerun fn in a new transaction, a fn joining an outer transaction is never rerun.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return fn(ctx)
+	}
+
+	var err error
+	for attempt := 1; attempt <= m.attempts; attempt++ {
+		if err = m.runTx(ctx, fn); err == nil || !m.isRetried(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (m *TxManager) runTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := m.db.BeginTxx(ctx, m.opts)
+	if err != nil {
+		return errors.Wrap(err, "TxManager.runTx.BeginTxx")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Wrapf(err, "TxManager.runTx.Rollback: %v", rbErr)
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "TxManager.runTx.Commit")
+	}
+
+	return nil
+}
+
+// Transaction of ctx if there is one, db otherwise
+func Conn(ctx context.Context, db *sqlx.DB) Querier {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// Check if err is a serialization failure or deadlock, the transaction may succeed when retried
+func IsSerializationFailure(err error) bool {
+	var sqlState interface{ SQLState() string }
+	if !errors.As(err, &sqlState) {
+		return false
+	}
+	code := sqlState.SQLState()
+	return code == sqlStateSerializationFailure || code == sqlStateDeadlockDetected
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+const insertAudit = "INSERT INTO audit(action) VALUES ($1)"
+
+func newTxManager(t *testing.T) (*TxManager, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	return NewTxManager(sqlxDB), mock
+}
+
+func TestTxManager_WithinTx(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Commit", func(t *testing.T) {
+		txManager, mock := newTxManager(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(insertAudit).WithArgs("register").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+			_, err := Conn(ctx, txManager.db).ExecContext(ctx, insertAudit, "register")
+			return err
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rollback on error", func(t *testing.T) {
+		txManager, mock := newTxManager(t)
+		fnErr := errors.New("fn failed")
+
+		mock.ExpectBegin()
+		mock.ExpectExec(insertAudit).WithArgs("register").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectRollback()
+
+		err := txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+			if _, err := Conn(ctx, txManager.db).ExecContext(ctx, insertAudit, "register"); err != nil {
+				return err
+			}
+			return fnErr
+		})
+		require.ErrorIs(t, err, fnErr)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rollback on panic", func(t *testing.T) {
+		txManager, mock := newTxManager(t)
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		require.PanicsWithValue(t, "fn panicked", func() {
+			_ = txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+				panic("fn panicked")
+			})
+		})
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Retry serialization failure", func(t *testing.T) {
+		txManager, mock := newTxManager(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(insertAudit).WithArgs("register").WillReturnError(pgx.PgError{Code: sqlStateSerializationFailure})
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec(insertAudit).WithArgs("register").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		attempts := 0
+		err := txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+			attempts++
+			_, err := Conn(ctx, txManager.db).ExecContext(ctx, insertAudit, "register")
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, attempts)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Nested joins outer transaction", func(t *testing.T) {
+		txManager, mock := newTxManager(t)
+
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		err := txManager.WithinTx(context.Background(), func(outer context.Context) error {
+			return txManager.WithinTx(outer, func(inner context.Context) error {
+				require.Same(t, Conn(outer, txManager.db), Conn(inner, txManager.db))
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
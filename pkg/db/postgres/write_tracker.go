@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Writers are swept from the in-process tracker once it grows past this
+const maxStickyWriters = 1024
+
+// Record of the clients that wrote recently. Instances behind a load balancer must share it,
+// a client's next read may land on any of them.
+type WriteTracker interface {
+	MarkWrite(ctx context.Context, writer string, window time.Duration) error
+	// Check if the writer wrote within the window it was marked with
+	WroteRecently(ctx context.Context, writer string) (bool, error)
+}
+
+// In-process write tracker, only for a single instance
+type memoryWriteTracker struct {
+	mu     sync.Mutex
+	writes map[string]time.Time
+}
+
+func newMemoryWriteTracker() *memoryWriteTracker {
+	return &memoryWriteTracker{writes: make(map[string]time.Time)}
+}
+
+func (m *memoryWriteTracker) MarkWrite(ctx context.Context, writer string, window time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if len(m.writes) >= maxStickyWriters {
+		for w, until := range m.writes {
+			if now.After(until) {
+				delete(m.writes, w)
+			}
+		}
+	}
+	m.writes[writer] = now.Add(window)
+	return nil
+}
+
+func (m *memoryWriteTracker) WroteRecently(ctx context.Context, writer string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, ok := m.writes[writer]
+	return ok && !time.Now().After(until), nil
+}
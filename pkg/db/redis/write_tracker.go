@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const writeTrackerPrefix = "read-your-writes:"
+
+// Read your writes tracker shared by every instance, a key per writer expires with its window
+type WriteTracker struct {
+	client *redis.Client
+}
+
+// Redis write tracker constructor
+func NewWriteTracker(client *redis.Client) *WriteTracker {
+	return &WriteTracker{client: client}
+}
+
+// Remember that the writer wrote for the window
+func (w *WriteTracker) MarkWrite(ctx context.Context, writer string, window time.Duration) error {
+	if err := w.client.Set(ctx, writeTrackerPrefix+writer, 1, window).Err(); err != nil {
+		return errors.Wrap(err, "WriteTracker.MarkWrite.Set")
+	}
+	return nil
+}
+
+// Check if the writer wrote within its window
+func (w *WriteTracker) WroteRecently(ctx context.Context, writer string) (bool, error) {
+	n, err := w.client.Exists(ctx, writeTrackerPrefix+writer).Result()
+	if err != nil {
+		return false, errors.Wrap(err, "WriteTracker.WroteRecently.Exists")
+	}
+	return n > 0, nil
+}
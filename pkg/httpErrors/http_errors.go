@@ -40,6 +40,12 @@ var (
 	InvalidJWTClaims      = errors.New("Invalid JWT claims")
 	NotAllowedImageHeader = errors.New("Not allowed image header")
 	NoCookie              = errors.New("not found cookie header")
+	BucketNotAllowed      = errors.New("Bucket is chosen by the server")
+	PreconditionFailed    = errors.New("Precondition Failed")
+	PreconditionRequired  = errors.New("If-Match header is required")
+	AccountDeactivated    = errors.New("Account is deactivated")
+	RestoreWindowExpired  = errors.New("Restore window has expired")
+	SessionLimitReached   = errors.New("Too many active sessions")
 )
 
 // Rest error interface
@@ -146,6 +152,11 @@ func NewInternalServerError(causes interface{}) RestErr {
 
 // Parser of error string messages returns RestError
 func ParseErrors(err error) RestErr {
+	// Already parsed, its causes would be matched again otherwise
+	if restErr, ok := err.(RestErr); ok {
+		return restErr
+	}
+
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
 		return NewRestError(http.StatusNotFound, NotFound.Error(), err)
@@ -166,13 +177,15 @@ func ParseErrors(err error) RestErr {
 	case strings.Contains(strings.ToLower(err.Error()), "bcrypt"):
 		return NewRestError(http.StatusBadRequest, BadRequest.Error(), err)
 	default:
-		if restErr, ok := err.(RestErr); ok {
-			return restErr
-		}
 		return NewInternalServerError(err)
 	}
 }
 
+// Check if err is a unique constraint violation of postgres
+func IsUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "SQLSTATE 23505")
+}
+
 func parseSqlErrors(err error) RestErr {
 	if strings.Contains(err.Error(), "23505") {
 		return NewRestError(http.StatusBadRequest, ExistsEmailError.Error(), err)
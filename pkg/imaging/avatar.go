@@ -0,0 +1,135 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif" // gif decoder
+	"image/jpeg"
+	"image/png"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // webp decoder
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrImageTooLarge     = errors.New("image dimensions are too large")
+)
+
+// Avatar processing options
+type Options struct {
+	MaxWidth    int
+	MaxHeight   int
+	Sizes       []int
+	JPEGQuality int
+}
+
+// Default avatar processing options
+var DefaultAvatarOptions = Options{
+	MaxWidth:    4096,
+	MaxHeight:   4096,
+	Sizes:       []int{64, 256, 512},
+	JPEGQuality: 85,
+}
+
+// Encoded square avatar image
+type Variant struct {
+	Size        int
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+// Decode the image, centre crop it to a square and re-encode every requested size, capped by the size of the crop.
+// Variants report their actual size. Re-encoding drops all metadata, EXIF included.
+func ProcessAvatar(data []byte, opts Options) ([]Variant, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(ErrUnsupportedFormat, err.Error())
+	}
+	// Check dimensions before decoding so huge images are never allocated
+	if cfg.Width > opts.MaxWidth || cfg.Height > opts.MaxHeight {
+		return nil, errors.Wrapf(ErrImageTooLarge, "%dx%d, max %dx%d", cfg.Width, cfg.Height, opts.MaxWidth, opts.MaxHeight)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "ProcessAvatar.image.Decode")
+	}
+
+	crop := centreSquare(src.Bounds())
+	outFormat := FormatJPEG
+	if format == "png" || format == "gif" {
+		// keep transparency
+		outFormat = FormatPNG
+	}
+
+	variants := make([]Variant, 0, len(opts.Sizes))
+	emitted := make(map[int]bool, len(opts.Sizes))
+	for _, size := range opts.Sizes {
+		// Images are not upscaled, sizes above the source all come out at its size and are stored once
+		side := size
+		if side > crop.Dx() {
+			side = crop.Dx()
+		}
+		if emitted[side] {
+			continue
+		}
+		emitted[side] = true
+
+		dst := image.NewRGBA(image.Rect(0, 0, side, side))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+		encoded, err := encode(dst, outFormat, opts.JPEGQuality)
+		if err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, Variant{
+			Size:        side,
+			Data:        encoded,
+			ContentType: "image/" + outFormat,
+			Extension:   extension(outFormat),
+		})
+	}
+
+	return variants, nil
+}
+
+func centreSquare(bounds image.Rectangle) image.Rectangle {
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+	return image.Rect(x, y, x+side, y+side)
+}
+
+func encode(img image.Image, format string, quality int) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	switch format {
+	case FormatPNG:
+		if err := png.Encode(buf, img); err != nil {
+			return nil, errors.Wrap(err, "imaging.encode.png")
+		}
+	default:
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, errors.Wrap(err, "imaging.encode.jpeg")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func extension(format string) string {
+	if format == FormatJPEG {
+		return "jpg"
+	}
+	return format
+}
@@ -0,0 +1,93 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// Insert an APP1 Exif segment right after the SOI marker
+func withExif(jpegData []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), []byte("GPS-secret-location")...)
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(out, segment...)
+	return append(out, jpegData[2:]...)
+}
+
+func TestProcessAvatar(t *testing.T) {
+	t.Parallel()
+
+	t.Run("JPEG is cropped, resized and stripped", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, jpeg.Encode(buf, testImage(800, 600), nil))
+		data := withExif(buf.Bytes())
+		require.Contains(t, string(data), "GPS-secret-location")
+
+		variants, err := ProcessAvatar(data, DefaultAvatarOptions)
+		require.NoError(t, err)
+		require.Len(t, variants, 3)
+
+		for i, size := range []int{64, 256, 512} {
+			v := variants[i]
+			require.Equal(t, size, v.Size)
+			require.Equal(t, "image/jpeg", v.ContentType)
+			require.Equal(t, "jpg", v.Extension)
+			require.NotContains(t, string(v.Data), "Exif")
+
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(v.Data))
+			require.NoError(t, err)
+			require.Equal(t, size, cfg.Width)
+			require.Equal(t, size, cfg.Height)
+		}
+	})
+
+	t.Run("PNG stays PNG and is not upscaled", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, png.Encode(buf, testImage(100, 300)))
+
+		variants, err := ProcessAvatar(buf.Bytes(), DefaultAvatarOptions)
+		require.NoError(t, err)
+		// 256 and 512 would both be the 100 pixel source
+		require.Len(t, variants, 2)
+		require.Equal(t, 64, variants[0].Size)
+		require.Equal(t, 100, variants[1].Size)
+
+		cfg, err := png.DecodeConfig(bytes.NewReader(variants[1].Data))
+		require.NoError(t, err)
+		require.Equal(t, "image/png", variants[1].ContentType)
+		require.Equal(t, 100, cfg.Width)
+		require.Equal(t, 100, cfg.Height)
+	})
+
+	t.Run("Too large", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, png.Encode(buf, image.NewGray(image.Rect(0, 0, 300, 20))))
+
+		_, err := ProcessAvatar(buf.Bytes(), Options{MaxWidth: 200, MaxHeight: 200, Sizes: []int{64}})
+		require.True(t, errors.Is(err, ErrImageTooLarge))
+	})
+
+	t.Run("Not an image", func(t *testing.T) {
+		_, err := ProcessAvatar([]byte("definitely not an image"), DefaultAvatarOptions)
+		require.True(t, errors.Is(err, ErrUnsupportedFormat))
+	})
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/fekuna/go-rest-clean-architecture/config"
@@ -23,18 +24,22 @@ type Logger interface {
 	DPanicf(template string, args ...interface{})
 	Fatal(args ...interface{})
 	Fatalf(template string, args ...interface{})
+	Level() string
+	SetLevel(level string) error
 }
 
 // Logger
 type apiLogger struct {
 	cfg         *config.Config
 	sugarLogger *zap.SugaredLogger
+	level       zap.AtomicLevel
 }
 
 // App Logger constructor
 func NewApiLogger(cfg *config.Config) *apiLogger {
 	return &apiLogger{
-		cfg: cfg,
+		cfg:   cfg,
+		level: zap.NewAtomicLevel(),
 	}
 }
 
@@ -85,7 +90,8 @@ func (l *apiLogger) InitLogger() {
 	}
 
 	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
-	core := zapcore.NewCore(encoder, logWriter, zap.NewAtomicLevelAt(logLevel))
+	l.level.SetLevel(logLevel)
+	core := zapcore.NewCore(encoder, logWriter, l.level)
 	logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
 
 	l.sugarLogger = logger.Sugar()
@@ -94,6 +100,21 @@ func (l *apiLogger) InitLogger() {
 	}
 }
 
+// Current log level
+func (l *apiLogger) Level() string {
+	return l.level.Level().String()
+}
+
+// Change log level at runtime
+func (l *apiLogger) SetLevel(level string) error {
+	logLevel, exists := loggerLevelMap[level]
+	if !exists {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	l.level.SetLevel(logLevel)
+	return nil
+}
+
 // Logger methods
 
 func (l *apiLogger) Debug(args ...interface{}) {
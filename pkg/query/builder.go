@@ -0,0 +1,53 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Builder of parameterised WHERE clauses, values are always bound as positional arguments
+type Builder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// Builder constructor
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Add condition, every ? in it is bound to the next argument
+func (b *Builder) Where(condition string, args ...interface{}) *Builder {
+	var sb strings.Builder
+	next := 0
+	for _, r := range condition {
+		if r == '?' && next < len(args) {
+			sb.WriteString(b.Arg(args[next]))
+			next++
+			continue
+		}
+		sb.WriteRune(r)
+	}
+
+	b.conditions = append(b.conditions, sb.String())
+	return b
+}
+
+// Bind argument, returns its placeholder
+func (b *Builder) Arg(arg interface{}) string {
+	b.args = append(b.args, arg)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// WHERE clause, empty without conditions
+func (b *Builder) WhereSQL() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+// Bound arguments
+func (b *Builder) Args() []interface{} {
+	return append([]interface{}{}, b.args...)
+}
@@ -0,0 +1,74 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor payload, the sort it was made for and the sort values of the row it points at
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+// Encode opaque cursor pointing at a row with the given sort values
+func EncodeCursor(sort Sort, values []string) string {
+	b, _ := json.Marshal(cursor{Sort: sort.String(), Values: values})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode cursor made for the same sort, returns the sort values of the row it points at
+func DecodeCursor(raw string, sort Sort) ([]string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidCursor, err.Error())
+	}
+
+	var c cursor
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, errors.Wrap(ErrInvalidCursor, err.Error())
+	}
+	if c.Sort != sort.String() || len(c.Values) != len(sort) {
+		return nil, errors.Wrap(ErrInvalidCursor, "cursor does not match the sort order")
+	}
+
+	return c.Values, nil
+}
+
+// Sort with every direction flipped, used to read the page before a cursor
+func (s Sort) Reverse() Sort {
+	reversed := make(Sort, len(s))
+	for i, f := range s {
+		f.Desc = !f.Desc
+		reversed[i] = f
+	}
+	return reversed
+}
+
+// Add keyset condition selecting rows after the cursor values in sort order, or before them when reverse is set.
+// The last sort field must be unique for pages not to skip or repeat rows.
+func (b *Builder) Keyset(sort Sort, values []string, reverse bool) *Builder {
+	alternatives := make([]string, 0, len(sort))
+	for i, field := range sort {
+		conditions := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, fmt.Sprintf("%s = %s", sort[j].Column, b.Arg(values[j])))
+		}
+
+		operator := ">"
+		if field.Desc != reverse {
+			operator = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("%s %s %s", field.Column, operator, b.Arg(values[i])))
+		alternatives = append(alternatives, "("+strings.Join(conditions, " AND ")+")")
+	}
+
+	b.conditions = append(b.conditions, "("+strings.Join(alternatives, " OR ")+")")
+	return b
+}
@@ -0,0 +1,93 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var testWhitelist = map[string]string{
+	"created_at": "created_at",
+	"last_name":  "last_name",
+	"user_id":    "user_id",
+}
+
+func TestParseSort(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Multiple fields", func(t *testing.T) {
+		sort, err := ParseSort("created_at:desc, last_name:ASC,created_at", testWhitelist)
+		require.NoError(t, err)
+		require.Equal(t, "created_at DESC, last_name ASC", sort.SQL())
+		require.Equal(t, "created_at:desc,last_name:asc", sort.String())
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		sort, err := ParseSort("last_name", testWhitelist)
+		require.NoError(t, err)
+		sort = sort.With(SortField{Field: "last_name", Column: "last_name", Desc: true}, SortField{Field: "user_id", Column: "user_id"})
+		require.Equal(t, "last_name ASC, user_id ASC", sort.SQL())
+	})
+
+	t.Run("Unknown field", func(t *testing.T) {
+		_, err := ParseSort("password:asc", testWhitelist)
+		require.True(t, errors.Is(err, ErrUnknownField))
+
+		_, err = ParseSort("created_at; DROP TABLE users", testWhitelist)
+		require.True(t, errors.Is(err, ErrUnknownField))
+	})
+
+	t.Run("Invalid direction", func(t *testing.T) {
+		_, err := ParseSort("created_at:sideways", testWhitelist)
+		require.True(t, errors.Is(err, ErrInvalidDirection))
+	})
+}
+
+func TestBuilder(t *testing.T) {
+	t.Parallel()
+
+	b := NewBuilder()
+	require.Equal(t, "", b.WhereSQL())
+
+	b.Where("role = ?", "admin").Where("created_at BETWEEN ? AND ?", 1, 2).Where("avatars IS NOT NULL")
+	require.Equal(t, " WHERE role = $1 AND created_at BETWEEN $2 AND $3 AND avatars IS NOT NULL", b.WhereSQL())
+	require.Equal(t, "$4", b.Arg(10))
+	require.Equal(t, []interface{}{"admin", 1, 2, 10}, b.Args())
+}
+
+func TestCursor(t *testing.T) {
+	t.Parallel()
+
+	sort, err := ParseSort("created_at:desc,user_id", testWhitelist)
+	require.NoError(t, err)
+
+	t.Run("Round trip", func(t *testing.T) {
+		values := []string{"2022-01-01T00:00:00Z", "4f8d4a5e-5b39-4ef5-9a5e-3b7bb6ff1f3a"}
+		decoded, err := DecodeCursor(EncodeCursor(sort, values), sort)
+		require.NoError(t, err)
+		require.Equal(t, values, decoded)
+	})
+
+	t.Run("Other sort", func(t *testing.T) {
+		other, err := ParseSort("last_name,user_id", testWhitelist)
+		require.NoError(t, err)
+
+		_, err = DecodeCursor(EncodeCursor(other, []string{"a", "b"}), sort)
+		require.True(t, errors.Is(err, ErrInvalidCursor))
+	})
+
+	t.Run("Garbage", func(t *testing.T) {
+		_, err := DecodeCursor("not a cursor!", sort)
+		require.True(t, errors.Is(err, ErrInvalidCursor))
+	})
+
+	t.Run("Keyset", func(t *testing.T) {
+		b := NewBuilder().Keyset(sort, []string{"t", "id"}, false)
+		require.Equal(t, " WHERE ((created_at < $1) OR (created_at = $2 AND user_id > $3))", b.WhereSQL())
+
+		b = NewBuilder().Keyset(sort, []string{"t", "id"}, true)
+		require.Equal(t, " WHERE ((created_at > $1) OR (created_at = $2 AND user_id < $3))", b.WhereSQL())
+		require.Equal(t, "created_at ASC, user_id DESC", sort.Reverse().SQL())
+	})
+}
@@ -0,0 +1,107 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	Asc  = "asc"
+	Desc = "desc"
+)
+
+var (
+	ErrUnknownField     = errors.New("unknown field")
+	ErrInvalidDirection = errors.New("invalid sort direction")
+)
+
+// Sort field with the SQL column it maps to
+type SortField struct {
+	Field  string
+	Column string
+	Desc   bool
+}
+
+// Sort fields in priority order
+type Sort []SortField
+
+// Parse sort query like "created_at:desc,last_name:asc", fields must be in the whitelist of field to column
+func ParseSort(raw string, whitelist map[string]string) (Sort, error) {
+	sort := make(Sort, 0)
+	if strings.TrimSpace(raw) == "" {
+		return sort, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		field, direction := strings.TrimSpace(part), Asc
+		if i := strings.IndexByte(field, ':'); i >= 0 {
+			field, direction = strings.TrimSpace(field[:i]), strings.ToLower(strings.TrimSpace(field[i+1:]))
+		}
+
+		column, ok := whitelist[field]
+		if !ok {
+			return nil, errors.Wrapf(ErrUnknownField, "sort by %q", field)
+		}
+		if direction != Asc && direction != Desc {
+			return nil, errors.Wrapf(ErrInvalidDirection, "sort by %q", part)
+		}
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+
+		sort = append(sort, SortField{Field: field, Column: column, Desc: direction == Desc})
+	}
+
+	return sort, nil
+}
+
+// Sort with the fallback appended when it is not sorted by already, a unique fallback makes the order stable
+func (s Sort) With(fallback ...SortField) Sort {
+	sort := append(Sort{}, s...)
+	for _, field := range fallback {
+		if !sort.Has(field.Field) {
+			sort = append(sort, field)
+		}
+	}
+	return sort
+}
+
+// Check if sorted by field
+func (s Sort) Has(field string) bool {
+	for _, f := range s {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ORDER BY expression
+func (s Sort) SQL() string {
+	columns := make([]string, 0, len(s))
+	for _, f := range s {
+		direction := "ASC"
+		if f.Desc {
+			direction = "DESC"
+		}
+		columns = append(columns, fmt.Sprintf("%s %s", f.Column, direction))
+	}
+	return strings.Join(columns, ", ")
+}
+
+// Sort query string, the inverse of ParseSort
+func (s Sort) String() string {
+	fields := make([]string, 0, len(s))
+	for _, f := range s {
+		direction := Asc
+		if f.Desc {
+			direction = Desc
+		}
+		fields = append(fields, f.Field+":"+direction)
+	}
+	return strings.Join(fields, ",")
+}
@@ -0,0 +1,384 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	dirPerm  = 0o755
+	filePerm = 0o644
+	sniffLen = 512
+
+	// Content type of an object is kept next to it in a hidden file, hidden names can't be requested or listed
+	contentTypeSuffix = ".type"
+)
+
+var bucketNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// Local filesystem object store, buckets are directories under root
+type fsStore struct {
+	root       string
+	publicURL  string
+	signingKey []byte
+}
+
+// Local filesystem object store constructor, presigned uploads need a signing key
+func NewFSStore(root string, publicURL string, signingKey string) (ObjectStore, error) {
+	if root == "" {
+		return nil, errors.New("fsStore: empty root folder")
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewFSStore.filepath.Abs")
+	}
+	if err = os.MkdirAll(absRoot, dirPerm); err != nil {
+		return nil, errors.Wrap(err, "NewFSStore.os.MkdirAll")
+	}
+
+	return &fsStore{root: absRoot, publicURL: strings.TrimRight(publicURL, "/"), signingKey: []byte(signingKey)}, nil
+}
+
+// Random key of upload urls, for a single development instance without a configured one
+func NewSigningKey() (string, error) {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.Wrap(err, "NewSigningKey.rand.Read")
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// Write object to the filesystem, the file is renamed into place once fully written
+func (s *fsStore) PutObject(ctx context.Context, bucket string, key string, reader io.Reader, size int64, contentType string) (*ObjectInfo, error) {
+	objectPath, err := s.objectPath(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(objectPath), dirPerm); err != nil {
+		return nil, errors.Wrap(err, "fsStore.PutObject.os.MkdirAll")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(objectPath), ".upload-*")
+	if err != nil {
+		return nil, errors.Wrap(err, "fsStore.PutObject.os.CreateTemp")
+	}
+	defer os.Remove(tmp.Name())
+
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+	written, err := io.Copy(tmp, reader)
+	if err != nil {
+		tmp.Close()
+		return nil, errors.Wrap(err, "fsStore.PutObject.io.Copy")
+	}
+	if size >= 0 && written != size {
+		tmp.Close()
+		return nil, errors.Errorf("fsStore.PutObject: short write %d of %d bytes", written, size)
+	}
+	if err = tmp.Chmod(filePerm); err != nil {
+		tmp.Close()
+		return nil, errors.Wrap(err, "fsStore.PutObject.Chmod")
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, errors.Wrap(err, "fsStore.PutObject.Close")
+	}
+	if err = os.WriteFile(contentTypePath(objectPath), []byte(contentType), filePerm); err != nil {
+		return nil, errors.Wrap(err, "fsStore.PutObject.os.WriteFile")
+	}
+	if err = os.Rename(tmp.Name(), objectPath); err != nil {
+		return nil, errors.Wrap(err, "fsStore.PutObject.os.Rename")
+	}
+
+	stat, err := os.Stat(objectPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "fsStore.PutObject.os.Stat")
+	}
+
+	return &ObjectInfo{
+		Bucket:       bucket,
+		Key:          key,
+		Size:         stat.Size(),
+		ContentType:  contentType,
+		ETag:         fileETag(stat),
+		LastModified: stat.ModTime(),
+	}, nil
+}
+
+// Open object from the filesystem
+func (s *fsStore) GetObject(ctx context.Context, bucket string, key string) (*Object, error) {
+	objectPath, err := s.objectPath(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(objectPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errors.Wrap(ErrNotFound, "fsStore.GetObject.os.Open")
+		}
+		return nil, errors.Wrap(err, "fsStore.GetObject.os.Open")
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrap(err, "fsStore.GetObject.Stat")
+	}
+	if stat.IsDir() {
+		file.Close()
+		return nil, errors.Wrap(ErrNotFound, "fsStore.GetObject")
+	}
+
+	contentType, err := s.contentType(objectPath, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Object{
+		ReadSeekCloser: file,
+		Info: ObjectInfo{
+			Bucket:       bucket,
+			Key:          key,
+			Size:         stat.Size(),
+			ContentType:  contentType,
+			ETag:         fileETag(stat),
+			LastModified: stat.ModTime(),
+		},
+	}, nil
+}
+
+// Delete object from the filesystem
+func (s *fsStore) RemoveObject(ctx context.Context, bucket string, key string) error {
+	objectPath, err := s.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(objectPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, "fsStore.RemoveObject.os.Remove")
+	}
+	if err = os.Remove(contentTypePath(objectPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, "fsStore.RemoveObject.os.Remove")
+	}
+	return nil
+}
+
+// Content type the object was stored with. Objects stored without one are sniffed,
+// only images are trusted as anything else could be a page rendered on the files origin.
+func (s *fsStore) contentType(objectPath string, file *os.File) (string, error) {
+	stored, err := os.ReadFile(contentTypePath(objectPath))
+	if err == nil && len(stored) > 0 {
+		return string(stored), nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", errors.Wrap(err, "fsStore.GetObject.os.ReadFile")
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", errors.Wrap(err, "fsStore.GetObject.Read")
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return "", errors.Wrap(err, "fsStore.GetObject.Seek")
+	}
+
+	if sniffed := http.DetectContentType(head[:n]); IsInlineContentType(sniffed) {
+		return sniffed, nil
+	}
+	return octetStream, nil
+}
+
+// List objects with the given key prefix, unfinished uploads are skipped
+func (s *fsStore) ListObjects(ctx context.Context, bucket string, prefix string) ([]ObjectInfo, error) {
+	if !bucketNameRegexp.MatchString(bucket) {
+		return nil, ErrInvalidKey
+	}
+
+	bucketPath := filepath.Join(s.root, bucket)
+	objects := make([]ObjectInfo, 0)
+	err := filepath.WalkDir(bucketPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(bucketPath, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		stat, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{
+			Bucket:       bucket,
+			Key:          key,
+			Size:         stat.Size(),
+			ETag:         fileETag(stat),
+			LastModified: stat.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "fsStore.ListObjects.WalkDir")
+	}
+
+	return objects, nil
+}
+
+// Public url of the object, served by the files route
+func (s *fsStore) ObjectURL(bucket string, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return fmt.Sprintf("%s/%s/%s", s.publicURL, bucket, strings.Join(segments, "/"))
+}
+
+// Check that the bucket directory exists
+func (s *fsStore) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	if !bucketNameRegexp.MatchString(bucket) {
+		return false, ErrInvalidKey
+	}
+
+	stat, err := os.Stat(filepath.Join(s.root, bucket))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "fsStore.BucketExists.os.Stat")
+	}
+	return stat.IsDir(), nil
+}
+
+// Create bucket directory, access is controlled by the files route
+func (s *fsStore) MakeBucket(ctx context.Context, bucket string, public bool) error {
+	if !bucketNameRegexp.MatchString(bucket) {
+		return ErrInvalidKey
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.root, bucket), dirPerm); err != nil {
+		return errors.Wrap(err, "fsStore.MakeBucket.os.MkdirAll")
+	}
+	return nil
+}
+
+// Presign a PUT request to the files route, the signature covers content type, length and expiry
+func (s *fsStore) PresignPut(ctx context.Context, bucket string, key string, contentType string, size int64, expires time.Duration) (*PresignedRequest, error) {
+	if len(s.signingKey) == 0 {
+		return nil, errors.New("fsStore.PresignPut: empty signing key")
+	}
+	if _, err := s.objectPath(bucket, key); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(expires)
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", s.sign(bucket, key, contentType, size, expiresAt.Unix()))
+
+	return &PresignedRequest{
+		Method: http.MethodPut,
+		URL:    s.ObjectURL(bucket, key) + "?" + query.Encode(),
+		Headers: map[string]string{
+			"Content-Type":   contentType,
+			"Content-Length": strconv.FormatInt(size, 10),
+		},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Check a presigned PUT request against its signature and expiry
+func (s *fsStore) verifyPresignedPut(bucket string, key string, query url.Values, contentType string, size int64) error {
+	if len(s.signingKey) == 0 {
+		return ErrSignature
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return ErrSignature
+	}
+
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return ErrSignature
+	}
+	expected, _ := hex.DecodeString(s.sign(bucket, key, contentType, size, expires))
+	if !hmac.Equal(signature, expected) {
+		return ErrSignature
+	}
+
+	return nil
+}
+
+func (s *fsStore) sign(bucket string, key string, contentType string, size int64, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%d\n%d", http.MethodPut, bucket, key, contentType, size, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Resolve bucket and key to a path under root, rejecting anything that could escape it
+func (s *fsStore) objectPath(bucket string, key string) (string, error) {
+	if !bucketNameRegexp.MatchString(bucket) || strings.Contains(bucket, "..") {
+		return "", ErrInvalidKey
+	}
+	if key == "" || strings.ContainsAny(key, "\\\x00") || path.IsAbs(key) || path.Clean(key) != key {
+		return "", ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." || strings.HasPrefix(segment, ".") {
+			return "", ErrInvalidKey
+		}
+	}
+
+	objectPath := filepath.Join(s.root, bucket, filepath.FromSlash(key))
+	rel, err := filepath.Rel(s.root, objectPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrInvalidKey
+	}
+
+	return objectPath, nil
+}
+
+func contentTypePath(objectPath string) string {
+	return filepath.Join(filepath.Dir(objectPath), "."+filepath.Base(objectPath)+contentTypeSuffix)
+}
+
+func fileETag(stat os.FileInfo) string {
+	return fmt.Sprintf("%x-%x", stat.ModTime().UnixNano(), stat.Size())
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/config"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSStore_PutGetRemove(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewFSStore(t.TempDir(), "http://localhost:5000/api/v1/files", "secret")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := "\x89PNG\r\n\x1a\nimage-bytes"
+
+	t.Run("PutObject", func(t *testing.T) {
+		info, err := store.PutObject(ctx, "avatars", "user/avatar.png", strings.NewReader(content), int64(len(content)), "image/png")
+		require.NoError(t, err)
+		require.Equal(t, int64(len(content)), info.Size)
+		require.NotEmpty(t, info.ETag)
+	})
+
+	t.Run("GetObject", func(t *testing.T) {
+		object, err := store.GetObject(ctx, "avatars", "user/avatar.png")
+		require.NoError(t, err)
+		defer object.Close()
+
+		data, err := io.ReadAll(object)
+		require.NoError(t, err)
+		require.Equal(t, content, string(data))
+		require.Equal(t, "image/png", object.Info.ContentType)
+	})
+
+	t.Run("ListObjects", func(t *testing.T) {
+		objects, err := store.ListObjects(ctx, "avatars", "user/")
+		require.NoError(t, err)
+		require.Len(t, objects, 1)
+		require.Equal(t, "user/avatar.png", objects[0].Key)
+
+		objects, err = store.ListObjects(ctx, "avatars", "other/")
+		require.NoError(t, err)
+		require.Len(t, objects, 0)
+	})
+
+	t.Run("RemoveObject", func(t *testing.T) {
+		require.NoError(t, store.RemoveObject(ctx, "avatars", "user/avatar.png"))
+
+		_, err := store.GetObject(ctx, "avatars", "user/avatar.png")
+		require.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("ObjectURL", func(t *testing.T) {
+		require.Equal(t, "http://localhost:5000/api/v1/files/avatars/user/my%20avatar.png", store.ObjectURL("avatars", "user/my avatar.png"))
+	})
+}
+
+func TestFSStore_RejectsUnsafePaths(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewFSStore(t.TempDir(), "", "")
+	require.NoError(t, err)
+
+	cases := []struct {
+		bucket string
+		key    string
+	}{
+		{"avatars", "../secret"},
+		{"avatars", "a/../../secret"},
+		{"avatars", "/etc/passwd"},
+		{"avatars", "a//b"},
+		{"avatars", ".hidden"},
+		{"avatars", "a\\..\\b"},
+		{"avatars", ""},
+		{"..", "file"},
+		{"Avatars", "file"},
+		{"a", "file"},
+	}
+
+	for _, tc := range cases {
+		_, err := store.PutObject(context.Background(), tc.bucket, tc.key, strings.NewReader("x"), 1, "text/plain")
+		require.ErrorIs(t, err, ErrInvalidKey, "bucket: %q, key: %q", tc.bucket, tc.key)
+	}
+}
+
+func TestFSStore_PresignPut(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewFSStore(t.TempDir(), "http://localhost:5000/api/v1/files", "secret")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := "\x89PNG\r\n\x1a\nimage-bytes"
+
+	e := echo.New()
+	e.PUT("/api/v1/files/:bucket/*", UploadHandler(store))
+
+	upload := func(presigned *PresignedRequest, contentType string, body string) int {
+		req := httptest.NewRequest(presigned.Method, presigned.URL, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	presigned, err := store.PresignPut(ctx, "avatars", "user/upload", "image/png", int64(len(content)), time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "image/png", presigned.Headers["Content-Type"])
+
+	t.Run("Wrong content type", func(t *testing.T) {
+		require.Equal(t, http.StatusForbidden, upload(presigned, "image/jpeg", content))
+	})
+
+	t.Run("Wrong size", func(t *testing.T) {
+		require.Equal(t, http.StatusForbidden, upload(presigned, "image/png", content+"more"))
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired, err := store.PresignPut(ctx, "avatars", "user/upload", "image/png", int64(len(content)), -time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, upload(expired, "image/png", content))
+	})
+
+	t.Run("Upload", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, upload(presigned, "image/png", content))
+
+		object, err := store.GetObject(ctx, "avatars", "user/upload")
+		require.NoError(t, err)
+		defer object.Close()
+		require.Equal(t, int64(len(content)), object.Info.Size)
+	})
+
+	t.Run("Signed content type is served", func(t *testing.T) {
+		page := "<html><script>alert(1)</script></html>"
+		presigned, err := store.PresignPut(ctx, "avatars", "user/page", "image/png", int64(len(page)), time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, upload(presigned, "image/png", page))
+
+		object, err := store.GetObject(ctx, "avatars", "user/page")
+		require.NoError(t, err)
+		defer object.Close()
+		require.Equal(t, "image/png", object.Info.ContentType)
+	})
+}
+
+func TestFileHandler(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	store, err := NewFSStore(root, "http://localhost:5000/api/v1/files", "secret")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := "\x89PNG\r\n\x1a\nimage-bytes"
+
+	e := echo.New()
+	e.GET("/api/v1/files/:bucket/*", FileHandler(store, "avatars"))
+
+	get := func(key string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/files/avatars/"+key, nil))
+		return rec
+	}
+
+	t.Run("Image", func(t *testing.T) {
+		_, err := store.PutObject(ctx, "avatars", "user/avatar.png", strings.NewReader(content), int64(len(content)), "image/png")
+		require.NoError(t, err)
+
+		rec := get("user/avatar.png")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+		require.Empty(t, rec.Header().Get(echo.HeaderContentDisposition))
+	})
+
+	t.Run("Pending upload", func(t *testing.T) {
+		key := "user/id" + PendingUploadSuffix
+		_, err := store.PutObject(ctx, "avatars", key, strings.NewReader(content), int64(len(content)), "image/png")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotFound, get(key).Code)
+	})
+
+	t.Run("Untyped document", func(t *testing.T) {
+		page := "<html><script>alert(1)</script></html>"
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "avatars", "user"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "avatars", "user", "page"), []byte(page), 0o644))
+
+		rec := get("user/page")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "application/octet-stream", rec.Header().Get(echo.HeaderContentType))
+		require.Equal(t, "attachment", rec.Header().Get(echo.HeaderContentDisposition))
+	})
+}
+
+func TestEnsureBuckets(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewFSStore(t.TempDir(), "", "")
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		Store: config.Store{
+			Buckets: config.Buckets{
+				Avatars:     config.Bucket{Name: "avatars", Public: true},
+				Attachments: config.Bucket{Name: "attachments"},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.Error(t, EnsureBuckets(ctx, store, cfg))
+
+	cfg.Store.CreateBuckets = true
+	require.NoError(t, EnsureBuckets(ctx, store, cfg))
+
+	exists, err := store.BucketExists(ctx, "attachments")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, []string{"avatars"}, PublicBuckets(cfg))
+}
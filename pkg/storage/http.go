@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+const defaultCacheControl = "public, max-age=86400"
+
+// Serve object content with ETag, Last-Modified and Range support, closes the object
+func ServeObject(c echo.Context, object *Object, cacheControl string) error {
+	defer object.Close()
+
+	header := c.Response().Header()
+	if object.Info.ETag != "" {
+		header.Set("ETag", `"`+strings.Trim(object.Info.ETag, `"`)+`"`)
+	}
+	contentType := object.Info.ContentType
+	if contentType == "" {
+		contentType = octetStream
+	}
+	header.Set(echo.HeaderContentType, contentType)
+	// Only images are shown inline, anything else is a download so it can't run on the serving origin
+	if !IsInlineContentType(contentType) {
+		header.Set(echo.HeaderContentDisposition, "attachment")
+	}
+	header.Set("Cache-Control", cacheControl)
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	http.ServeContent(c.Response(), c.Request(), object.Info.Key, object.Info.LastModified, object)
+	return nil
+}
+
+// Files handler, serves objects of the public buckets as /:bucket/*, pending uploads are not served
+func FileHandler(store ObjectStore, publicBuckets ...string) echo.HandlerFunc {
+	public := make(map[string]bool, len(publicBuckets))
+	for _, bucket := range publicBuckets {
+		public[bucket] = true
+	}
+
+	return func(c echo.Context) error {
+		if !public[c.Param("bucket")] || IsPendingUpload(c.Param("*")) {
+			return c.JSON(ErrorResponse(ErrNotFound))
+		}
+
+		object, err := store.GetObject(c.Request().Context(), c.Param("bucket"), c.Param("*"))
+		if err != nil {
+			return c.JSON(ErrorResponse(err))
+		}
+
+		return ServeObject(c, object, defaultCacheControl)
+	}
+}
+
+// Stores accepting presigned uploads through the files route
+type presignedPutVerifier interface {
+	verifyPresignedPut(bucket string, key string, query url.Values, contentType string, size int64) error
+}
+
+// Upload handler, accepts presigned PUT requests as /:bucket/*
+func UploadHandler(store ObjectStore) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		verifier, ok := store.(presignedPutVerifier)
+		if !ok {
+			return c.JSON(http.StatusNotFound, httpErrors.NewNotFoundError(http.StatusText(http.StatusNotFound)))
+		}
+
+		req := c.Request()
+		bucket, key := c.Param("bucket"), c.Param("*")
+		contentType := req.Header.Get(echo.HeaderContentType)
+		if err := verifier.verifyPresignedPut(bucket, key, req.URL.Query(), contentType, req.ContentLength); err != nil {
+			return c.JSON(ErrorResponse(err))
+		}
+
+		body := http.MaxBytesReader(c.Response(), req.Body, req.ContentLength)
+		info, err := store.PutObject(req.Context(), bucket, key, body, req.ContentLength, contentType)
+		if err != nil {
+			return c.JSON(ErrorResponse(err))
+		}
+
+		c.Response().Header().Set("ETag", `"`+info.ETag+`"`)
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+// Map storage errors to rest errors
+func ErrorResponse(err error) (int, interface{}) {
+	switch {
+	case errors.Is(err, ErrInvalidKey):
+		return http.StatusBadRequest, httpErrors.NewBadRequestError(err.Error())
+	case errors.Is(err, ErrSignature):
+		return http.StatusForbidden, httpErrors.NewForbiddenError(err.Error())
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, httpErrors.NewNotFoundError(err.Error())
+	default:
+		return httpErrors.ErrorResponse(err)
+	}
+}
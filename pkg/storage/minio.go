@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+const publicReadPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["*"]},"Action":["s3:GetObject"],"Resource":["arn:aws:s3:::%s/*"]}]}`
+
+// Minio object store
+type minioStore struct {
+	client   *minio.Client
+	endpoint string
+}
+
+// Minio object store constructor
+func NewMinioStore(client *minio.Client, endpoint string) ObjectStore {
+	return &minioStore{client: client, endpoint: endpoint}
+}
+
+// Upload object to minio
+func (s *minioStore) PutObject(ctx context.Context, bucket string, key string, reader io.Reader, size int64, contentType string) (*ObjectInfo, error) {
+	options := minio.PutObjectOptions{
+		ContentType:  contentType,
+		UserMetadata: map[string]string{"x-amz-acl": "public-read"},
+	}
+
+	uploadInfo, err := s.client.PutObject(ctx, bucket, key, reader, size, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "minioStore.PutObject")
+	}
+
+	return &ObjectInfo{
+		Bucket:       uploadInfo.Bucket,
+		Key:          uploadInfo.Key,
+		Size:         uploadInfo.Size,
+		ContentType:  contentType,
+		ETag:         uploadInfo.ETag,
+		LastModified: uploadInfo.LastModified,
+	}, nil
+}
+
+// Download object from minio
+func (s *minioStore) GetObject(ctx context.Context, bucket string, key string) (*Object, error) {
+	object, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "minioStore.GetObject")
+	}
+
+	// GetObject is lazy, Stat makes the request and reports missing objects
+	stat, err := object.Stat()
+	if err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, errors.Wrap(ErrNotFound, "minioStore.GetObject.Stat")
+		}
+		return nil, errors.Wrap(err, "minioStore.GetObject.Stat")
+	}
+
+	return &Object{
+		ReadSeekCloser: object,
+		Info: ObjectInfo{
+			Bucket:       bucket,
+			Key:          stat.Key,
+			Size:         stat.Size,
+			ContentType:  stat.ContentType,
+			ETag:         stat.ETag,
+			LastModified: stat.LastModified,
+		},
+	}, nil
+}
+
+// Delete object from minio
+func (s *minioStore) RemoveObject(ctx context.Context, bucket string, key string) error {
+	if err := s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return errors.Wrap(err, "minioStore.RemoveObject")
+	}
+	return nil
+}
+
+// List objects with the given key prefix
+func (s *minioStore) ListObjects(ctx context.Context, bucket string, prefix string) ([]ObjectInfo, error) {
+	objects := make([]ObjectInfo, 0)
+	for object := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, errors.Wrap(object.Err, "minioStore.ListObjects")
+		}
+		objects = append(objects, ObjectInfo{
+			Bucket:       bucket,
+			Key:          object.Key,
+			Size:         object.Size,
+			ContentType:  object.ContentType,
+			ETag:         object.ETag,
+			LastModified: object.LastModified,
+		})
+	}
+	return objects, nil
+}
+
+// Presign a PUT request, content type and length are signed so minio rejects anything else
+func (s *minioStore) PresignPut(ctx context.Context, bucket string, key string, contentType string, size int64, expires time.Duration) (*PresignedRequest, error) {
+	headers := http.Header{}
+	headers.Set("Content-Type", contentType)
+	headers.Set("Content-Length", strconv.FormatInt(size, 10))
+
+	presignedURL, err := s.client.PresignHeader(ctx, http.MethodPut, bucket, key, expires, nil, headers)
+	if err != nil {
+		return nil, errors.Wrap(err, "minioStore.PresignPut.PresignHeader")
+	}
+
+	return &PresignedRequest{
+		Method: http.MethodPut,
+		URL:    presignedURL.String(),
+		Headers: map[string]string{
+			"Content-Type":   contentType,
+			"Content-Length": strconv.FormatInt(size, 10),
+		},
+		ExpiresAt: time.Now().Add(expires),
+	}, nil
+}
+
+// Check that the bucket exists
+func (s *minioStore) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	exists, err := s.client.BucketExists(ctx, bucket)
+	if err != nil {
+		return false, errors.Wrap(err, "minioStore.BucketExists")
+	}
+	return exists, nil
+}
+
+// Create bucket, public buckets get an anonymous read policy
+func (s *minioStore) MakeBucket(ctx context.Context, bucket string, public bool) error {
+	if err := s.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
+		return errors.Wrap(err, "minioStore.MakeBucket")
+	}
+	if !public {
+		return nil
+	}
+
+	if err := s.client.SetBucketPolicy(ctx, bucket, fmt.Sprintf(publicReadPolicy, bucket)); err != nil {
+		return errors.Wrap(err, "minioStore.MakeBucket.SetBucketPolicy")
+	}
+	return nil
+}
+
+// Public minio object url
+func (s *minioStore) ObjectURL(bucket string, key string) string {
+	return fmt.Sprintf("%s/minio/%s/%s", s.endpoint, bucket, key)
+}
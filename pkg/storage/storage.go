@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/config"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/db/aws"
+)
+
+const (
+	DriverMinio = "minio"
+	DriverFS    = "fs"
+
+	// Object keys of direct uploads that are not confirmed yet end with this, they are never served publicly
+	PendingUploadSuffix = "_upload"
+
+	octetStream = "application/octet-stream"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid bucket or object key")
+	ErrSignature  = errors.New("invalid or expired upload signature")
+)
+
+// Object store interface
+type ObjectStore interface {
+	PutObject(ctx context.Context, bucket string, key string, reader io.Reader, size int64, contentType string) (*ObjectInfo, error)
+	GetObject(ctx context.Context, bucket string, key string) (*Object, error)
+	RemoveObject(ctx context.Context, bucket string, key string) error
+	ListObjects(ctx context.Context, bucket string, prefix string) ([]ObjectInfo, error)
+	ObjectURL(bucket string, key string) string
+	BucketExists(ctx context.Context, bucket string) (bool, error)
+	MakeBucket(ctx context.Context, bucket string, public bool) error
+	PresignPut(ctx context.Context, bucket string, key string, contentType string, size int64, expires time.Duration) (*PresignedRequest, error)
+}
+
+// Stored object metadata
+type ObjectInfo struct {
+	Bucket       string
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// Presigned upload request, the client must send exactly these headers
+type PresignedRequest struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// Stored object, the caller must close it
+type Object struct {
+	io.ReadSeekCloser
+	Info ObjectInfo
+}
+
+// Check that every configured bucket exists, creating missing ones if allowed by config
+func EnsureBuckets(ctx context.Context, store ObjectStore, cfg *config.Config) error {
+	for _, bucket := range configuredBuckets(cfg) {
+		exists, err := store.BucketExists(ctx, bucket.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if !cfg.Store.CreateBuckets {
+			return fmt.Errorf("bucket %s does not exist", bucket.Name)
+		}
+		if err = store.MakeBucket(ctx, bucket.Name, bucket.Public); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Names of the configured buckets allowing anonymous reads
+func PublicBuckets(cfg *config.Config) []string {
+	public := make([]string, 0)
+	for _, bucket := range configuredBuckets(cfg) {
+		if bucket.Public {
+			public = append(public, bucket.Name)
+		}
+	}
+	return public
+}
+
+func configuredBuckets(cfg *config.Config) []config.Bucket {
+	buckets := make([]config.Bucket, 0, 3)
+	for _, bucket := range []config.Bucket{cfg.Store.Buckets.Avatars, cfg.Store.Buckets.News, cfg.Store.Buckets.Attachments} {
+		if bucket.Name != "" {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets
+}
+
+// Object store constructor, chooses the implementation by config
+func NewObjectStore(cfg *config.Config) (ObjectStore, error) {
+	switch cfg.Store.Driver {
+	case DriverFS:
+		return NewFSStore(cfg.Store.ImageFolder, cfg.Store.PublicURL, cfg.Store.SigningKey)
+	case DriverMinio, "":
+		client, err := aws.NewAWSClient(cfg.AWS.Endpoint, cfg.AWS.MinioAccessKey, cfg.AWS.MinioSecretkey, cfg.AWS.UseSSL)
+		if err != nil {
+			return nil, err
+		}
+		return NewMinioStore(client, cfg.AWS.MinioEndpoint), nil
+	default:
+		return nil, fmt.Errorf("unknown store driver: %s", cfg.Store.Driver)
+	}
+}
+
+// Check if the key is a direct upload waiting for confirmation
+func IsPendingUpload(key string) bool {
+	return strings.HasSuffix(key, PendingUploadSuffix)
+}
+
+// Check if content of the type is safe to show inline, raster images only as svg can carry scripts
+func IsInlineContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return strings.HasPrefix(mediaType, "image/") && !strings.HasPrefix(mediaType, "image/svg")
+}
@@ -0,0 +1,83 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/fekuna/go-rest-clean-architecture/config"
+)
+
+// Client certificate policies of the config
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                   tls.NoClientCert,
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify-if-given":    tls.VerifyClientCertIfGiven,
+	"require-and-verify": tls.RequireAndVerifyClientCert,
+}
+
+// Protocol versions of the config
+var versions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Server TLS config of the settings, certificates and client CAs come from the reloader on every handshake
+func NewServerConfig(cfg config.TLS, reloader *Reloader) (*tls.Config, error) {
+	minVersion, ok := versions[cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tls min version %q", cfg.MinVersion)
+	}
+	clientAuth, ok := clientAuthTypes[cfg.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unknown tls client auth %q", cfg.ClientAuth)
+	}
+	if clientAuth >= tls.VerifyClientCertIfGiven && cfg.ClientCAFile == "" {
+		return nil, fmt.Errorf("tls client auth %q requires a client CA file", cfg.ClientAuth)
+	}
+	cipherSuites, err := cipherSuiteIDs(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		ClientAuth:     clientAuth,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if cfg.ClientCAFile != "" {
+		tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			handshake := tlsConfig.Clone()
+			handshake.GetConfigForClient = nil
+			handshake.ClientCAs = reloader.ClientCAs()
+			return handshake, nil
+		}
+	}
+	return tlsConfig, nil
+}
+
+// Cipher suite ids of their names, insecure suites are refused
+func cipherSuiteIDs(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure tls cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
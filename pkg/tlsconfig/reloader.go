@@ -0,0 +1,149 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/fekuna/go-rest-clean-architecture/pkg/logger"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// Server certificate and client CA pool, reloaded from their files so certificates rotate without a restart.
+// Failed reloads keep serving the previous certificates.
+type Reloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	log          logger.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// Certificate reloader constructor, the files are loaded right away, clientCAFile may be empty
+func NewReloader(certFile, keyFile, clientCAFile string, log logger.Logger) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile, log: log}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Load the certificate files again
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "Reloader.Reload.LoadX509KeyPair")
+	}
+
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return errors.Wrap(err, "Reloader.Reload.ReadFile")
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.Errorf("Reloader.Reload: no certificates in %s", r.clientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.mu.Unlock()
+	return nil
+}
+
+// Current server certificate, for tls.Config.GetCertificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Current client CA pool, nil without a client CA file
+func (r *Reloader) ClientCAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clientCAs
+}
+
+// Reload on changes to the directories of the files and on SIGHUP until ctx is done.
+// Directories are watched as certificates are usually replaced by renames, like kubernetes secret volumes do.
+func (r *Reloader) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events chan fsnotify.Event
+	var watchErrors chan error
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		r.log.Errorf("TLS certificates watch: %s, reloading on SIGHUP only", err)
+	} else {
+		defer watcher.Close()
+		for _, dir := range r.dirs() {
+			if err := watcher.Add(dir); err != nil {
+				r.log.Errorf("TLS certificates watch %s: %s", dir, err)
+			}
+		}
+		events, watchErrors = watcher.Events, watcher.Errors
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload("SIGHUP")
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename|fsnotify.Remove) != 0 {
+				r.reload(event.Name)
+			}
+		case err, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				continue
+			}
+			r.log.Errorf("TLS certificates watch: %s", err)
+		}
+	}
+}
+
+func (r *Reloader) reload(cause string) {
+	if err := r.Reload(); err != nil {
+		r.log.Errorf("TLS certificates reload on %s: %s, keeping the previous ones", cause, err)
+		return
+	}
+	r.log.Infof("TLS certificates reloaded on %s", cause)
+}
+
+// Directories of the certificate files
+func (r *Reloader) dirs() []string {
+	seen := make(map[string]bool)
+	dirs := make([]string, 0, 3)
+	for _, file := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if file == "" {
+			continue
+		}
+		dir := filepath.Dir(file)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,180 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/config"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// Certificate signed by parent, self signed when parent is nil
+func newTestCert(t *testing.T, name string, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"test"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{name},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCert{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) write(t *testing.T, dir string) (string, string) {
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.pem")
+	require.NoError(t, os.WriteFile(certFile, c.pem, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, c.keyPEM(t), 0o600))
+	return certFile, keyFile
+}
+
+func TestReloader_Reload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	first := newTestCert(t, "first", nil, false)
+	certFile, keyFile := first.write(t, dir)
+
+	reloader, err := NewReloader(certFile, keyFile, "", nil)
+	require.NoError(t, err)
+
+	cert, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, first.cert.Raw, cert.Certificate[0])
+
+	second := newTestCert(t, "second", nil, false)
+	second.write(t, dir)
+	require.NoError(t, reloader.Reload())
+
+	cert, err = reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, second.cert.Raw, cert.Certificate[0])
+
+	t.Run("Broken files keep the certificate", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+		require.Error(t, reloader.Reload())
+
+		cert, err := reloader.GetCertificate(nil)
+		require.NoError(t, err)
+		require.Equal(t, second.cert.Raw, cert.Certificate[0])
+	})
+}
+
+func TestNewServerConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := newTestCert(t, "server", nil, false).write(t, dir)
+	reloader, err := NewReloader(certFile, keyFile, "", nil)
+	require.NoError(t, err)
+
+	tlsConfig, err := NewServerConfig(config.TLS{MinVersion: "1.3"}, reloader)
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+
+	tlsConfig, err = NewServerConfig(config.TLS{CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}}, reloader)
+	require.NoError(t, err)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, tlsConfig.CipherSuites)
+
+	for _, cfg := range []config.TLS{
+		{MinVersion: "1.0"},
+		{ClientAuth: "always"},
+		{ClientAuth: "require-and-verify"},
+		{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+	} {
+		_, err = NewServerConfig(cfg, reloader)
+		require.Error(t, err, cfg)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, true)
+	certFile, keyFile := newTestCert(t, "127.0.0.1", ca, false).write(t, dir)
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+
+	reloader, err := NewReloader(certFile, keyFile, caFile, nil)
+	require.NoError(t, err)
+	tlsConfig, err := NewServerConfig(config.TLS{ClientCAFile: caFile, ClientAuth: "require-and-verify"}, reloader)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(IdentityFromState(r.TLS))
+	}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := newTestCert(t, "billing", ca, false)
+	clientCert := tls.Certificate{Certificate: [][]byte{client.cert.Raw}, PrivateKey: client.key}
+
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert},
+	}}}
+	res, err := httpClient.Get(server.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	identity := &Identity{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(identity))
+	require.Equal(t, "billing", identity.CommonName)
+	require.Equal(t, client.cert.SerialNumber.String(), identity.SerialNumber)
+
+	t.Run("Without client certificate", func(t *testing.T) {
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+		_, err := httpClient.Get(server.URL)
+		require.Error(t, err)
+	})
+}
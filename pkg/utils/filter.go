@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/query"
+	"github.com/labstack/echo/v4"
+)
+
+const filterDateLayout = "2006-01-02"
+
+// Get users list filter and sort from query params
+func GetUserFilterFromCtx(c echo.Context) (*models.UserFilter, error) {
+	sort, err := query.ParseSort(c.QueryParam("orderBy"), models.UserSortFields)
+	if err != nil {
+		return nil, httpErrors.NewRestError(http.StatusBadRequest, err.Error(), err)
+	}
+
+	filter := &models.UserFilter{
+		Role:    stringParam(c, "role"),
+		City:    stringParam(c, "city"),
+		Country: stringParam(c, "country"),
+		Gender:  stringParam(c, "gender"),
+		Sort:    sort,
+	}
+
+	if filter.CreatedAfter, err = timeParam(c, "created_after"); err != nil {
+		return nil, err
+	}
+	if filter.CreatedBefore, err = timeParam(c, "created_before"); err != nil {
+		return nil, err
+	}
+
+	if hasAvatar := c.QueryParam("has_avatar"); hasAvatar != "" {
+		b, err := strconv.ParseBool(hasAvatar)
+		if err != nil {
+			return nil, httpErrors.NewRestError(http.StatusBadRequest, "invalid has_avatar, must be a boolean", err)
+		}
+		filter.HasAvatar = &b
+	}
+
+	return filter, nil
+}
+
+func stringParam(c echo.Context, name string) *string {
+	value := strings.TrimSpace(c.QueryParam(name))
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// Time param as RFC 3339 timestamp or date
+func timeParam(c echo.Context, name string) (*time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, filterDateLayout} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, httpErrors.NewRestError(http.StatusBadRequest, "invalid "+name+", must be a RFC 3339 timestamp or date", nil)
+}
+
+// Get requested user fields from the fields query param
+func GetUserFieldsFromCtx(c echo.Context) ([]string, error) {
+	fields, err := models.ParseUserFields(c.QueryParam("fields"))
+	if err != nil {
+		return nil, httpErrors.NewRestError(http.StatusBadRequest, "invalid fields, "+err.Error(), err)
+	}
+	return fields, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"mime/multipart"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/fekuna/go-rest-clean-architecture/config"
 	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
@@ -18,6 +20,9 @@ type UserCtxKey struct{}
 // ReqIDCtxKey is a key used for the Request ID in context
 type ReqIDCtxKey struct{}
 
+// ClientIdentityCtxKey is a key used for the client certificate identity in context
+type ClientIdentityCtxKey struct{}
+
 // Get request id from echo context
 func GetRequestID(c echo.Context) string {
 	return c.Response().Header().Get(echo.HeaderXRequestID)
@@ -42,8 +47,13 @@ func GetConfigPath(configPath string) string {
 	return "./config/config-local"
 }
 
-// Create session cookie
-func CreateSessionCookie(cfg *config.Config, session string) *http.Cookie {
+// Create session cookie expiring with the session
+func CreateSessionCookie(cfg *config.Config, session string, expiresAt time.Time) *http.Cookie {
+	maxAge := int(time.Until(expiresAt).Seconds())
+	if maxAge < 1 {
+		maxAge = -1
+	}
+
 	return &http.Cookie{
 		Name:  cfg.Session.Name,
 		Value: session,
@@ -51,10 +61,24 @@ func CreateSessionCookie(cfg *config.Config, session string) *http.Cookie {
 		// Domain: "/",
 		// Expires:    time.Now().Add(1 * time.Minute),
 		RawExpires: "",
-		MaxAge:     cfg.Session.Expire,
+		MaxAge:     maxAge,
 		Secure:     cfg.Cookie.Secure,
 		HttpOnly:   cfg.Cookie.HTTPOnly,
-		SameSite:   0,
+		SameSite:   SameSite(cfg.Cookie.SameSite),
+	}
+}
+
+// SameSite cookie attribute of its config name, the browser default for empty or unknown names
+func SameSite(name string) http.SameSite {
+	switch strings.ToLower(name) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteDefaultMode
 	}
 }
 
@@ -4,8 +4,10 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"strconv"
 
 	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/storage"
 	"github.com/google/uuid"
 )
 
@@ -64,6 +66,11 @@ func GetImageContentType(image []byte) (string, bool) {
 	return extension, allowed
 }
 
+func IsAllowedImageMimeType(contentType string) bool {
+	_, allowed := allowedImagesContentType[contentType]
+	return allowed
+}
+
 func IsAllowedImageContentType(image []byte) bool {
 	_, allowed := GetImageContentType(image)
 	return allowed
@@ -74,3 +81,16 @@ func GetUniqFileName(userID string, fileExtension string) string {
 
 	return "userid_" + userID + "_" + randString + "." + fileExtension
 }
+
+// Object name prefix shared by every avatar
+const AvatarFilePrefix = "userid_"
+
+// Object name of one avatar size, all sizes of an upload share the same prefix
+func GetAvatarFileName(userID string, uploadID string, size int, fileExtension string) string {
+	return AvatarFilePrefix + userID + "/" + uploadID + "_" + strconv.Itoa(size) + "." + fileExtension
+}
+
+// Object name of an unprocessed direct upload, it shares the prefix of the sizes made from it
+func GetAvatarUploadName(userID string, uploadID string) string {
+	return AvatarFilePrefix + userID + "/" + uploadID + storage.PendingUploadSuffix
+}
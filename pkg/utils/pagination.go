@@ -3,8 +3,13 @@ package utils
 import (
 	"fmt"
 	"math"
+	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"github.com/fekuna/go-rest-clean-architecture/internal/models"
+	"github.com/fekuna/go-rest-clean-architecture/pkg/httpErrors"
 	"github.com/labstack/echo/v4"
 )
 
@@ -12,11 +17,15 @@ const (
 	defaultSize = 10
 )
 
-// Pagination query params
+// Pagination query params, after, before or limit switch to cursor pagination
 type PaginationQuery struct {
-	Size    int    `json:"size,omitempty"`
-	Page    int    `json:"page,omitempty"`
-	OrderBy string `json:"orderBy,omitempty"`
+	Size         int    `json:"size,omitempty"`
+	Page         int    `json:"page,omitempty"`
+	OrderBy      string `json:"orderBy,omitempty"`
+	After        string `json:"after,omitempty"`
+	Before       string `json:"before,omitempty"`
+	Cursor       bool   `json:"-"`
+	IncludeTotal bool   `json:"include_total,omitempty"`
 }
 
 // Set page size
@@ -38,7 +47,7 @@ func (q *PaginationQuery) SetSize(sizeQuery string) error {
 // Set page number
 func (q *PaginationQuery) SetPage(pageQuery string) error {
 	if pageQuery == "" {
-		q.Size = 0
+		q.Page = 0
 		return nil
 	}
 
@@ -56,6 +65,41 @@ func (q *PaginationQuery) SetOrderBy(orderByQuery string) {
 	q.OrderBy = orderByQuery
 }
 
+// Set cursor pagination params
+func (q *PaginationQuery) SetCursor(after string, before string, limitQuery string) error {
+	if after != "" && before != "" {
+		return httpErrors.NewRestError(http.StatusBadRequest, "after and before can not be used together", nil)
+	}
+	q.After, q.Before = after, before
+	q.Cursor = after != "" || before != "" || limitQuery != ""
+
+	if limitQuery != "" {
+		return q.SetSize(limitQuery)
+	}
+	return nil
+}
+
+// Set include total
+func (q *PaginationQuery) SetIncludeTotal(includeTotalQuery string) error {
+	if includeTotalQuery == "" {
+		q.IncludeTotal = false
+		return nil
+	}
+
+	b, err := strconv.ParseBool(includeTotalQuery)
+	if err != nil {
+		return httpErrors.NewRestError(http.StatusBadRequest, "invalid include_total, must be a boolean", err)
+	}
+	q.IncludeTotal = b
+
+	return nil
+}
+
+// Check if cursor pagination is used
+func (q *PaginationQuery) IsCursor() bool {
+	return q.Cursor
+}
+
 // Get offset
 func (q *PaginationQuery) GetOffset() int {
 	if q.Page == 0 {
@@ -99,17 +143,110 @@ func GetPaginationFromCtx(c echo.Context) (*PaginationQuery, error) {
 		return nil, err
 	}
 	q.SetOrderBy(c.QueryParam("orderBy"))
+	if err := q.SetCursor(c.QueryParam("after"), c.QueryParam("before"), c.QueryParam("limit")); err != nil {
+		return nil, err
+	}
+	if err := q.SetIncludeTotal(c.QueryParam("include_total")); err != nil {
+		return nil, err
+	}
+	if q.Size <= 0 {
+		return nil, httpErrors.NewRestError(http.StatusBadRequest, "size must be positive", nil)
+	}
 
 	return q, nil
 }
 
 // Get total pages int
 func GetTotalPages(totalCount int, pageSize int) int {
+	if pageSize <= 0 {
+		return 0
+	}
 	d := float64(totalCount) / float64(pageSize)
 	return int(math.Ceil(d))
 }
 
-// Get has more
+// Get has more, page zero is the first page like in GetOffset
 func GetHasMore(currentPage int, totalCount int, pageSize int) bool {
-	return currentPage < totalCount/pageSize
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	return currentPage < GetTotalPages(totalCount, pageSize)
+}
+
+// Get offset pagination of a list
+func GetPagination(totalCount int, pq *PaginationQuery) models.Pagination {
+	return models.Pagination{
+		TotalCount: totalCount,
+		TotalPages: GetTotalPages(totalCount, pq.GetSize()),
+		Page:       pq.GetPage(),
+		Size:       pq.GetSize(),
+		HasMore:    GetHasMore(pq.GetPage(), totalCount, pq.GetSize()),
+	}
+}
+
+// Set pagination links built from the request url keeping its other query params, also sent as RFC 8288 Link header
+func SetPaginationLinks(c echo.Context, pq *PaginationQuery, p *models.Pagination) {
+	base := c.Scheme() + "://" + c.Request().Host + c.Request().URL.Path
+	link := func(set map[string]string) string {
+		params := url.Values{}
+		for key, values := range c.QueryParams() {
+			params[key] = append([]string{}, values...)
+		}
+		for key, value := range set {
+			if value == "" {
+				params.Del(key)
+				continue
+			}
+			params.Set(key, value)
+		}
+		if len(params) == 0 {
+			return base
+		}
+		return base + "?" + params.Encode()
+	}
+
+	p.Links = models.PaginationLinks{Self: link(nil)}
+	if pq.IsCursor() {
+		limit := strconv.Itoa(pq.GetSize())
+		p.Links.First = link(map[string]string{"after": "", "before": "", "limit": limit})
+		if p.PrevCursor != "" {
+			p.Links.Prev = link(map[string]string{"after": "", "before": p.PrevCursor, "limit": limit})
+		}
+		if p.NextCursor != "" {
+			p.Links.Next = link(map[string]string{"after": p.NextCursor, "before": "", "limit": limit})
+		}
+	} else {
+		page := func(n int) string {
+			return link(map[string]string{"page": strconv.Itoa(n), "size": strconv.Itoa(pq.GetSize())})
+		}
+		current := pq.GetPage()
+		if current < 1 {
+			current = 1
+		}
+
+		p.Links.First = page(1)
+		if current > 1 {
+			p.Links.Prev = page(current - 1)
+		}
+		if p.HasMore {
+			p.Links.Next = page(current + 1)
+		}
+		if p.TotalPages > 0 {
+			p.Links.Last = page(p.TotalPages)
+		}
+	}
+
+	links := make([]string, 0, 5)
+	for _, l := range []struct{ rel, url string }{
+		{"self", p.Links.Self},
+		{"first", p.Links.First},
+		{"prev", p.Links.Prev},
+		{"next", p.Links.Next},
+		{"last", p.Links.Last},
+	} {
+		if l.url != "" {
+			links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, l.url, l.rel))
+		}
+	}
+	c.Response().Header().Set("Link", strings.Join(links, ", "))
 }
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHasMore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Default page", func(t *testing.T) {
+		require.False(t, GetHasMore(0, 10, 10))
+		require.True(t, GetHasMore(0, 11, 10))
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		require.True(t, GetHasMore(1, 11, 10))
+		require.False(t, GetHasMore(2, 11, 10))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		require.False(t, GetHasMore(0, 0, 10))
+	})
+}